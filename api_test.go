@@ -5,14 +5,17 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"avito_intr/internal/auth/jwt_auth"
+	"avito_intr/internal/health"
 	"avito_intr/internal/http_api"
 	"avito_intr/internal/storage/pg_storage"
 )
@@ -48,12 +51,22 @@ func newIntegrationServer(t *testing.T) http.Handler {
 	if err != nil {
 		t.Fatalf("Ошибка подключения к базе: %v", err)
 	}
-	if err := pg.Migrate(); err != nil {
+	if err := pg.Migrate(context.Background()); err != nil {
 		t.Fatalf("Ошибка миграции: %v", err)
 	}
 
+	checker := health.NewChecker(time.Second)
+	checker.Register("database", health.DatabaseCheck(pg))
+	checker.Register("migrations", health.MigrationCheck(pg))
+
 	auth := jwt_auth.NewJwtAuth(jwtKey)
-	return http_api.NewServer(pg, auth)
+	// ответ, расходящийся со swagger.yaml, проваливает тест
+	return http_api.NewServer(pg, auth, checker, http_api.Options{
+		ValidateResponses: true,
+		OnSpecViolation: func(r *http.Request, err error) {
+			t.Errorf("%s %s: response does not match swagger.yaml: %v", r.Method, r.URL.Path, err)
+		},
+	}, zap.NewNop())
 }
 
 func performRequest(handler http.Handler, method, path string, body io.Reader, token string) *httptest.ResponseRecorder {
@@ -90,6 +103,29 @@ func TestDummyLogin(t *testing.T) {
 	}
 }
 
+func TestHealthEndpoints(t *testing.T) {
+	server := newIntegrationServer(t)
+
+	rr := performRequest(server, "GET", "/healthz", nil, "")
+	if rr.Code != http.StatusOK {
+		t.Errorf("healthz: ожидался статус 200, получен %d", rr.Code)
+	}
+
+	rr = performRequest(server, "GET", "/readyz", nil, "")
+	if rr.Code != http.StatusOK {
+		t.Errorf("readyz: ожидался статус 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	var report health.Report
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("readyz: не удалось распарсить ответ: %v", err)
+	}
+	for _, name := range []string{"database", "migrations"} {
+		if report.Checks[name].Status != health.StatusOK {
+			t.Errorf("readyz: проверка %s: %+v", name, report.Checks[name])
+		}
+	}
+}
+
 func TestInvalidDummyLogin(t *testing.T) {
 	server := newIntegrationServer(t)
 
@@ -2,17 +2,76 @@ package main
 
 import (
 	"avito_intr/internal/auth/jwt_auth"
+	"avito_intr/internal/config"
+	"avito_intr/internal/feed"
 	"avito_intr/internal/grpc_api"
 	pb "avito_intr/internal/grpc_api/pvz_v1"
+	"avito_intr/internal/health"
 	"avito_intr/internal/http_api"
+	"avito_intr/internal/idempotency"
+	"avito_intr/internal/outbox"
+	"avito_intr/internal/ratelimit"
+	"avito_intr/internal/service"
 	"avito_intr/internal/storage/pg_storage"
+	"avito_intr/internal/tracing"
+	"avito_intr/internal/version"
+	"avito_intr/internal/webhook"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
 	"net"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	gaugeRefreshInterval = 15 * time.Second
+	// dbCloseTimeout ограничивает ожидание запросов, которые отменены после истечения срока остановки
+	dbCloseTimeout = 5 * time.Second
+
+	readinessTimeout        = 2 * time.Second
+	healthReportInterval    = 5 * time.Second
+	poolSaturationThreshold = 0.9
+
+	idempotencyPurgeInterval = 10 * time.Minute
+	webhookPollInterval      = time.Second
+
+	outboxPollInterval   = 200 * time.Millisecond
+	outboxInitialBackoff = time.Second
+	outboxMaxBackoff     = time.Minute
+	outboxRetention      = 24 * time.Hour
+
+	// feedPollInterval страхует от потерянных уведомлений LISTEN, обычно события приходят сразу
+	feedPollInterval = 5 * time.Second
+	feedRetention    = 24 * time.Hour
+	// rateLimitPurgeInterval - как часто удаляются наполнившиеся вёдра ограничителя запросов
+	rateLimitPurgeInterval = 10 * time.Minute
+
+	// grpcKeepaliveTime - через сколько простоя соединения сервер проверяет клиента ping-ом: поток WatchPVZ
+	// может долго молчать, и без проверки обрыв сети заметен только при следующем событии
+	grpcKeepaliveTime    = 30 * time.Second
+	grpcKeepaliveTimeout = 10 * time.Second
+	// grpcMinClientPing - ping-и клиента чаще этого считаются злоупотреблением и рвут соединение
+	grpcMinClientPing = 10 * time.Second
 )
 
 func main() {
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
 	logger, err := zap.NewDevelopment()
 	if err != nil {
 		panic(err)
@@ -24,67 +83,221 @@ func main() {
 		}
 	}(logger)
 
-	logger.Info("starting application")
+	logger.Info("starting application", zap.String("version", version.String()))
 
-	pgConn, ok := os.LookupEnv("PG_CONN")
-	if !ok {
-		logger.Fatal("PG_CONN environment variable not set")
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
-
-	jwtKey, ok := os.LookupEnv("JWT_SECRET_KEY")
-	if !ok {
-		jwtKey = "secret_key"
+	if err != nil {
+		logger.Fatal("invalid configuration", zap.Error(err))
+	}
+	logger.Info("effective configuration\n" + cfg.String())
+	if cfg.JWTSecret == config.DefaultJWTSecret {
 		logger.Warn("JWT_SECRET_KEY not set, using default")
 	}
 
-	port, ok := os.LookupEnv("PORT")
-	if !ok {
-		port = "8080"
-		logger.Warn("PORT not set, using default :8080")
+	traceCfg := tracing.Config{
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		ServiceName: "avito_intr",
+		SampleRatio: cfg.Tracing.SampleRatio,
 	}
-
-	metrics_port, ok := os.LookupEnv("METRICS_PORT")
-	if !ok {
-		metrics_port = "9000"
-		logger.Warn("METRICS_PORT not set, using default :9000")
+	shutdownTracing, err := tracing.Setup(context.Background(), traceCfg)
+	if err != nil {
+		logger.Fatal("failed to set up tracing", zap.Error(err))
 	}
-
-	grpc_port, ok := os.LookupEnv("GRPC_PORT")
-	if !ok {
-		metrics_port = "3000"
-		logger.Warn("GRPC_PORT not set, using default :9000")
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error("failed to flush traces", zap.Error(err))
+		}
+	}()
+	if traceCfg.Endpoint == "" {
+		logger.Warn("OTEL_EXPORTER_OTLP_ENDPOINT not set, traces are not exported")
 	}
 
-	pg, err := pg_storage.NewPgStorage(pgConn)
+	pg, err := pg_storage.NewPgStorage(cfg.PgConn)
 	if err != nil {
 		logger.Fatal("failed to connect to Postgres", zap.Error(err))
 	}
 
 	logger.Info("running database migrations")
-	if err := pg.Migrate(); err != nil {
+	if err := pg.Migrate(context.Background()); err != nil {
 		logger.Fatal("failed to run migrations", zap.Error(err))
 	}
 
-	auth := jwt_auth.NewJwtAuth(jwtKey)
-	h := http_api.NewServer(pg, auth, logger)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	webhooks := webhook.NewDispatcher(pg, webhook.Config{
+		MaxAttempts:    cfg.Webhooks.MaxAttempts,
+		Timeout:        cfg.Webhooks.Timeout,
+		InitialBackoff: cfg.Webhooks.InitialBackoff,
+		MaxBackoff:     cfg.Webhooks.MaxBackoff,
+	}, logger)
+
+	hub := feed.NewHub(pg, feedRetention, logger)
 
-	lis, err := net.Listen("tcp", ":"+grpc_port)
+	// события пишутся в outbox вместе с изменениями и оттуда попадают в очередь webhooks и в ленту
+	events := outbox.NewDispatcher(pg, outbox.Publishers{webhooks, hub}, outbox.Config{
+		InitialBackoff: outboxInitialBackoff,
+		MaxBackoff:     outboxMaxBackoff,
+		Retention:      outboxRetention,
+	}, logger)
+
+	// фоновые задачи останавливаются отменой ctx, база закрывается только после их завершения
+	var background sync.WaitGroup
+	runBackground := func(run func()) {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			run()
+		}()
+	}
+
+	svc := service.New(pg, logger)
+	runBackground(func() { svc.RunGaugeRefresher(ctx, gaugeRefreshInterval) })
+	runBackground(func() { idempotency.RunPurger(ctx, pg, idempotencyPurgeInterval, logger) })
+	runBackground(func() { events.Run(ctx, outboxPollInterval) })
+	runBackground(func() { webhooks.Run(ctx, webhookPollInterval) })
+	runBackground(func() { hub.Run(ctx, feedPollInterval) })
+
+	var limiter *ratelimit.Limiter
+	if cfg.RateLimits.Enabled {
+		var buckets ratelimit.Store = ratelimit.NewMemoryStore()
+		if cfg.RateLimits.Shared {
+			buckets = pg
+		}
+		limiter = ratelimit.New(buckets, rateLimitGroups(cfg.RateLimits), logger)
+		runBackground(func() { limiter.Run(ctx, rateLimitPurgeInterval) })
+	}
+
+	// список уже проверен при загрузке конфигурации
+	trustedProxies, err := ratelimit.ParseTrustedProxies(cfg.RateLimits.TrustedProxies)
+	if err != nil {
+		logger.Fatal("invalid trusted proxies", zap.Error(err))
+	}
+
+	checker := health.NewChecker(readinessTimeout)
+	checker.Register("database", health.DatabaseCheck(pg))
+	checker.Register("migrations", health.MigrationCheck(pg))
+	checker.Register("pool", health.PoolCheck(pg, poolSaturationThreshold))
+
+	auth := jwt_auth.NewJwtAuth(cfg.JWTSecret)
+	h := http_api.NewServer(svc, auth, checker, http_api.Options{
+		ValidateResponses: cfg.OpenAPI.ValidateResponses,
+		ExplorerPath:      cfg.OpenAPI.ExplorerPath,
+		PublicURL:         cfg.OpenAPI.PublicURL,
+		LegacyDeprecated:  cfg.API.LegacyDeprecated,
+		LegacySunset:      cfg.API.LegacySunset,
+		IdempotencyTTL:    cfg.API.IdempotencyTTL,
+		Feed:              hub,
+		RateLimiter:       limiter,
+		TrustedProxies:    trustedProxies,
+	}, logger)
+
+	lis, err := net.Listen("tcp", ":"+cfg.GRPCPort)
 	if err != nil {
 		logger.Fatal("failed to listen on gRPC port", zap.Error(err))
 	}
 
-	logger.Info("starting gRPC server", zap.String("grpc-port", grpc_port))
-	s := grpc.NewServer()
-	pb.RegisterPVZServiceServer(s, grpc_api.NewGrpcServer(pg, logger))
+	logger.Info("starting gRPC server", zap.String("grpc-port", cfg.GRPCPort))
+	s := grpc.NewServer(
+		grpc.KeepaliveParams(keepalive.ServerParameters{Time: grpcKeepaliveTime, Timeout: grpcKeepaliveTimeout}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: grpcMinClientPing, PermitWithoutStream: true}),
+		grpc.ChainUnaryInterceptor(
+			grpc_api.UnaryRequestIDInterceptor(logger),
+			grpc_api.UnaryTracingInterceptor(),
+			grpc_api.UnaryMetricsInterceptor(),
+			grpc_api.UnaryRateLimitInterceptor(limiter, auth, trustedProxies),
+		),
+		grpc.ChainStreamInterceptor(
+			grpc_api.StreamRequestIDInterceptor(logger),
+			grpc_api.StreamTracingInterceptor(),
+			grpc_api.StreamMetricsInterceptor(),
+			grpc_api.StreamRateLimitInterceptor(limiter, auth, trustedProxies),
+		),
+	)
+	pb.RegisterPVZServiceServer(s, grpc_api.NewGrpcServer(svc, auth, hub, cfg.API.IdempotencyTTL, logger))
+	hs := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+	runBackground(func() { grpc_api.ReportHealth(ctx, checker, hs, healthReportInterval, logger) })
 
+	serveErr := make(chan error, 2)
 	go func() {
 		if err := s.Serve(lis); err != nil {
-			logger.Fatal("failed to start gRPC server", zap.Error(err))
+			serveErr <- fmt.Errorf("gRPC server: %w", err)
 		}
 	}()
 
-	logger.Info("starting HTTP server", zap.String("port", port), zap.String("metrics-port", metrics_port))
-	if err := h.ListenAndServe(port, metrics_port); err != nil {
-		logger.Fatal("HTTP server failed", zap.Error(err))
+	logger.Info("starting HTTP server", zap.String("port", cfg.Port), zap.String("metrics-port", cfg.MetricsPort))
+	httpDone := make(chan struct{})
+	go func() {
+		defer close(httpDone)
+		if err := h.ListenAndServe(ctx, cfg.Port, cfg.MetricsPort, cfg.ShutdownTimeout); err != nil {
+			serveErr <- fmt.Errorf("HTTP server: %w", err)
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		logger.Info("shutdown signal received, draining requests", zap.Duration("timeout", cfg.ShutdownTimeout))
+	case err := <-serveErr:
+		logger.Error("server failed, shutting down", zap.Error(err))
+		exitCode = 1
+	}
+	// Отмена ctx останавливает HTTP-серверы и фоновые задачи
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	stopGRPC(shutdownCtx, s)
+	logger.Info("gRPC server stopped")
+	<-httpDone
+	logger.Info("HTTP server stopped")
+	background.Wait()
+	logger.Info("background workers stopped")
+
+	// База закрывается последней: ждём, пока отпущенные серверами запросы вернут соединения
+	dbCtx, cancelDB := context.WithTimeout(context.Background(), dbCloseTimeout)
+	defer cancelDB()
+	if err := pg.Close(dbCtx); err != nil {
+		logger.Error("failed to close Postgres", zap.Error(err))
+		exitCode = 1
+		return
+	}
+	logger.Info("Postgres connection closed")
+}
+
+func rateLimitGroups(cfg config.RateLimits) map[string]ratelimit.Group {
+	group := func(g config.RateLimitGroup) ratelimit.Group {
+		return ratelimit.Group{
+			User:   ratelimit.Limit(g.User),
+			Client: ratelimit.Limit(g.Client),
+			IP:     ratelimit.Limit(g.IP),
+		}
+	}
+	return map[string]ratelimit.Group{
+		ratelimit.GroupAuth:  group(cfg.Auth),
+		ratelimit.GroupList:  group(cfg.List),
+		ratelimit.GroupRead:  group(cfg.Read),
+		ratelimit.GroupWrite: group(cfg.Write),
+	}
+}
+
+// stopGRPC дожидается завершения текущих вызовов, а по истечении ctx обрывает оставшиеся
+func stopGRPC(ctx context.Context, s *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.Stop()
+		<-stopped
 	}
 }
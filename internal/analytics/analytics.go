@@ -0,0 +1,59 @@
+package analytics
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"sync"
+	"time"
+)
+
+const maxEntries = 256
+
+type entry struct {
+	value   *storage.Analytics
+	expires time.Time
+}
+
+// Cache хранит результаты агрегирующих запросов аналитики ttl времени,
+// чтобы частые обновления дашбордов не нагружали базу.
+type Cache struct {
+	store storage.Storage
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu      sync.Mutex
+	entries map[storage.AnalyticsFilter]entry
+}
+
+func NewCache(store storage.Storage, ttl time.Duration) *Cache {
+	return &Cache{store: store, ttl: ttl, now: time.Now, entries: make(map[storage.AnalyticsFilter]entry)}
+}
+
+func (c *Cache) Get(ctx context.Context, filter storage.AnalyticsFilter) (*storage.Analytics, error) {
+	c.mu.Lock()
+	e, ok := c.entries[filter]
+	c.mu.Unlock()
+	if ok && c.now().Before(e.expires) {
+		return e.value, nil
+	}
+
+	value, err := c.store.GetAnalytics(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if len(c.entries) >= maxEntries {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	if len(c.entries) < maxEntries {
+		c.entries[filter] = entry{value: value, expires: now.Add(c.ttl)}
+	}
+	return value, nil
+}
@@ -0,0 +1,51 @@
+package analytics
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"testing"
+	"time"
+)
+
+type countingStore struct {
+	storage.Storage
+	calls int
+}
+
+func (s *countingStore) GetAnalytics(context.Context, storage.AnalyticsFilter) (*storage.Analytics, error) {
+	s.calls++
+	return &storage.Analytics{Durations: storage.ReceptionDurations{Closed: s.calls}}, nil
+}
+
+func TestCache(t *testing.T) {
+	store := &countingStore{}
+	now := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	c := NewCache(store, time.Minute)
+	c.now = func() time.Time { return now }
+
+	moscow := storage.AnalyticsFilter{City: storage.Moscow}
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get(context.Background(), moscow); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if store.calls != 1 {
+		t.Errorf("store calls = %d, want 1", store.calls)
+	}
+
+	if _, err := c.Get(context.Background(), storage.AnalyticsFilter{City: storage.Kazan}); err != nil {
+		t.Fatal(err)
+	}
+	if store.calls != 2 {
+		t.Errorf("store calls = %d, want 2 for another filter", store.calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	res, err := c.Get(context.Background(), moscow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.calls != 3 || res.Durations.Closed != 3 {
+		t.Error("expired entry was not refreshed")
+	}
+}
@@ -1,8 +1,13 @@
 package auth
 
+import "time"
+
 type Authorization interface {
 	Generate(id, role string) (string, error)
 	Validate(tokenString string) (string, error)
+	// GenerateScoped выпускает токен на ttl, пригодный только для ValidateScoped с тем же scope
+	GenerateScoped(id, scope string, ttl time.Duration) (string, error)
+	ValidateScoped(tokenString, scope string) (string, error)
 }
 
 type TokenExpired struct{}
@@ -23,7 +23,20 @@ func (gen *JwtAuth) Generate(id, role string) (string, error) {
 		"iat":  time.Now().Unix(), // время выпуска
 		"exp":  time.Now().Add(12 * time.Hour).Unix(),
 	}
+	return gen.sign(claims)
+}
+
+func (gen *JwtAuth) GenerateScoped(id, scope string, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"id":    id,
+		"scope": scope,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(ttl).Unix(),
+	}
+	return gen.sign(claims)
+}
 
+func (gen *JwtAuth) sign(claims jwt.MapClaims) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	signedToken, err := token.SignedString(gen.secretKey)
@@ -33,7 +46,16 @@ func (gen *JwtAuth) Generate(id, role string) (string, error) {
 	return signedToken, nil
 }
 
+// Validate принимает только токены входа: токен с scope годится лишь для своего назначения
 func (gen *JwtAuth) Validate(tokenString string) (string, error) {
+	return gen.validate(tokenString, "")
+}
+
+func (gen *JwtAuth) ValidateScoped(tokenString, scope string) (string, error) {
+	return gen.validate(tokenString, scope)
+}
+
+func (gen *JwtAuth) validate(tokenString, scope string) (string, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
@@ -50,7 +72,14 @@ func (gen *JwtAuth) Validate(tokenString string) (string, error) {
 				return "", auth.TokenExpired{}
 			}
 		}
-		return claims["id"].(string), nil
+		if got, _ := claims["scope"].(string); got != scope {
+			return "", errors.New("token is not valid for this request")
+		}
+		id, ok := claims["id"].(string)
+		if !ok {
+			return "", errors.New("invalid token")
+		}
+		return id, nil
 	} else {
 		return "", errors.New("invalid token")
 	}
@@ -0,0 +1,426 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+
+	// DefaultJWTSecret используется только для локальной разработки,
+	// в production с ним сервис не запустится.
+	DefaultJWTSecret = "secret_key"
+
+	redacted = "[REDACTED]"
+)
+
+type Tracing struct {
+	// Endpoint - адрес OTLP/gRPC коллектора, пустой отключает экспорт
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+type OpenAPI struct {
+	// ValidateResponses включает сверку JSON-ответов со swagger.yaml, расхождения только логируются
+	ValidateResponses bool `yaml:"validate_responses"`
+	// ExplorerPath - путь страницы Swagger UI, пустой отключает её
+	ExplorerPath string `yaml:"explorer_path"`
+	// PublicURL подставляется в servers спецификации. Пустой - адрес берётся из запроса
+	PublicURL string `yaml:"public_url"`
+}
+
+type API struct {
+	// LegacyDeprecated - дата, с которой пути без префикса версии считаются устаревшими, для заголовка Deprecation.
+	// Пустая - такие пути не помечаются устаревшими.
+	LegacyDeprecated time.Time `yaml:"legacy_deprecated,omitempty"`
+	// LegacySunset - дата отключения путей без префикса версии для заголовка Sunset.
+	// Пустая - дата не назначена, такие пути отвечают только с заголовком Deprecation.
+	LegacySunset time.Time `yaml:"legacy_sunset,omitempty"`
+	// IdempotencyTTL - сколько хранится первый ответ на запрос с ключом идемпотентности
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl"`
+}
+
+type Webhooks struct {
+	// MaxAttempts - после стольких неудачных попыток доставка больше не повторяется
+	MaxAttempts int `yaml:"max_attempts"`
+	// Timeout ограничивает один запрос к подписчику
+	Timeout time.Duration `yaml:"timeout"`
+	// InitialBackoff - пауза перед первым повтором, дальше она удваивается до MaxBackoff
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+// RateLimit - ведро токенов: в среднем Rate запросов в секунду, подряд не больше Burst. Rate 0 - без ограничения.
+type RateLimit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// RateLimitGroup - лимиты группы маршрутов по пользователю из токена, клиенту из заголовка X-Client-ID
+// и IP. Запрос проверяется по каждому ключу, который у него есть.
+type RateLimitGroup struct {
+	User   RateLimit `yaml:"user"`
+	Client RateLimit `yaml:"client"`
+	IP     RateLimit `yaml:"ip"`
+}
+
+type RateLimits struct {
+	Enabled bool `yaml:"enabled"`
+	// Shared хранит вёдра в Postgres, чтобы лимиты были общими для всех экземпляров
+	Shared bool `yaml:"shared"`
+	// TrustedProxies - адреса и подсети прокси, которым можно верить в X-Forwarded-For.
+	// Пустой список - лимит по IP считается по адресу соединения.
+	TrustedProxies []string       `yaml:"trusted_proxies"`
+	Auth           RateLimitGroup `yaml:"auth"`
+	List           RateLimitGroup `yaml:"list"`
+	Read           RateLimitGroup `yaml:"read"`
+	Write          RateLimitGroup `yaml:"write"`
+}
+
+// Config - итоговые настройки сервиса. Источники применяются по возрастанию приоритета:
+// значения по умолчанию, YAML-файл, переменные окружения, флаги командной строки.
+type Config struct {
+	Env         string `yaml:"env"`
+	PgConn      string `yaml:"pg_conn"`
+	JWTSecret   string `yaml:"jwt_secret_key"`
+	Port        string `yaml:"port"`
+	MetricsPort string `yaml:"metrics_port"`
+	GRPCPort    string `yaml:"grpc_port"`
+	// ShutdownTimeout - сколько ждать завершения текущих запросов после SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	Tracing         Tracing       `yaml:"tracing"`
+	OpenAPI         OpenAPI       `yaml:"openapi"`
+	API             API           `yaml:"api"`
+	Webhooks        Webhooks      `yaml:"webhooks"`
+	RateLimits      RateLimits    `yaml:"rate_limits"`
+}
+
+func Default() Config {
+	return Config{
+		Env:         EnvDevelopment,
+		JWTSecret:   DefaultJWTSecret,
+		Port:        "8080",
+		MetricsPort: "9000",
+		GRPCPort:    "3000",
+
+		ShutdownTimeout: 15 * time.Second,
+		Tracing:         Tracing{SampleRatio: 1},
+		OpenAPI:         OpenAPI{ExplorerPath: "/docs"},
+		API:             API{IdempotencyTTL: 24 * time.Hour},
+		Webhooks:        Webhooks{MaxAttempts: 8, Timeout: 10 * time.Second, InitialBackoff: 10 * time.Second, MaxBackoff: time.Hour},
+		RateLimits: RateLimits{
+			Enabled: true,
+			Auth:    RateLimitGroup{IP: RateLimit{Rate: 1, Burst: 10}},
+			List: RateLimitGroup{User: RateLimit{Rate: 2, Burst: 10}, Client: RateLimit{Rate: 10, Burst: 30},
+				IP: RateLimit{Rate: 10, Burst: 30}},
+			Read: RateLimitGroup{User: RateLimit{Rate: 20, Burst: 50}, Client: RateLimit{Rate: 50, Burst: 100},
+				IP: RateLimit{Rate: 50, Burst: 100}},
+			Write: RateLimitGroup{User: RateLimit{Rate: 10, Burst: 30}, Client: RateLimit{Rate: 30, Burst: 60},
+				IP: RateLimit{Rate: 30, Burst: 60}},
+		},
+	}
+}
+
+// option связывает поле конфигурации с переменной окружения и флагом
+type option struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+func stringOption(env, flag, usage string, field func(c *Config) *string) option {
+	return option{env: env, flag: flag, usage: usage, set: func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}}
+}
+
+// dateOption разбирает дату YYYY-MM-DD, пустое значение сбрасывает дату
+func dateOption(env, flag, usage string, field func(c *Config) *time.Time) option {
+	return option{env: env, flag: flag, usage: usage, set: func(c *Config, v string) (err error) {
+		if v == "" {
+			*field(c) = time.Time{}
+			return nil
+		}
+		*field(c), err = time.Parse(time.DateOnly, v)
+		return err
+	}}
+}
+
+var options = []option{
+	stringOption("APP_ENV", "env", "environment: development or production (default development)",
+		func(c *Config) *string { return &c.Env }),
+	stringOption("PG_CONN", "pg-conn", "Postgres connection string (required)",
+		func(c *Config) *string { return &c.PgConn }),
+	stringOption("JWT_SECRET_KEY", "jwt-secret", "JWT signing key, must be changed in production",
+		func(c *Config) *string { return &c.JWTSecret }),
+	stringOption("PORT", "port", "HTTP API port (default 8080)",
+		func(c *Config) *string { return &c.Port }),
+	stringOption("METRICS_PORT", "metrics-port", "Prometheus metrics port (default 9000)",
+		func(c *Config) *string { return &c.MetricsPort }),
+	stringOption("GRPC_PORT", "grpc-port", "gRPC API port (default 3000)",
+		func(c *Config) *string { return &c.GRPCPort }),
+	{env: "SHUTDOWN_TIMEOUT", flag: "shutdown-timeout", usage: "how long to drain in-flight requests on shutdown (default 15s)",
+		set: func(c *Config, v string) (err error) {
+			c.ShutdownTimeout, err = time.ParseDuration(v)
+			return err
+		}},
+	stringOption("OTEL_EXPORTER_OTLP_ENDPOINT", "otlp-endpoint", "OTLP/gRPC collector address, empty disables export",
+		func(c *Config) *string { return &c.Tracing.Endpoint }),
+	{env: "OTEL_EXPORTER_OTLP_INSECURE", flag: "otlp-insecure", usage: "connect to the collector without TLS",
+		set: func(c *Config, v string) (err error) {
+			c.Tracing.Insecure, err = strconv.ParseBool(v)
+			return err
+		}},
+	{env: "OTEL_TRACES_SAMPLER_ARG", flag: "trace-sample-ratio", usage: "share of root traces to keep, 0..1 (default 1)",
+		set: func(c *Config, v string) (err error) {
+			c.Tracing.SampleRatio, err = strconv.ParseFloat(v, 64)
+			return err
+		}},
+	{env: "OPENAPI_VALIDATE_RESPONSES", flag: "validate-responses", usage: "check JSON responses against swagger.yaml and log violations",
+		set: func(c *Config, v string) (err error) {
+			c.OpenAPI.ValidateResponses, err = strconv.ParseBool(v)
+			return err
+		}},
+	stringOption("OPENAPI_EXPLORER_PATH", "explorer-path", "path of the embedded API explorer, empty disables it (default /docs)",
+		func(c *Config) *string { return &c.OpenAPI.ExplorerPath }),
+	stringOption("OPENAPI_PUBLIC_URL", "public-url", "base URL put into the served OpenAPI document, empty means taken from the request",
+		func(c *Config) *string { return &c.OpenAPI.PublicURL }),
+	dateOption("API_LEGACY_DEPRECATED", "legacy-deprecated", "date (YYYY-MM-DD) since when unversioned routes are deprecated, sent in the Deprecation header; empty means not deprecated",
+		func(c *Config) *time.Time { return &c.API.LegacyDeprecated }),
+	dateOption("API_LEGACY_SUNSET", "legacy-sunset", "date (YYYY-MM-DD) when unversioned routes are removed, sent in the Sunset header; empty means not scheduled",
+		func(c *Config) *time.Time { return &c.API.LegacySunset }),
+	{env: "IDEMPOTENCY_TTL", flag: "idempotency-ttl", usage: "how long the first response to a request with an idempotency key is kept (default 24h)",
+		set: func(c *Config, v string) (err error) {
+			c.API.IdempotencyTTL, err = time.ParseDuration(v)
+			return err
+		}},
+	{env: "WEBHOOK_MAX_ATTEMPTS", flag: "webhook-max-attempts", usage: "failed webhook deliveries are dead-lettered after this many attempts (default 8)",
+		set: func(c *Config, v string) (err error) {
+			c.Webhooks.MaxAttempts, err = strconv.Atoi(v)
+			return err
+		}},
+	{env: "WEBHOOK_TIMEOUT", flag: "webhook-timeout", usage: "timeout of a single webhook request (default 10s)",
+		set: func(c *Config, v string) (err error) {
+			c.Webhooks.Timeout, err = time.ParseDuration(v)
+			return err
+		}},
+	{env: "WEBHOOK_INITIAL_BACKOFF", flag: "webhook-initial-backoff", usage: "delay before the first webhook retry, doubled for each next one (default 10s)",
+		set: func(c *Config, v string) (err error) {
+			c.Webhooks.InitialBackoff, err = time.ParseDuration(v)
+			return err
+		}},
+	{env: "WEBHOOK_MAX_BACKOFF", flag: "webhook-max-backoff", usage: "upper bound of the delay between webhook retries (default 1h)",
+		set: func(c *Config, v string) (err error) {
+			c.Webhooks.MaxBackoff, err = time.ParseDuration(v)
+			return err
+		}},
+	{env: "RATE_LIMIT_ENABLED", flag: "rate-limit", usage: "limit HTTP and gRPC requests per user, client and IP (default true)",
+		set: func(c *Config, v string) (err error) {
+			c.RateLimits.Enabled, err = strconv.ParseBool(v)
+			return err
+		}},
+	{env: "RATE_LIMIT_SHARED", flag: "rate-limit-shared", usage: "keep rate limit buckets in Postgres so that limits are shared by all instances",
+		set: func(c *Config, v string) (err error) {
+			c.RateLimits.Shared, err = strconv.ParseBool(v)
+			return err
+		}},
+	{env: "RATE_LIMIT_TRUSTED_PROXIES", flag: "rate-limit-trusted-proxies", usage: "comma-separated addresses and CIDRs of proxies whose X-Forwarded-For is trusted",
+		set: func(c *Config, v string) error {
+			c.RateLimits.TrustedProxies = nil
+			for _, p := range strings.Split(v, ",") {
+				if p = strings.TrimSpace(p); p != "" {
+					c.RateLimits.TrustedProxies = append(c.RateLimits.TrustedProxies, p)
+				}
+			}
+			return nil
+		}},
+}
+
+// Load собирает конфигурацию из всех источников и проверяет её.
+// Путь к YAML-файлу задаётся флагом -config или переменной CONFIG_FILE.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	fs := flag.NewFlagSet("avito_intr", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to YAML config file (env CONFIG_FILE)")
+	values := make(map[string]*string, len(options))
+	for _, o := range options {
+		values[o.flag] = fs.String(o.flag, "", fmt.Sprintf("%s (env %s)", o.usage, o.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+
+	path := *configPath
+	if path == "" {
+		path, _ = lookupEnv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, o := range options {
+		if v, ok := lookupEnv(o.env); ok {
+			if err := o.set(&cfg, v); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", o.env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, o := range options {
+			if o.flag == f.Name && flagErr == nil {
+				if err := o.set(&cfg, *values[o.flag]); err != nil {
+					flagErr = fmt.Errorf("invalid -%s: %w", o.flag, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	switch c.Env {
+	case EnvDevelopment, EnvProduction:
+	default:
+		errs = append(errs, fmt.Errorf("env must be %q or %q, got %q", EnvDevelopment, EnvProduction, c.Env))
+	}
+	if c.PgConn == "" {
+		errs = append(errs, errors.New("pg_conn is required"))
+	}
+	if c.JWTSecret == "" {
+		errs = append(errs, errors.New("jwt_secret_key must not be empty"))
+	}
+	if c.Env == EnvProduction && c.JWTSecret == DefaultJWTSecret {
+		errs = append(errs, errors.New("refusing to start in production with the default jwt_secret_key"))
+	}
+	for name, port := range map[string]string{"port": c.Port, "metrics_port": c.MetricsPort, "grpc_port": c.GRPCPort} {
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			errs = append(errs, fmt.Errorf("%s must be a number in 1..65535, got %q", name, port))
+		}
+	}
+	if c.Port == c.MetricsPort || c.Port == c.GRPCPort || c.MetricsPort == c.GRPCPort {
+		errs = append(errs, errors.New("port, metrics_port and grpc_port must differ"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown_timeout must be positive, got %s", c.ShutdownTimeout))
+	}
+	if !c.API.LegacySunset.IsZero() && !c.API.LegacySunset.After(c.API.LegacyDeprecated) {
+		errs = append(errs, errors.New("api.legacy_sunset requires an earlier api.legacy_deprecated"))
+	}
+	if c.API.IdempotencyTTL <= 0 {
+		errs = append(errs, fmt.Errorf("api.idempotency_ttl must be positive, got %s", c.API.IdempotencyTTL))
+	}
+	if c.Webhooks.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("webhooks.max_attempts must be at least 1, got %d", c.Webhooks.MaxAttempts))
+	}
+	for name, d := range map[string]time.Duration{"timeout": c.Webhooks.Timeout, "initial_backoff": c.Webhooks.InitialBackoff,
+		"max_backoff": c.Webhooks.MaxBackoff} {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("webhooks.%s must be positive, got %s", name, d))
+		}
+	}
+	if c.Webhooks.MaxBackoff < c.Webhooks.InitialBackoff {
+		errs = append(errs, fmt.Errorf("webhooks.max_backoff must not be less than initial_backoff"))
+	}
+	for name, group := range map[string]RateLimitGroup{"auth": c.RateLimits.Auth, "list": c.RateLimits.List,
+		"read": c.RateLimits.Read, "write": c.RateLimits.Write} {
+		for scope, limit := range map[string]RateLimit{"user": group.User, "client": group.Client, "ip": group.IP} {
+			if limit.Rate < 0 || (limit.Rate > 0 && limit.Burst < 1) {
+				errs = append(errs, fmt.Errorf("rate_limits.%s.%s: rate must not be negative and burst must be at least 1, got rate %v burst %d",
+					name, scope, limit.Rate, limit.Burst))
+			}
+		}
+	}
+	for _, p := range c.RateLimits.TrustedProxies {
+		if _, err := netip.ParsePrefix(p); err != nil {
+			if _, err := netip.ParseAddr(p); err != nil {
+				errs = append(errs, fmt.Errorf("rate_limits.trusted_proxies: %q is neither an address nor a CIDR", p))
+			}
+		}
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio must be in 0..1, got %v", c.Tracing.SampleRatio))
+	}
+	if p := c.OpenAPI.ExplorerPath; p != "" && (!strings.HasPrefix(p, "/") || strings.TrimRight(p, "/") == "") {
+		errs = append(errs, fmt.Errorf("openapi.explorer_path must start with / and not be the root, got %q", p))
+	}
+	if c.OpenAPI.PublicURL != "" {
+		if u, err := url.Parse(c.OpenAPI.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("openapi.public_url must be an absolute http(s) URL, got %q", c.OpenAPI.PublicURL))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Redacted возвращает копию конфигурации, безопасную для логов:
+// секрет JWT и пароль в строке подключения к Postgres скрыты.
+func (c Config) Redacted() Config {
+	if c.JWTSecret != "" {
+		c.JWTSecret = redacted
+	}
+	c.PgConn = redactConn(c.PgConn)
+	return c
+}
+
+func (c Config) String() string {
+	out, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
+
+func redactConn(conn string) string {
+	u, err := url.Parse(conn)
+	if err != nil || u.Scheme == "" {
+		// key=value формат: скрываем password=...
+		fields := strings.Fields(conn)
+		for i, f := range fields {
+			if strings.HasPrefix(f, "password=") {
+				fields[i] = "password=" + redacted
+			}
+		}
+		return strings.Join(fields, " ")
+	}
+	return u.Redacted()
+}
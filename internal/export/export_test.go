@@ -0,0 +1,65 @@
+package export
+
+import (
+	"bytes"
+	"github.com/xuri/excelize/v2"
+	"testing"
+)
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(CSV, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]string{"city", "type"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]string{"Москва", "одежда, обувь"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "city,type\nМосква,\"одежда, обувь\"\n"
+	if buf.String() != want {
+		t.Errorf("csv = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(XLSX, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]string{{"city", "type"}, {"Казань", "электроника"}}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := f.GetRows(xlsxSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1][0] != "Казань" || got[1][1] != "электроника" {
+		t.Errorf("xlsx rows = %v, want %v", got, rows)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := NewWriter("pdf", &bytes.Buffer{}); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
@@ -0,0 +1,260 @@
+package feed
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const (
+	// readBatch - сколько событий читается из ленты за один запрос
+	readBatch = 500
+	// subscriberBuffer - сколько событий ждут медленного подписчика, после этого он отключается
+	// и переподключается с Last-Event-ID
+	subscriberBuffer = 256
+	// reconnectDelay - пауза перед новым LISTEN после обрыва соединения
+	reconnectDelay = time.Second
+	purgeInterval  = 10 * time.Minute
+)
+
+// Store - часть storage.Storage, в которой хранится лента
+type Store interface {
+	AppendFeed(ctx context.Context, event storage.Event) error
+	ReadFeed(ctx context.Context, filter storage.FeedFilter) ([]storage.FeedEvent, error)
+	LastFeedSeq(ctx context.Context) (int64, error)
+	ListenFeed(ctx context.Context, notify func()) error
+	PurgeFeed(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+// Types - события, которые попадают в ленту
+var Types = map[string]bool{
+	storage.EventReceptionOpened: true,
+	storage.EventProductAdded:    true,
+	storage.EventProductDeleted:  true,
+	storage.EventReceptionClosed: true,
+}
+
+// Filter отбирает события одного ПВЗ или города, пустые поля не проверяются
+type Filter struct {
+	PvzId string
+	City  storage.City
+}
+
+func (f Filter) match(e storage.FeedEvent) bool {
+	return (f.PvzId == "" || f.PvzId == e.Event.PvzId) && (f.City == "" || f.City == e.City)
+}
+
+var (
+	subscribers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "feed_subscribers",
+		Help: "Live event feed subscribers of this instance",
+	})
+	droppedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "feed_subscribers_dropped_total",
+		Help: "Subscribers disconnected because they did not keep up with the event feed",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(subscribers, droppedTotal)
+}
+
+// Hub читает общую ленту из Postgres и раздаёт события подписчикам своего экземпляра.
+// Каждый экземпляр сервиса держит свой Hub, поэтому подписчик получает события, записанные любым из них.
+type Hub struct {
+	store     Store
+	retention time.Duration
+	logger    *zap.Logger
+	wake      chan struct{}
+
+	mu sync.Mutex
+	// last - Seq последнего разосланного события
+	last int64
+	subs map[*Subscription]struct{}
+}
+
+func NewHub(store Store, retention time.Duration, logger *zap.Logger) *Hub {
+	return &Hub{store: store, retention: retention, logger: logger, wake: make(chan struct{}, 1),
+		subs: make(map[*Subscription]struct{})}
+}
+
+// Publish добавляет событие в ленту. Hub подключается к outbox как outbox.Publisher.
+func (h *Hub) Publish(ctx context.Context, event storage.Event) error {
+	if !Types[event.Type] {
+		return nil
+	}
+	return h.store.AppendFeed(ctx, event)
+}
+
+// Run раздаёт новые события, пока не отменён ctx. Новые события приходят по LISTEN, а раз в
+// pollInterval лента перечитывается на случай потерянного уведомления.
+func (h *Hub) Run(ctx context.Context, pollInterval time.Duration) {
+	for {
+		last, err := h.store.LastFeedSeq(ctx)
+		if err == nil {
+			h.mu.Lock()
+			h.last = last
+			h.mu.Unlock()
+			break
+		}
+		h.logger.Warn("failed to read event feed position", zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+
+	go h.listen(ctx)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	lastPurge := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			h.closeAll()
+			return
+		case <-h.wake:
+		case <-ticker.C:
+		}
+		if err := h.catchUp(ctx); err != nil && ctx.Err() == nil {
+			h.logger.Warn("failed to read event feed", zap.Error(err))
+		}
+		if time.Since(lastPurge) >= purgeInterval {
+			lastPurge = time.Now()
+			if _, err := h.store.PurgeFeed(ctx, h.retention); err != nil && ctx.Err() == nil {
+				h.logger.Warn("failed to purge event feed", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (h *Hub) listen(ctx context.Context) {
+	for {
+		err := h.store.ListenFeed(ctx, h.notify)
+		if ctx.Err() != nil {
+			return
+		}
+		h.logger.Warn("event feed listener disconnected", zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+		// пока соединения не было, уведомления терялись
+		h.notify()
+	}
+}
+
+func (h *Hub) notify() {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (h *Hub) catchUp(ctx context.Context) error {
+	for {
+		h.mu.Lock()
+		after := h.last
+		h.mu.Unlock()
+		events, err := h.store.ReadFeed(ctx, storage.FeedFilter{After: after, Limit: readBatch})
+		if err != nil {
+			return err
+		}
+		h.broadcast(events)
+		if len(events) < readBatch {
+			return nil
+		}
+	}
+}
+
+func (h *Hub) broadcast(events []storage.FeedEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, e := range events {
+		h.last = e.Seq
+		for sub := range h.subs {
+			if !sub.filter.match(e) {
+				continue
+			}
+			select {
+			case sub.ch <- e:
+			default:
+				droppedTotal.Inc()
+				h.remove(sub)
+			}
+		}
+	}
+}
+
+func (h *Hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		h.remove(sub)
+	}
+}
+
+// remove вызывается под h.mu
+func (h *Hub) remove(sub *Subscription) {
+	if _, ok := h.subs[sub]; !ok {
+		return
+	}
+	delete(h.subs, sub)
+	close(sub.ch)
+	subscribers.Dec()
+}
+
+// Subscribe подписывает на новые события по фильтру. Пропущенные до подписки события отдаёт Replay.
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sub := &Subscription{hub: h, filter: filter, ch: make(chan storage.FeedEvent, subscriberBuffer), liveFrom: h.last}
+	sub.C = sub.ch
+	h.subs[sub] = struct{}{}
+	subscribers.Inc()
+	return sub
+}
+
+// Subscription получает события в C по порядку Seq. C закрывается, если подписчик не успевает
+// за лентой или сервис останавливается.
+type Subscription struct {
+	C <-chan storage.FeedEvent
+
+	hub      *Hub
+	filter   Filter
+	ch       chan storage.FeedEvent
+	liveFrom int64
+}
+
+// Replay передаёт fn события после after, записанные до подписки: с ними и C подписчик
+// получает ленту без пропусков и повторов.
+func (s *Subscription) Replay(ctx context.Context, after int64, fn func(storage.FeedEvent) error) error {
+	for after < s.liveFrom {
+		events, err := s.hub.store.ReadFeed(ctx, storage.FeedFilter{After: after, Until: s.liveFrom,
+			PvzId: s.filter.PvzId, City: s.filter.City, Limit: readBatch})
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		if len(events) < readBatch {
+			return nil
+		}
+		after = events[len(events)-1].Seq
+	}
+	return nil
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
@@ -0,0 +1,196 @@
+package feed
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"go.uber.org/zap"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore - лента в памяти, ListenFeed будит Hub после каждого AppendFeed
+type memoryStore struct {
+	mu     sync.Mutex
+	events []storage.FeedEvent
+	cities map[string]storage.City
+	notify func()
+}
+
+func (m *memoryStore) AppendFeed(ctx context.Context, event storage.Event) error {
+	m.mu.Lock()
+	m.events = append(m.events, storage.FeedEvent{Seq: int64(len(m.events) + 1), City: m.cities[event.PvzId], Event: event})
+	notify := m.notify
+	m.mu.Unlock()
+	if notify != nil {
+		notify()
+	}
+	return nil
+}
+
+func (m *memoryStore) ReadFeed(ctx context.Context, filter storage.FeedFilter) ([]storage.FeedEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var res []storage.FeedEvent
+	for _, e := range m.events {
+		if e.Seq <= filter.After || (filter.Until != 0 && e.Seq > filter.Until) || len(res) == filter.Limit {
+			continue
+		}
+		if (filter.PvzId == "" || e.Event.PvzId == filter.PvzId) && (filter.City == "" || e.City == filter.City) {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+func (m *memoryStore) LastFeedSeq(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.events)), nil
+}
+
+func (m *memoryStore) ListenFeed(ctx context.Context, notify func()) error {
+	m.mu.Lock()
+	m.notify = notify
+	m.mu.Unlock()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (m *memoryStore) PurgeFeed(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func publish(t *testing.T, h *Hub, eventType, pvzId string) {
+	t.Helper()
+	event, err := storage.NewEvent(eventType, pvzId, struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receive(t *testing.T, sub *Subscription) storage.FeedEvent {
+	t.Helper()
+	select {
+	case e, ok := <-sub.C:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+	}
+	return storage.FeedEvent{}
+}
+
+func startHub(t *testing.T, store *memoryStore) *Hub {
+	t.Helper()
+	h := NewHub(store, time.Hour, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go h.Run(ctx, time.Hour)
+	// Hub начинает слушать ленту после того, как прочитал её позицию
+	for deadline := time.Now().Add(time.Second); ; {
+		store.mu.Lock()
+		listening := store.notify != nil
+		store.mu.Unlock()
+		if listening {
+			return h
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("hub did not start listening")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHubFiltersAndSkipsOtherEvents(t *testing.T) {
+	store := &memoryStore{cities: map[string]storage.City{"a": storage.Moscow, "b": storage.Kazan}}
+	h := startHub(t, store)
+
+	byPvz := h.Subscribe(Filter{PvzId: "a"})
+	defer byPvz.Close()
+	byCity := h.Subscribe(Filter{City: storage.Kazan})
+	defer byCity.Close()
+
+	publish(t, h, storage.EventPvzCreated, "a")
+	publish(t, h, storage.EventReceptionOpened, "a")
+	publish(t, h, storage.EventReceptionOpened, "b")
+	publish(t, h, storage.EventProductDeleted, "a")
+
+	if e := receive(t, byPvz); e.Event.Type != storage.EventReceptionOpened || e.Seq != 1 {
+		t.Errorf("first event of pvz a = %+v, pvz.created must not reach the feed", e)
+	}
+	if e := receive(t, byPvz); e.Event.Type != storage.EventProductDeleted {
+		t.Errorf("second event of pvz a = %+v", e)
+	}
+	if e := receive(t, byCity); e.Event.PvzId != "b" {
+		t.Errorf("event for Kazan = %+v", e)
+	}
+}
+
+func TestSubscriptionReplay(t *testing.T) {
+	store := &memoryStore{cities: map[string]storage.City{"a": storage.Moscow}}
+	for i := 0; i < 3; i++ {
+		event, _ := storage.NewEvent(storage.EventProductAdded, "a", struct{}{})
+		store.events = append(store.events, storage.FeedEvent{Seq: int64(i + 1), City: storage.Moscow, Event: event})
+	}
+	h := startHub(t, store)
+
+	sub := h.Subscribe(Filter{PvzId: "a"})
+	defer sub.Close()
+	publish(t, h, storage.EventReceptionClosed, "a")
+
+	var seqs []int64
+	err := sub.Replay(context.Background(), 1, func(e storage.FeedEvent) error {
+		seqs = append(seqs, e.Seq)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seqs = append(seqs, receive(t, sub).Seq)
+	if len(seqs) != 3 || seqs[0] != 2 || seqs[1] != 3 || seqs[2] != 4 {
+		t.Errorf("seqs = %v, want 2, 3 from replay and 4 live", seqs)
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	store := &memoryStore{cities: map[string]storage.City{"a": storage.Moscow}}
+	h := startHub(t, store)
+	sub := h.Subscribe(Filter{})
+	defer sub.Close()
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		publish(t, h, storage.EventProductAdded, "a")
+	}
+	// подписчик не читает, пока Hub не разослал всё
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		h.mu.Lock()
+		last := h.last
+		h.mu.Unlock()
+		if last == subscriberBuffer+1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("hub broadcast %d events", last)
+		}
+	}
+	deadline := time.After(time.Second)
+	for n := 0; ; n++ {
+		select {
+		case _, ok := <-sub.C:
+			if !ok {
+				if n > subscriberBuffer {
+					t.Errorf("received %d events, buffer is %d", n, subscriberBuffer)
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatal("slow subscriber was not dropped")
+		}
+	}
+}
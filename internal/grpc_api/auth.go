@@ -0,0 +1,30 @@
+package grpc_api
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+const authorizationKey = "authorization"
+
+// authenticate проверяет bearer-токен из метаданных authorization так же, как HTTP API,
+// и возвращает идентификатор пользователя
+func (s GrpcServer) authenticate(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(authorizationKey)
+	if len(values) == 0 {
+		return "", status.Error(codes.Unauthenticated, "token missed")
+	}
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok || token == "" {
+		return "", status.Error(codes.Unauthenticated, "invalid token header")
+	}
+	uuid, err := s.auth.Validate(token)
+	if err != nil {
+		return "", status.Error(codes.Unauthenticated, "invalid token header")
+	}
+	return uuid, nil
+}
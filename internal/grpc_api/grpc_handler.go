@@ -1,23 +1,44 @@
 package grpc_api
 
 import (
+	"avito_intr/internal/auth"
+	"avito_intr/internal/feed"
 	pb "avito_intr/internal/grpc_api/pvz_v1"
+	"avito_intr/internal/idempotency"
+	"avito_intr/internal/requestid"
 	"avito_intr/internal/storage"
 	"context"
+	"errors"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"time"
 )
 
+const (
+	defaultListLimit = 10
+	maxListLimit     = 100
+)
+
 type GrpcServer struct {
 	pb.UnimplementedPVZServiceServer
 	storage storage.Storage
-	logger  *zap.Logger
+	auth    auth.Authorization
+	// feed - источник событий для WatchPVZ, nil - вызов недоступен
+	feed *feed.Hub
+	// idempotencyTTL - сколько хранится первый ответ на вызов с idempotency-key
+	idempotencyTTL time.Duration
+	logger         *zap.Logger
 }
 
-func NewGrpcServer(storage storage.Storage, logger *zap.Logger) *GrpcServer {
-	return &GrpcServer{storage: storage, logger: logger}
+func NewGrpcServer(storage storage.Storage, authorizator auth.Authorization, hub *feed.Hub, idempotencyTTL time.Duration, logger *zap.Logger) *GrpcServer {
+	if idempotencyTTL <= 0 {
+		idempotencyTTL = idempotency.DefaultTTL
+	}
+	return &GrpcServer{storage: storage, auth: authorizator, feed: hub, idempotencyTTL: idempotencyTTL, logger: logger}
 }
 
 func (s GrpcServer) GetPVZList(ctx context.Context, request *pb.GetPVZListRequest) (*pb.GetPVZListResponse, error) {
@@ -32,29 +53,112 @@ func (s GrpcServer) GetPVZList(ctx context.Context, request *pb.GetPVZListReques
 	}
 
 	t := time.Now()
+	logger := requestid.Logger(ctx, s.logger)
 
-	info, err := s.storage.GetOnlyPvzList()
+	var (
+		info []storage.PvzInfo
+		next string
+		err  error
+	)
+	if proto.Equal(request, &pb.GetPVZListRequest{}) {
+		info, err = s.storage.GetOnlyPvzList(ctx)
+	} else {
+		var page *storage.PvzPage
+		page, err = s.storage.GetPvzList(ctx, listFilter(request))
+		if page != nil {
+			info, next = page.Items, page.NextCursor
+		}
+	}
 	if err != nil {
-		s.logger.Error("GRPC Request",
+		logger.Error("GRPC Request",
 			zap.String("method", request.String()),
 			zap.String("client_ip", ip),
 			zap.Duration("duration", time.Since(t)),
 			zap.Error(err),
 		)
-		return nil, err
+		var failed storage.ReceptionFailed
+		if errors.As(err, &failed) {
+			return nil, status.Error(codes.InvalidArgument, failed.Message)
+		}
+		// подробности уже в логе, клиенту их не отдаём
+		return nil, status.Error(codes.Internal, "internal error")
 	}
 
 	var ans []*pb.PVZ
 
 	for _, v := range info {
-		ans = append(ans, &pb.PVZ{Id: *v.PvzId, RegistrationDate: timestamppb.New(*v.RegistrationDate), City: string(v.City)})
+		ans = append(ans, newPVZ(v))
 	}
 
-	s.logger.Info("GRPC Request",
+	logger.Info("GRPC Request",
 		zap.String("method", method),
 		zap.String("client_ip", ip),
 		zap.Duration("duration", time.Since(t)),
 	)
 
-	return &pb.GetPVZListResponse{Pvzs: ans}, nil
+	return &pb.GetPVZListResponse{Pvzs: ans, NextCursor: next}, nil
+}
+
+func listFilter(request *pb.GetPVZListRequest) storage.PvzFilter {
+	limit := int(request.GetLimit())
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	filter := storage.PvzFilter{
+		Page:          1,
+		Limit:         limit,
+		Cursor:        request.GetCursor(),
+		City:          storage.City(request.GetCity()),
+		OpenReception: request.OpenReception,
+		ProductType:   request.GetProductType(),
+		Author:        request.GetAuthorId(),
+		Ascending:     request.GetAscending(),
+	}
+	if request.GetSort() == pb.PVZSort_PVZ_SORT_ACTIVITY {
+		filter.Sort = storage.SortByActivity
+	}
+	if request.StartDate != nil {
+		filter.StartDate = request.GetStartDate().AsTime().Format(time.RFC3339)
+	}
+	if request.EndDate != nil {
+		filter.EndDate = request.GetEndDate().AsTime().Format(time.RFC3339)
+	}
+	return filter
+}
+
+// storageError отдаёт клиенту текст только доменных ошибок хранилища, как и HTTP API
+func (s GrpcServer) storageError(ctx context.Context, err error) error {
+	var failed storage.ReceptionFailed
+	var login storage.LoginFailed
+	switch {
+	case errors.Is(err, storage.ErrVersionMismatch):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.As(err, &failed):
+		return status.Error(codes.InvalidArgument, failed.Message)
+	case errors.As(err, &login):
+		return status.Error(codes.PermissionDenied, login.Message)
+	}
+	return s.internalError(ctx, err)
+}
+
+func (s GrpcServer) internalError(ctx context.Context, err error) error {
+	requestid.Logger(ctx, s.logger).Error("internal error", zap.Error(err))
+	return status.Error(codes.Internal, "internal error")
+}
+
+func newPVZ(pvz storage.PvzInfo) *pb.PVZ {
+	return &pb.PVZ{Id: *pvz.PvzId, RegistrationDate: timestamppb.New(*pvz.RegistrationDate), City: string(pvz.City),
+		Version: pvz.Version}
+}
+
+func newReception(rec storage.ReceptionInfo) *pb.Reception {
+	st := pb.ReceptionStatus_RECEPTION_STATUS_IN_PROGRESS
+	if rec.Status == storage.Inactive {
+		st = pb.ReceptionStatus_RECEPTION_STATUS_CLOSED
+	}
+	return &pb.Reception{Id: rec.ReceptionId, DateTime: timestamppb.New(rec.DateTime), PvzId: rec.PvzId, Status: st,
+		Version: rec.Version}
 }
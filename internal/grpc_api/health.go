@@ -0,0 +1,39 @@
+package grpc_api
+
+import (
+	pb "avito_intr/internal/grpc_api/pvz_v1"
+	"avito_intr/internal/health"
+	"context"
+	"go.uber.org/zap"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"time"
+)
+
+// ReportHealth периодически прогоняет те же проверки, что и /readyz, и выставляет результат
+// в стандартном сервисе grpc.health.v1 для всего сервера и для PVZService.
+// После отмены ctx все сервисы переводятся в NOT_SERVING, чтобы балансировщик снял трафик.
+func ReportHealth(ctx context.Context, checker *health.Checker, hs *grpchealth.Server, interval time.Duration, logger *zap.Logger) {
+	update := func() {
+		status := healthpb.HealthCheckResponse_SERVING
+		if report := checker.Run(ctx); !report.Ready() {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+			logger.Warn("gRPC health check failed", zap.Any("checks", report.Checks))
+		}
+		hs.SetServingStatus("", status)
+		hs.SetServingStatus(pb.PVZService_ServiceDesc.ServiceName, status)
+	}
+
+	update()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			hs.Shutdown()
+			return
+		case <-ticker.C:
+			update()
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package grpc_api
+
+import (
+	"avito_intr/internal/idempotency"
+	"avito_intr/internal/storage"
+	"context"
+	"errors"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const idempotentContentType = "application/x-protobuf"
+
+// retryableCodes - ошибки, после которых повтор может завершиться иначе. Такой ответ не сохраняется,
+// и ключ освобождается для следующей попытки.
+var retryableCodes = map[codes.Code]bool{
+	codes.Canceled:          true,
+	codes.Unknown:           true,
+	codes.DeadlineExceeded:  true,
+	codes.ResourceExhausted: true,
+	codes.Aborted:           true,
+	codes.Internal:          true,
+	codes.Unavailable:       true,
+}
+
+// idempotentByKey выполняет call не больше одного раза на ключ. Повтор с тем же ключом и запросом
+// получает сохранённый ответ или ошибку первого вызова, replayed - ответ взят из сохранённого.
+func idempotentByKey[T proto.Message](ctx context.Context, s GrpcServer, caller, key, method string, req proto.Message, call func() (T, error)) (T, bool, error) {
+	var zero T
+	if caller == "" {
+		// у всех токенов из /dummyLogin пустой идентификатор, их ключи пересекались бы между клиентами
+		return zero, false, status.Error(codes.InvalidArgument, "idempotency keys require a token of a registered user, not one from /dummyLogin")
+	}
+	payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return zero, false, s.internalError(ctx, err)
+	}
+	stored, err := idempotency.Begin(ctx, s.storage, "grpc", caller, key, idempotency.Fingerprint(method, payload), s.idempotencyTTL)
+	switch {
+	case errors.Is(err, storage.ErrIdempotencyKeyInUse):
+		return zero, false, status.Error(codes.Aborted, "call with this idempotency-key is still in progress")
+	case errors.Is(err, storage.ErrIdempotencyKeyReused):
+		return zero, false, status.Error(codes.InvalidArgument, "idempotency-key was already used for a different request")
+	case err != nil:
+		return zero, false, s.internalError(ctx, err)
+	case stored != nil:
+		resp, err := replay[T](ctx, s, stored)
+		return resp, true, err
+	}
+
+	resp, callErr := call()
+	code := status.Code(callErr)
+	var result proto.Message = status.Convert(callErr).Proto()
+	if callErr == nil {
+		result, err = anypb.New(resp)
+	}
+	body, marshalErr := proto.Marshal(result)
+	keep := !retryableCodes[code] && err == nil && marshalErr == nil
+	idempotency.Finish(ctx, s.storage, caller, key,
+		storage.IdempotentResponse{Status: int(code), ContentType: idempotentContentType, Body: body}, keep, s.logger)
+	return resp, false, callErr
+}
+
+func replay[T proto.Message](ctx context.Context, s GrpcServer, stored *storage.IdempotentResponse) (T, error) {
+	var zero T
+	if codes.Code(stored.Status) != codes.OK {
+		var st spb.Status
+		if err := proto.Unmarshal(stored.Body, &st); err != nil {
+			return zero, s.internalError(ctx, err)
+		}
+		return zero, status.ErrorProto(&st)
+	}
+	var wrapped anypb.Any
+	if err := proto.Unmarshal(stored.Body, &wrapped); err != nil {
+		return zero, s.internalError(ctx, err)
+	}
+	msg, err := wrapped.UnmarshalNew()
+	if err != nil {
+		return zero, s.internalError(ctx, err)
+	}
+	resp, ok := msg.(T)
+	if !ok {
+		return zero, s.internalError(ctx, errors.New("stored response has type "+wrapped.TypeUrl))
+	}
+	return resp, nil
+}
@@ -0,0 +1,129 @@
+package grpc_api
+
+import (
+	pb "avito_intr/internal/grpc_api/pvz_v1"
+	"avito_intr/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"testing"
+	"time"
+)
+
+type stubAuth struct{}
+
+func (stubAuth) Generate(id, role string) (string, error) { return role, nil }
+
+func (stubAuth) Validate(token string) (string, error) {
+	// токены из /dummyLogin не содержат идентификатора пользователя
+	if token == "dummy" {
+		return "", nil
+	}
+	if token != "valid" {
+		return "", errors.New("invalid token")
+	}
+	return "33333333-3333-3333-3333-333333333333", nil
+}
+
+func (stubAuth) GenerateScoped(id, scope string, ttl time.Duration) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (stubAuth) ValidateScoped(token, scope string) (string, error) {
+	return "", errors.New("not supported")
+}
+
+type idempotencyEntry struct {
+	fingerprint string
+	resp        *storage.IdempotentResponse
+}
+
+// memoryStore хранит ключи в памяти и считает товары, реально добавленные хранилищем
+type memoryStore struct {
+	storage.Storage
+	mu       sync.Mutex
+	keys     map[string]idempotencyEntry
+	products int
+}
+
+func (m *memoryStore) AddProduct(ctx context.Context, pvz, author, product string, _ int64) (*storage.Product, error) {
+	if pvz == "closed" {
+		return nil, storage.ReceptionFailed{Message: "no open reception in pvz"}
+	}
+	m.products++
+	return &storage.Product{ProductId: fmt.Sprint(m.products), ProductType: product, DateTime: time.Now()}, nil
+}
+
+func (m *memoryStore) BeginIdempotent(ctx context.Context, caller, key, fingerprint string, ttl time.Duration) (*storage.IdempotentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.keys[caller+"/"+key]
+	switch {
+	case !ok:
+		m.keys[caller+"/"+key] = idempotencyEntry{fingerprint: fingerprint}
+		return nil, nil
+	case entry.fingerprint != fingerprint:
+		return nil, storage.ErrIdempotencyKeyReused
+	case entry.resp == nil:
+		return nil, storage.ErrIdempotencyKeyInUse
+	}
+	return entry.resp, nil
+}
+
+func (m *memoryStore) CompleteIdempotent(ctx context.Context, caller, key string, resp storage.IdempotentResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.keys[caller+"/"+key]
+	entry.resp = &resp
+	m.keys[caller+"/"+key] = entry
+	return nil
+}
+
+func TestIdempotentByKey(t *testing.T) {
+	store := &memoryStore{keys: map[string]idempotencyEntry{}}
+	s := NewGrpcServer(store, stubAuth{}, nil, time.Hour, zap.NewNop())
+	ctx := context.Background()
+	add := func(pvz string) func() (*pb.Product, error) {
+		return func() (*pb.Product, error) {
+			product, err := store.AddProduct(ctx, pvz, "author", storage.Shoes, 0)
+			if err != nil {
+				return nil, s.storageError(ctx, err)
+			}
+			return newProduct(*product), nil
+		}
+	}
+	req := &pb.Scan{ScanId: "k1", Type: storage.Shoes}
+
+	first, replayed, err := idempotentByKey(ctx, *s, "author", "k1", "ScanSession/open", req, add("open"))
+	if err != nil || replayed {
+		t.Fatalf("first call: replayed %v, err %v", replayed, err)
+	}
+	retry, replayed, err := idempotentByKey(ctx, *s, "author", "k1", "ScanSession/open", req, add("open"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !replayed || retry.GetId() != first.GetId() || store.products != 1 {
+		t.Errorf("retry = %v, replayed %v, products added %d, want the stored response", retry, replayed, store.products)
+	}
+
+	closed := &pb.Scan{ScanId: "k2", Type: storage.Shoes}
+	_, _, err = idempotentByKey(ctx, *s, "author", "k2", "ScanSession/closed", closed, add("closed"))
+	_, _, retryErr := idempotentByKey(ctx, *s, "author", "k2", "ScanSession/closed", closed, add("closed"))
+	if status.Code(retryErr) != codes.InvalidArgument || status.Convert(retryErr).Message() != status.Convert(err).Message() {
+		t.Errorf("retry error = %v, want the stored error %v", retryErr, err)
+	}
+
+	other := &pb.Scan{ScanId: "k1", Type: storage.Clothes}
+	if _, _, err := idempotentByKey(ctx, *s, "author", "k1", "ScanSession/open", other, add("open")); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("key reused for other request = %v", err)
+	}
+
+	// вызывающие с токенами из /dummyLogin неразличимы, их ключи не принимаются
+	if _, _, err := idempotentByKey(ctx, *s, "", "k3", "ScanSession/open", req, add("open")); status.Code(err) != codes.InvalidArgument || store.products != 1 {
+		t.Errorf("key from dummy token = %v, products added %d", err, store.products)
+	}
+}
@@ -0,0 +1,65 @@
+package grpc_api
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// Интервалы совпадают с http_request_duration_seconds, чтобы оба транспорта были на одних дашбордах
+var latencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+var grpcRequestsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "grpc_server_handled_total",
+		Help: "Total number of gRPC calls completed on the server",
+	},
+	[]string{"grpc_method", "grpc_type", "grpc_code"},
+)
+
+var grpcRequestDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "grpc_server_handling_seconds",
+		Help:    "gRPC call duration in seconds",
+		Buckets: latencyBuckets,
+	},
+	[]string{"grpc_method", "grpc_type", "grpc_code"},
+)
+
+func init() {
+	prometheus.MustRegister(grpcRequestsTotal)
+	prometheus.MustRegister(grpcRequestDuration)
+}
+
+func observe(method, kind string, start time.Time, err error) {
+	code := status.Code(err).String()
+	grpcRequestsTotal.WithLabelValues(method, kind, code).Inc()
+	grpcRequestDuration.WithLabelValues(method, kind, code).Observe(time.Since(start).Seconds())
+}
+
+func UnaryMetricsInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observe(info.FullMethod, "unary", start, err)
+		return resp, err
+	}
+}
+
+func StreamMetricsInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		kind := "server_stream"
+		switch {
+		case info.IsClientStream && info.IsServerStream:
+			kind = "bidi_stream"
+		case info.IsClientStream:
+			kind = "client_stream"
+		}
+		observe(info.FullMethod, kind, start, err)
+		return err
+	}
+}
@@ -0,0 +1,126 @@
+package grpc_api
+
+import (
+	"avito_intr/internal/auth"
+	pb "avito_intr/internal/grpc_api/pvz_v1"
+	"avito_intr/internal/ratelimit"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	clientIDKey     = "x-client-id"
+	forwardedForKey = "x-forwarded-for"
+	retryAfterKey   = "retry-after"
+)
+
+// rateLimitGroups относит методы к тем же группам лимитов, что и операции HTTP API
+var rateLimitGroups = map[string]string{
+	pb.PVZService_GetPVZList_FullMethodName:  ratelimit.GroupList,
+	pb.PVZService_WatchPVZ_FullMethodName:    ratelimit.GroupRead,
+	pb.PVZService_ScanSession_FullMethodName: ratelimit.GroupWrite,
+}
+
+type rateLimiter struct {
+	limiter *ratelimit.Limiter
+	auth    auth.Authorization
+	proxies ratelimit.TrustedProxies
+}
+
+// keys возвращает ключи вызова в порядке проверки: сначала клиент и IP, затем пользователь.
+// Пользователь берётся только из верного токена, неверный токен отклоняет сам метод.
+func (l rateLimiter) keys(ctx context.Context) []ratelimit.Keys {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var ip string
+	if p, ok := peer.FromContext(ctx); ok {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		ip = l.proxies.ClientIP(ip, md.Get(forwardedForKey))
+	}
+	var client string
+	if values := md.Get(clientIDKey); len(values) > 0 {
+		client = ratelimit.ClientID(values[0])
+	}
+	keys := []ratelimit.Keys{{Client: client, IP: ip}}
+	if values := md.Get(authorizationKey); len(values) > 0 {
+		if token, ok := strings.CutPrefix(values[0], "Bearer "); ok {
+			if user, err := l.auth.Validate(token); err == nil && user != "" {
+				keys = append(keys, ratelimit.Keys{User: user})
+			}
+		}
+	}
+	return keys
+}
+
+// allow берёт токены по всем ключам вызова. При отказе время до следующего токена уходит в трейлер retry-after.
+func (l rateLimiter) allow(ctx context.Context, group string, keys []ratelimit.Keys, setTrailer func(metadata.MD)) error {
+	for _, k := range keys {
+		d, limited := l.limiter.Allow(ctx, group, k)
+		if !limited || d.Allowed {
+			continue
+		}
+		setTrailer(metadata.Pairs(retryAfterKey, strconv.Itoa(max(int(math.Ceil(d.RetryAfter.Seconds())), 1))))
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded for "+d.Scope)
+	}
+	return nil
+}
+
+// UnaryRateLimitInterceptor ограничивает вызовы по тем же группам и ключам, что и HTTP API. nil limiter - без ограничений.
+func UnaryRateLimitInterceptor(limiter *ratelimit.Limiter, authorizator auth.Authorization, proxies ratelimit.TrustedProxies) grpc.UnaryServerInterceptor {
+	l := rateLimiter{limiter: limiter, auth: authorizator, proxies: proxies}
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		group, ok := rateLimitGroups[info.FullMethod]
+		if limiter == nil || !ok {
+			return handler(ctx, req)
+		}
+		setTrailer := func(md metadata.MD) { _ = grpc.SetTrailer(ctx, md) }
+		if err := l.allow(ctx, group, l.keys(ctx), setTrailer); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamRateLimitInterceptor берёт токен при открытии серверного потока. Поток с командами клиента (ScanSession)
+// расходует токен на каждую команду: превысивший лимит поток завершается с RESOURCE_EXHAUSTED.
+func StreamRateLimitInterceptor(limiter *ratelimit.Limiter, authorizator auth.Authorization, proxies ratelimit.TrustedProxies) grpc.StreamServerInterceptor {
+	l := rateLimiter{limiter: limiter, auth: authorizator, proxies: proxies}
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		group, ok := rateLimitGroups[info.FullMethod]
+		if limiter == nil || !ok {
+			return handler(srv, ss)
+		}
+		keys := l.keys(ss.Context())
+		if info.IsClientStream {
+			return handler(srv, &limitedStream{ServerStream: ss, limiter: l, group: group, keys: keys})
+		}
+		if err := l.allow(ss.Context(), group, keys, ss.SetTrailer); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+type limitedStream struct {
+	grpc.ServerStream
+	limiter rateLimiter
+	group   string
+	keys    []ratelimit.Keys
+}
+
+func (s *limitedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.limiter.allow(s.Context(), s.group, s.keys, s.SetTrailer)
+}
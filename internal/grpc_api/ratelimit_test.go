@@ -0,0 +1,89 @@
+package grpc_api
+
+import (
+	pb "avito_intr/internal/grpc_api/pvz_v1"
+	"avito_intr/internal/ratelimit"
+	"context"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"testing"
+)
+
+func peerContext(ip string, pairs ...string) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000}})
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(pairs...))
+}
+
+func TestUnaryRateLimit(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), map[string]ratelimit.Group{
+		ratelimit.GroupList: {User: ratelimit.Limit{Rate: 0.1, Burst: 1}, IP: ratelimit.Limit{Rate: 0.1, Burst: 2}},
+	}, zap.NewNop())
+	interceptor := UnaryRateLimitInterceptor(limiter, stubAuth{}, nil)
+	info := &grpc.UnaryServerInfo{FullMethod: pb.PVZService_GetPVZList_FullMethodName}
+	handler := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+	call := func(ctx context.Context) error {
+		_, err := interceptor(ctx, nil, info, handler)
+		return err
+	}
+
+	if err := call(peerContext("203.0.113.1", "authorization", "Bearer valid")); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	// ведро пользователя пусто, хотя у IP ещё есть токен
+	err := call(peerContext("203.0.113.2", "authorization", "Bearer valid"))
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("second call of the user: %v, want ResourceExhausted", err)
+	}
+	// без токена считается только IP
+	for i, want := range []codes.Code{codes.OK, codes.OK, codes.ResourceExhausted} {
+		if err := call(peerContext("203.0.113.3")); status.Code(err) != want {
+			t.Errorf("anonymous call %d: %v, want %v", i+1, err, want)
+		}
+	}
+	// методы вне групп не ограничены
+	if _, err := interceptor(peerContext("203.0.113.3"), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, handler); err != nil {
+		t.Errorf("health check: %v", err)
+	}
+}
+
+type recvStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	trailer metadata.MD
+}
+
+func (s *recvStream) Context() context.Context  { return s.ctx }
+func (s *recvStream) RecvMsg(any) error         { return nil }
+func (s *recvStream) SetTrailer(md metadata.MD) { s.trailer = metadata.Join(s.trailer, md) }
+
+func TestStreamRateLimitPerCommand(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), map[string]ratelimit.Group{
+		ratelimit.GroupWrite: {User: ratelimit.Limit{Rate: 0.1, Burst: 2}},
+	}, zap.NewNop())
+	interceptor := StreamRateLimitInterceptor(limiter, stubAuth{}, nil)
+	info := &grpc.StreamServerInfo{FullMethod: pb.PVZService_ScanSession_FullMethodName, IsClientStream: true, IsServerStream: true}
+	stream := &recvStream{ctx: peerContext("203.0.113.1", "authorization", "Bearer valid")}
+
+	var codesSeen []codes.Code
+	err := interceptor(nil, stream, info, func(srv any, ss grpc.ServerStream) error {
+		for i := 0; i < 3; i++ {
+			err := ss.RecvMsg(&pb.ScanCommand{})
+			codesSeen = append(codesSeen, status.Code(err))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if status.Code(err) != codes.ResourceExhausted || len(codesSeen) != 3 || codesSeen[1] != codes.OK {
+		t.Fatalf("stream ended with %v after %v, want the third command limited", err, codesSeen)
+	}
+	if got := stream.trailer.Get(retryAfterKey); len(got) != 1 || got[0] != "10" {
+		t.Errorf("retry-after trailer = %v", got)
+	}
+}
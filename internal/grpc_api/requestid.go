@@ -0,0 +1,45 @@
+package grpc_api
+
+import (
+	"avito_intr/internal/requestid"
+	"context"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// withRequestID берёт x-request-id из метаданных или генерирует новый
+// и кладёт в контекст вместе с логгером запроса
+func withRequestID(ctx context.Context, logger *zap.Logger) (context.Context, string) {
+	var incoming string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(requestid.MetadataKey); len(v) > 0 {
+			incoming = v[0]
+		}
+	}
+	id := requestid.FromOrNew(incoming)
+	ctx, _ = requestid.With(ctx, id, logger)
+	return ctx, id
+}
+
+// UnaryRequestIDInterceptor возвращает идентификатор запроса клиенту в заголовке ответа x-request-id,
+// в том числе при ошибке
+func UnaryRequestIDInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, id := withRequestID(ctx, logger)
+		if err := grpc.SetHeader(ctx, metadata.Pairs(requestid.MetadataKey, id)); err != nil {
+			requestid.Logger(ctx, logger).Warn("failed to set request id header", zap.Error(err))
+		}
+		return handler(ctx, req)
+	}
+}
+
+func StreamRequestIDInterceptor(logger *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, id := withRequestID(ss.Context(), logger)
+		if err := ss.SetHeader(metadata.Pairs(requestid.MetadataKey, id)); err != nil {
+			requestid.Logger(ctx, logger).Warn("failed to set request id header", zap.Error(err))
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
@@ -0,0 +1,190 @@
+package grpc_api
+
+import (
+	pb "avito_intr/internal/grpc_api/pvz_v1"
+	"avito_intr/internal/idempotency"
+	"avito_intr/internal/storage"
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"strconv"
+)
+
+// ScanSession выполняет команды терминала по очереди теми же методами хранилища, что и HTTP API,
+// поэтому действуют те же правила: одна открытая приёмка на ПВЗ, удаление товаров только из открытой приёмки.
+func (s GrpcServer) ScanSession(stream grpc.BidiStreamingServer[pb.ScanCommand, pb.ScanResult]) error {
+	ctx := stream.Context()
+	author, err := s.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+	sessionKey, err := sessionIdempotencyKey(ctx, author)
+	if err != nil {
+		return err
+	}
+
+	first, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	start := first.GetStart()
+	if start == nil {
+		return status.Error(codes.InvalidArgument, "first command of a scan session must be start")
+	}
+	pvzId := start.GetPvzId()
+	reception, err := s.attachReception(ctx, author, pvzId)
+	if err != nil {
+		// без приёмки сессии нет: ответ на start отклонён, и поток завершается
+		return stream.Send(s.rejectScan(ctx, first.GetCommandId(), err))
+	}
+	if err := stream.Send(&pb.ScanResult{CommandId: first.GetCommandId(),
+		Result: &pb.ScanResult_Reception{Reception: newReception(*reception)}}); err != nil {
+		return err
+	}
+
+	for {
+		cmd, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		res := s.runCommand(ctx, author, pvzId, sessionKey, cmd)
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+		if cmd.GetClose() != nil && res.GetRejected() == nil {
+			return nil
+		}
+	}
+}
+
+// sessionIdempotencyKey читает ключ сессии из метаданных idempotency-key, "" - ключа нет
+func sessionIdempotencyKey(ctx context.Context, author string) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	keys := md.Get(idempotency.MetadataKey)
+	if len(keys) == 0 {
+		return "", nil
+	}
+	if !idempotency.ValidKey(keys[0]) {
+		return "", status.Errorf(codes.InvalidArgument, "%s must be 1..%d printable ASCII characters",
+			idempotency.MetadataKey, idempotency.MaxKeyLength)
+	}
+	if author == "" {
+		return "", status.Errorf(codes.InvalidArgument, "%s requires a token of a registered user, not one from /dummyLogin",
+			idempotency.MetadataKey)
+	}
+	return keys[0], nil
+}
+
+// commandKey - ключ повтора команды, "" - команда выполняется без него. Скан с scan_id узнаётся по scan_id,
+// с ключом сессии ключ получают и остальные изменяющие команды - по command_id, который терминал
+// повторяет, когда после обрыва заново отправляет сессию с тем же idempotency-key.
+func commandKey(sessionKey string, cmd *pb.ScanCommand) string {
+	scanId := cmd.GetScan().GetScanId()
+	switch {
+	case scanId != "" && sessionKey == "":
+		return "scan/" + scanId
+	case scanId != "":
+		return sessionKey + "/scan/" + scanId
+	case sessionKey != "" && cmd.GetStart() == nil:
+		return sessionKey + "/" + strconv.FormatInt(cmd.GetCommandId(), 10)
+	}
+	return ""
+}
+
+// runCommand выполняет команду после start. Команда с ключом выполняется не больше одного раза:
+// повтор получает первый ответ с duplicate = true.
+func (s GrpcServer) runCommand(ctx context.Context, author, pvzId, sessionKey string, cmd *pb.ScanCommand) *pb.ScanResult {
+	if scanId := cmd.GetScan().GetScanId(); scanId != "" && !idempotency.ValidKey(scanId) {
+		return s.rejectScan(ctx, cmd.GetCommandId(), status.Errorf(codes.InvalidArgument,
+			"scan_id must be 1..%d printable ASCII characters", idempotency.MaxKeyLength))
+	}
+	execute := func() (*pb.ScanResult, error) {
+		return s.executeCommand(ctx, author, pvzId, cmd)
+	}
+	var (
+		res       *pb.ScanResult
+		duplicate bool
+		err       error
+	)
+	if key := commandKey(sessionKey, cmd); key != "" {
+		// повтор узнаётся по ПВЗ и содержимому команды: command_id после переподключения может быть другим
+		req := proto.Clone(cmd).(*pb.ScanCommand)
+		req.CommandId = 0
+		res, duplicate, err = idempotentByKey(ctx, s, author, key, "ScanSession/"+pvzId, req, execute)
+	} else {
+		res, err = execute()
+	}
+	if err != nil {
+		return s.rejectScan(ctx, cmd.GetCommandId(), err)
+	}
+	res.CommandId, res.Duplicate = cmd.GetCommandId(), duplicate
+	return res
+}
+
+// executeCommand возвращает ошибки хранилища уже переведёнными в статусы gRPC: от кода зависит,
+// сохранится ли ответ для повтора
+func (s GrpcServer) executeCommand(ctx context.Context, author, pvzId string, cmd *pb.ScanCommand) (*pb.ScanResult, error) {
+	switch c := cmd.GetCommand().(type) {
+	case *pb.ScanCommand_Scan:
+		if !storage.ValidProductType(c.Scan.GetType()) {
+			return nil, status.Error(codes.InvalidArgument, "unknown product type")
+		}
+		product, err := s.storage.AddProduct(ctx, pvzId, author, c.Scan.GetType(), c.Scan.GetExpectedVersion())
+		if err != nil {
+			return nil, s.storageError(ctx, err)
+		}
+		return &pb.ScanResult{Result: &pb.ScanResult_Product{Product: newProduct(*product)}}, nil
+	case *pb.ScanCommand_Undo:
+		version, err := s.storage.DeleteLastProduct(ctx, pvzId, c.Undo.GetExpectedVersion())
+		if err != nil {
+			return nil, s.storageError(ctx, err)
+		}
+		return &pb.ScanResult{Result: &pb.ScanResult_ReceptionVersion{ReceptionVersion: version}}, nil
+	case *pb.ScanCommand_Close:
+		reception, err := s.storage.CloseLastReception(ctx, pvzId, c.Close.GetExpectedVersion())
+		if err != nil {
+			return nil, s.storageError(ctx, err)
+		}
+		return &pb.ScanResult{Result: &pb.ScanResult_Reception{Reception: newReception(*reception)}}, nil
+	case *pb.ScanCommand_Start:
+		return nil, status.Error(codes.FailedPrecondition, "scan session is already started")
+	}
+	return nil, status.Error(codes.InvalidArgument, "unknown command")
+}
+
+// attachReception возвращает открытую приёмку ПВЗ, открывая её, если открытой нет
+func (s GrpcServer) attachReception(ctx context.Context, author, pvzId string) (*storage.ReceptionInfo, error) {
+	for {
+		reception, err := s.storage.GetOpenReception(ctx, pvzId)
+		if err != nil || reception != nil {
+			return reception, err
+		}
+		reception, err = s.storage.OpenReception(ctx, author, pvzId, 0)
+		// приёмку успел открыть другой терминал, подключаемся к ней
+		if errors.Is(err, storage.ErrReceptionAlreadyOpen) {
+			continue
+		}
+		return reception, err
+	}
+}
+
+// rejectScan превращает ошибку команды в ответ: сессия продолжается
+func (s GrpcServer) rejectScan(ctx context.Context, commandId int64, err error) *pb.ScanResult {
+	if _, ok := status.FromError(err); !ok {
+		err = s.storageError(ctx, err)
+	}
+	st := status.Convert(err)
+	return &pb.ScanResult{CommandId: commandId,
+		Result: &pb.ScanResult_Rejected{Rejected: &pb.ScanRejection{Code: uint32(st.Code()), Message: st.Message()}}}
+}
@@ -0,0 +1,258 @@
+package grpc_api
+
+import (
+	pb "avito_intr/internal/grpc_api/pvz_v1"
+	"avito_intr/internal/idempotency"
+	"avito_intr/internal/storage"
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"testing"
+	"time"
+)
+
+// receptionMemory - одна приёмка ПВЗ в памяти с правилами хранилища
+type receptionMemory struct {
+	*memoryStore
+	reception *storage.ReceptionInfo
+	products  []string
+}
+
+func (r *receptionMemory) GetOpenReception(ctx context.Context, pvzId string) (*storage.ReceptionInfo, error) {
+	if r.reception == nil || r.reception.Status != storage.Active {
+		return nil, nil
+	}
+	return r.reception, nil
+}
+
+func (r *receptionMemory) OpenReception(ctx context.Context, author, pvz string, _ int64) (*storage.ReceptionInfo, error) {
+	if r.reception != nil && r.reception.Status == storage.Active {
+		return nil, storage.ErrReceptionAlreadyOpen
+	}
+	r.reception = &storage.ReceptionInfo{ReceptionId: "r1", DateTime: time.Now(), PvzId: pvz, Status: storage.Active, Version: 1}
+	return r.reception, nil
+}
+
+// checkVersion сверяет ожидаемую версию открытой приёмки, как хранилище
+func (r *receptionMemory) checkVersion(expected int64) error {
+	if expected != 0 && (r.reception == nil || r.reception.Status != storage.Active || r.reception.Version != expected) {
+		return storage.ErrVersionMismatch
+	}
+	return nil
+}
+
+func (r *receptionMemory) AddProduct(ctx context.Context, pvz, author, product string, expected int64) (*storage.Product, error) {
+	if err := r.checkVersion(expected); err != nil {
+		return nil, err
+	}
+	if r.reception == nil || r.reception.Status != storage.Active {
+		return nil, storage.ReceptionFailed{Message: "no open reception in pvz"}
+	}
+	r.reception.Version++
+	id := fmt.Sprint("p", r.reception.Version)
+	r.products = append(r.products, id)
+	return &storage.Product{ProductId: id, ProductType: product, ReceptionId: r.reception.ReceptionId,
+		ReceptionVersion: r.reception.Version}, nil
+}
+
+func (r *receptionMemory) DeleteLastProduct(ctx context.Context, pvz string, expected int64) (int64, error) {
+	if err := r.checkVersion(expected); err != nil {
+		return 0, err
+	}
+	if len(r.products) == 0 {
+		return 0, storage.ReceptionFailed{Message: "reception has no products"}
+	}
+	r.products = r.products[:len(r.products)-1]
+	r.reception.Version++
+	return r.reception.Version, nil
+}
+
+func (r *receptionMemory) CloseLastReception(ctx context.Context, pvz string, expected int64) (*storage.ReceptionInfo, error) {
+	if err := r.checkVersion(expected); err != nil {
+		return nil, err
+	}
+	r.reception.Status = storage.Inactive
+	return r.reception, nil
+}
+
+// scanStream отдаёт серверу команды по очереди и собирает ответы
+type scanStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	commands []*pb.ScanCommand
+	results  []*pb.ScanResult
+}
+
+func (s *scanStream) Context() context.Context { return s.ctx }
+
+func (s *scanStream) Recv() (*pb.ScanCommand, error) {
+	if len(s.commands) == 0 {
+		return nil, io.EOF
+	}
+	cmd := s.commands[0]
+	s.commands = s.commands[1:]
+	return cmd, nil
+}
+
+func (s *scanStream) Send(res *pb.ScanResult) error {
+	s.results = append(s.results, res)
+	return nil
+}
+
+func scanContext() context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer valid"))
+}
+
+func scan(id int64, scanId, productType string) *pb.ScanCommand {
+	return &pb.ScanCommand{CommandId: id, Command: &pb.ScanCommand_Scan{Scan: &pb.Scan{ScanId: scanId, Type: productType}}}
+}
+
+func TestScanSession(t *testing.T) {
+	store := &receptionMemory{memoryStore: &memoryStore{keys: map[string]idempotencyEntry{}}}
+	s := NewGrpcServer(store, stubAuth{}, nil, time.Hour, zap.NewNop())
+	stream := &scanStream{ctx: scanContext(), commands: []*pb.ScanCommand{
+		{CommandId: 1, Command: &pb.ScanCommand_Start{Start: &pb.StartScan{PvzId: watchedPvz}}},
+		scan(2, "s1", storage.Shoes),
+		scan(3, "s1", storage.Shoes),
+		scan(4, "s2", "мебель"),
+		{CommandId: 5, Command: &pb.ScanCommand_Undo{Undo: &pb.UndoScan{}}},
+		{CommandId: 6, Command: &pb.ScanCommand_Undo{Undo: &pb.UndoScan{}}},
+		{CommandId: 7, Command: &pb.ScanCommand_Close{Close: &pb.CloseScan{}}},
+		scan(8, "s3", storage.Shoes),
+	}}
+	if err := s.ScanSession(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	res := stream.results
+	if len(res) != 7 {
+		t.Fatalf("got %d results, want 7: the session ends after close", len(res))
+	}
+	for i, r := range res {
+		if r.GetCommandId() != int64(i+1) {
+			t.Errorf("result %d answers command %d", i, r.GetCommandId())
+		}
+	}
+	if res[0].GetReception().GetId() != "r1" || res[0].GetReception().GetStatus() != pb.ReceptionStatus_RECEPTION_STATUS_IN_PROGRESS {
+		t.Errorf("start = %v", res[0])
+	}
+	if res[1].GetProduct() == nil || res[1].GetDuplicate() {
+		t.Errorf("scan = %v", res[1])
+	}
+	if res[2].GetProduct().GetId() != res[1].GetProduct().GetId() || !res[2].GetDuplicate() {
+		t.Errorf("repeated scan = %v", res[2])
+	}
+	if codes.Code(res[3].GetRejected().GetCode()) != codes.InvalidArgument {
+		t.Errorf("scan of unknown type = %v", res[3])
+	}
+	// приёмка открыта с версией 1, один товар добавлен и удалён: повтор скана товар не добавил
+	if res[4].GetReceptionVersion() != 3 {
+		t.Errorf("undo = %v, want reception version 3", res[4])
+	}
+	if rejected := res[5].GetRejected(); codes.Code(rejected.GetCode()) != codes.InvalidArgument || rejected.GetMessage() != "reception has no products" {
+		t.Errorf("undo of empty reception = %v", res[5])
+	}
+	if res[6].GetReception().GetStatus() != pb.ReceptionStatus_RECEPTION_STATUS_CLOSED {
+		t.Errorf("close = %v", res[6])
+	}
+}
+
+func TestScanSessionExpectedVersion(t *testing.T) {
+	open := &storage.ReceptionInfo{ReceptionId: "r0", DateTime: time.Now(), PvzId: watchedPvz, Status: storage.Active, Version: 5}
+	store := &receptionMemory{memoryStore: &memoryStore{keys: map[string]idempotencyEntry{}}, reception: open}
+	s := NewGrpcServer(store, stubAuth{}, nil, time.Hour, zap.NewNop())
+	stream := &scanStream{ctx: scanContext(), commands: []*pb.ScanCommand{
+		{CommandId: 1, Command: &pb.ScanCommand_Start{Start: &pb.StartScan{PvzId: watchedPvz}}},
+		{CommandId: 2, Command: &pb.ScanCommand_Scan{Scan: &pb.Scan{Type: storage.Shoes, ExpectedVersion: 5}}},
+		// другой терминал успел изменить приёмку: версия 5 устарела
+		{CommandId: 3, Command: &pb.ScanCommand_Undo{Undo: &pb.UndoScan{ExpectedVersion: 5}}},
+		{CommandId: 4, Command: &pb.ScanCommand_Close{Close: &pb.CloseScan{ExpectedVersion: 4}}},
+		{CommandId: 5, Command: &pb.ScanCommand_Close{Close: &pb.CloseScan{ExpectedVersion: 6}}},
+	}}
+	if err := s.ScanSession(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	res := stream.results
+	if len(res) != 5 {
+		t.Fatalf("got %d results, want 5", len(res))
+	}
+	if res[1].GetProduct().GetReceptionVersion() != 6 {
+		t.Errorf("scan with current version = %v", res[1])
+	}
+	for _, r := range res[2:4] {
+		if codes.Code(r.GetRejected().GetCode()) != codes.FailedPrecondition {
+			t.Errorf("command %d with stale version = %v, want FAILED_PRECONDITION", r.GetCommandId(), r)
+		}
+	}
+	if res[4].GetReception().GetStatus() != pb.ReceptionStatus_RECEPTION_STATUS_CLOSED {
+		t.Errorf("close with current version = %v", res[4])
+	}
+}
+
+func TestScanSessionIdempotencyKey(t *testing.T) {
+	store := &receptionMemory{memoryStore: &memoryStore{keys: map[string]idempotencyEntry{}}}
+	s := NewGrpcServer(store, stubAuth{}, nil, time.Hour, zap.NewNop())
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs("authorization", "Bearer valid", idempotency.MetadataKey, "terminal-7/session-1"))
+	session := func() []*pb.ScanResult {
+		stream := &scanStream{ctx: ctx, commands: []*pb.ScanCommand{
+			{CommandId: 1, Command: &pb.ScanCommand_Start{Start: &pb.StartScan{PvzId: watchedPvz}}},
+			scan(2, "", storage.Shoes),
+			scan(3, "", storage.Clothes),
+			{CommandId: 4, Command: &pb.ScanCommand_Undo{Undo: &pb.UndoScan{}}},
+		}}
+		if err := s.ScanSession(stream); err != nil {
+			t.Fatal(err)
+		}
+		return stream.results
+	}
+
+	first := session()
+	// терминал потерял ответы и отправил сессию заново с тем же ключом
+	retry := session()
+	if len(store.products) != 1 || store.reception.Version != 4 {
+		t.Fatalf("products = %v, version %d: the retried session must not change the reception", store.products, store.reception.Version)
+	}
+	for i, r := range retry[1:] {
+		if !r.GetDuplicate() || !proto.Equal(r.GetProduct(), first[i+1].GetProduct()) ||
+			r.GetReceptionVersion() != first[i+1].GetReceptionVersion() {
+			t.Errorf("retried command %d = %v, want duplicate of %v", r.GetCommandId(), r, first[i+1])
+		}
+	}
+
+	dummy := &scanStream{ctx: metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs("authorization", "Bearer dummy", idempotency.MetadataKey, "k"))}
+	if err := s.ScanSession(dummy); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("session key with a dummy token: %v, want InvalidArgument", err)
+	}
+}
+
+func TestScanSessionAttachesToOpenReception(t *testing.T) {
+	open := &storage.ReceptionInfo{ReceptionId: "r0", DateTime: time.Now(), PvzId: watchedPvz, Status: storage.Active, Version: 5}
+	store := &receptionMemory{memoryStore: &memoryStore{keys: map[string]idempotencyEntry{}}, reception: open}
+	s := NewGrpcServer(store, stubAuth{}, nil, time.Hour, zap.NewNop())
+	stream := &scanStream{ctx: scanContext(), commands: []*pb.ScanCommand{
+		{CommandId: 1, Command: &pb.ScanCommand_Start{Start: &pb.StartScan{PvzId: watchedPvz}}},
+	}}
+	if err := s.ScanSession(stream); err != nil {
+		t.Fatal(err)
+	}
+	if len(stream.results) != 1 || stream.results[0].GetReception().GetId() != "r0" {
+		t.Errorf("results = %v", stream.results)
+	}
+}
+
+func TestScanSessionRequiresStart(t *testing.T) {
+	s := NewGrpcServer(&receptionMemory{}, stubAuth{}, nil, time.Hour, zap.NewNop())
+	stream := &scanStream{ctx: scanContext(), commands: []*pb.ScanCommand{scan(1, "", storage.Shoes)}}
+	if err := s.ScanSession(stream); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("err = %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,146 @@
+package grpc_api
+
+import (
+	"avito_intr/internal/feed"
+	pb "avito_intr/internal/grpc_api/pvz_v1"
+	"avito_intr/internal/storage"
+	"encoding/json"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+)
+
+// WatchPVZ подписывается на ленту до чтения снимка, поэтому изменения между ними не теряются,
+// а события, которые уже есть в снимке, отбрасывает receptionWatch.
+func (s GrpcServer) WatchPVZ(request *pb.WatchPVZRequest, stream grpc.ServerStreamingServer[pb.PVZEvent]) error {
+	ctx := stream.Context()
+	if _, err := s.authenticate(ctx); err != nil {
+		return err
+	}
+	if s.feed == nil {
+		return status.Error(codes.Unimplemented, "event feed is disabled")
+	}
+
+	sub := s.feed.Subscribe(feed.Filter{PvzId: request.GetPvzId()})
+	defer sub.Close()
+
+	reception, err := s.storage.GetOpenReception(ctx, request.GetPvzId())
+	if err != nil {
+		return s.storageError(ctx, err)
+	}
+	watch := newReceptionWatch(reception)
+	if err := stream.Send(newSnapshot(reception)); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case e, ok := <-sub.C:
+			// лента закрыта: клиент отстал или сервис останавливается
+			if !ok {
+				return status.Error(codes.Unavailable, "event feed closed, watch again to get a fresh snapshot")
+			}
+			msg, err := watch.apply(e)
+			if err != nil {
+				return s.internalError(ctx, err)
+			}
+			if msg == nil {
+				continue
+			}
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func newSnapshot(reception *storage.ReceptionInfo) *pb.PVZEvent {
+	snapshot := &pb.ReceptionSnapshot{}
+	if reception != nil {
+		snapshot.Reception = newReception(*reception)
+		for _, p := range reception.Products {
+			snapshot.Products = append(snapshot.Products, newProduct(p))
+		}
+	}
+	return &pb.PVZEvent{OccurredAt: timestamppb.Now(), Event: &pb.PVZEvent_Snapshot{Snapshot: snapshot}}
+}
+
+func newProduct(p storage.Product) *pb.Product {
+	return &pb.Product{Id: p.ProductId, DateTime: timestamppb.New(p.DateTime), Type: p.ProductType,
+		ReceptionId: p.ReceptionId, ReceptionVersion: p.ReceptionVersion}
+}
+
+// receptionWatch хранит открытую приёмку и её товары, которые уже видел клиент. Лента может прислать
+// события, записанные до снимка: они узнаются по идентификаторам и не повторяются.
+type receptionWatch struct {
+	// current - открытая приёмка, "" - открытой приёмки нет
+	current string
+	// since - время открытия приёмки из снимка: приёмки, открытые раньше неё, уже закрыты
+	since    time.Time
+	products map[string]bool
+}
+
+func newReceptionWatch(reception *storage.ReceptionInfo) *receptionWatch {
+	w := &receptionWatch{products: make(map[string]bool)}
+	if reception != nil {
+		w.current, w.since = reception.ReceptionId, reception.DateTime
+		for _, p := range reception.Products {
+			w.products[p.ProductId] = true
+		}
+	}
+	return w
+}
+
+// apply возвращает сообщение для клиента или nil, если событие уже учтено
+func (w *receptionWatch) apply(e storage.FeedEvent) (*pb.PVZEvent, error) {
+	msg := &pb.PVZEvent{Seq: e.Seq, OccurredAt: timestamppb.New(e.Event.OccurredAt)}
+	switch e.Event.Type {
+	case storage.EventReceptionOpened, storage.EventReceptionClosed:
+		var rec storage.ReceptionInfo
+		if err := json.Unmarshal(e.Event.Data, &rec); err != nil {
+			return nil, err
+		}
+		if e.Event.Type == storage.EventReceptionOpened {
+			if rec.ReceptionId == w.current || rec.DateTime.Before(w.since) {
+				return nil, nil
+			}
+			w.current = rec.ReceptionId
+			clear(w.products)
+			msg.Event = &pb.PVZEvent_ReceptionOpened{ReceptionOpened: newReception(rec)}
+			return msg, nil
+		}
+		if rec.ReceptionId != w.current {
+			return nil, nil
+		}
+		w.current = ""
+		msg.Event = &pb.PVZEvent_ReceptionClosed{ReceptionClosed: newReception(rec)}
+		return msg, nil
+	case storage.EventProductAdded, storage.EventProductDeleted:
+		var product storage.Product
+		if err := json.Unmarshal(e.Event.Data, &product); err != nil {
+			return nil, err
+		}
+		if product.ReceptionId != w.current {
+			return nil, nil
+		}
+		if e.Event.Type == storage.EventProductAdded {
+			if w.products[product.ProductId] {
+				return nil, nil
+			}
+			w.products[product.ProductId] = true
+			msg.Event = &pb.PVZEvent_ProductAdded{ProductAdded: newProduct(product)}
+			return msg, nil
+		}
+		if !w.products[product.ProductId] {
+			return nil, nil
+		}
+		delete(w.products, product.ProductId)
+		msg.Event = &pb.PVZEvent_ProductDeleted{ProductDeleted: newProduct(product)}
+		return msg, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,227 @@
+package grpc_api
+
+import (
+	"avito_intr/internal/feed"
+	pb "avito_intr/internal/grpc_api/pvz_v1"
+	"avito_intr/internal/storage"
+	"context"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"sync"
+	"testing"
+	"time"
+)
+
+const watchedPvz = "11111111-1111-1111-1111-111111111111"
+
+// feedStore - лента в памяти для feed.Hub
+type feedStore struct {
+	mu     sync.Mutex
+	events []storage.FeedEvent
+	notify func()
+}
+
+func (f *feedStore) AppendFeed(ctx context.Context, event storage.Event) error {
+	f.mu.Lock()
+	f.events = append(f.events, storage.FeedEvent{Seq: int64(len(f.events) + 1), City: storage.Moscow, Event: event})
+	notify := f.notify
+	f.mu.Unlock()
+	if notify != nil {
+		notify()
+	}
+	return nil
+}
+
+func (f *feedStore) ReadFeed(ctx context.Context, filter storage.FeedFilter) ([]storage.FeedEvent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var res []storage.FeedEvent
+	for _, e := range f.events {
+		if e.Seq > filter.After && (filter.Until == 0 || e.Seq <= filter.Until) &&
+			(filter.PvzId == "" || filter.PvzId == e.Event.PvzId) && len(res) < filter.Limit {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+func (f *feedStore) LastFeedSeq(ctx context.Context) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return int64(len(f.events)), nil
+}
+
+func (f *feedStore) ListenFeed(ctx context.Context, notify func()) error {
+	f.mu.Lock()
+	f.notify = notify
+	f.mu.Unlock()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (f *feedStore) PurgeFeed(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (f *feedStore) listening() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.notify != nil
+}
+
+type receptionStore struct {
+	storage.Storage
+	reception *storage.ReceptionInfo
+}
+
+func (r receptionStore) GetOpenReception(ctx context.Context, pvzId string) (*storage.ReceptionInfo, error) {
+	return r.reception, nil
+}
+
+// watchStream передаёт отправленные сервером сообщения в канал
+type watchStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *pb.PVZEvent
+}
+
+func (w watchStream) Context() context.Context { return w.ctx }
+
+func (w watchStream) Send(msg *pb.PVZEvent) error {
+	w.sent <- msg
+	return nil
+}
+
+func feedEvent(t *testing.T, seq int64, eventType string, data any) storage.FeedEvent {
+	t.Helper()
+	event, err := storage.NewEvent(eventType, watchedPvz, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage.FeedEvent{Seq: seq, City: storage.Moscow, Event: event}
+}
+
+func TestReceptionWatchSkipsSnapshotEvents(t *testing.T) {
+	since := time.Now()
+	current := storage.ReceptionInfo{ReceptionId: "r1", DateTime: since, PvzId: watchedPvz, Status: storage.Active,
+		Products: []storage.Product{{ProductId: "p1", ReceptionId: "r1"}}}
+	w := newReceptionWatch(&current)
+
+	steps := []struct {
+		event storage.FeedEvent
+		sent  bool
+	}{
+		// приёмка, закрытая до снимка
+		{feedEvent(t, 1, storage.EventReceptionOpened, storage.ReceptionInfo{ReceptionId: "r0", DateTime: since.Add(-time.Hour)}), false},
+		{feedEvent(t, 2, storage.EventProductAdded, storage.Product{ProductId: "p0", ReceptionId: "r0"}), false},
+		{feedEvent(t, 3, storage.EventReceptionClosed, storage.ReceptionInfo{ReceptionId: "r0", Status: storage.Inactive}), false},
+		{feedEvent(t, 4, storage.EventReceptionOpened, current), false},
+		{feedEvent(t, 5, storage.EventProductAdded, storage.Product{ProductId: "p1", ReceptionId: "r1"}), false},
+		{feedEvent(t, 6, storage.EventProductAdded, storage.Product{ProductId: "p2", ReceptionId: "r1"}), true},
+		{feedEvent(t, 7, storage.EventProductDeleted, storage.Product{ProductId: "p2", ReceptionId: "r1"}), true},
+		{feedEvent(t, 8, storage.EventProductDeleted, storage.Product{ProductId: "p2", ReceptionId: "r1"}), false},
+		{feedEvent(t, 9, storage.EventReceptionClosed, storage.ReceptionInfo{ReceptionId: "r1", Status: storage.Inactive}), true},
+		{feedEvent(t, 10, storage.EventReceptionOpened, storage.ReceptionInfo{ReceptionId: "r2", DateTime: since.Add(time.Hour)}), true},
+	}
+	for _, step := range steps {
+		msg, err := w.apply(step.event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (msg != nil) != step.sent {
+			t.Errorf("event %d %s: sent = %v, want %v", step.event.Seq, step.event.Event.Type, msg != nil, step.sent)
+		}
+		if closed := msg.GetReceptionClosed(); closed != nil && closed.GetStatus() != pb.ReceptionStatus_RECEPTION_STATUS_CLOSED {
+			t.Errorf("closed reception status = %v", closed.GetStatus())
+		}
+	}
+}
+
+// TestReceptionWatchProductVersion передаёт данные в том виде, в каком их пишет outbox pg_storage
+func TestReceptionWatchProductVersion(t *testing.T) {
+	current := storage.ReceptionInfo{ReceptionId: "r1", DateTime: time.Now(), PvzId: watchedPvz, Status: storage.Active, Version: 3}
+	w := newReceptionWatch(&current)
+	type productEvent struct {
+		storage.Product
+		PvzId string `json:"pvzId"`
+	}
+
+	added, err := w.apply(feedEvent(t, 1, storage.EventProductAdded, productEvent{
+		Product: storage.Product{ProductId: "p1", ProductType: storage.Shoes, ReceptionId: "r1", ReceptionVersion: 4},
+		PvzId:   watchedPvz}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := added.GetProductAdded().GetReceptionVersion(); got != 4 {
+		t.Errorf("product_added reception_version = %d, want 4", got)
+	}
+	deleted, err := w.apply(feedEvent(t, 2, storage.EventProductDeleted, productEvent{
+		Product: storage.Product{ProductId: "p1", ProductType: storage.Shoes, ReceptionId: "r1", ReceptionVersion: 5},
+		PvzId:   watchedPvz}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := deleted.GetProductDeleted().GetReceptionVersion(); got != 5 {
+		t.Errorf("product_deleted reception_version = %d, want 5", got)
+	}
+}
+
+func TestWatchPVZ(t *testing.T) {
+	store := &feedStore{}
+	hub := feed.NewHub(store, time.Hour, zap.NewNop())
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	defer stopHub()
+	go hub.Run(hubCtx, time.Hour)
+	for !store.listening() {
+		time.Sleep(time.Millisecond)
+	}
+
+	reception := &storage.ReceptionInfo{ReceptionId: "r1", DateTime: time.Now(), PvzId: watchedPvz, Status: storage.Active,
+		Version: 3, Products: []storage.Product{{ProductId: "p1", ProductType: storage.Shoes, ReceptionId: "r1"}}}
+	s := NewGrpcServer(receptionStore{reception: reception}, stubAuth{}, hub, time.Hour, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs("authorization", "Bearer valid")))
+	stream := watchStream{ctx: ctx, sent: make(chan *pb.PVZEvent, 10)}
+	done := make(chan error, 1)
+	go func() { done <- s.WatchPVZ(&pb.WatchPVZRequest{PvzId: watchedPvz}, stream) }()
+
+	receive := func() *pb.PVZEvent {
+		t.Helper()
+		select {
+		case msg := <-stream.sent:
+			return msg
+		case err := <-done:
+			t.Fatalf("watch ended: %v", err)
+		case <-time.After(time.Second):
+			t.Fatal("no message")
+		}
+		return nil
+	}
+	snapshot := receive().GetSnapshot()
+	if snapshot.GetReception().GetId() != "r1" || snapshot.GetReception().GetStatus() != pb.ReceptionStatus_RECEPTION_STATUS_IN_PROGRESS ||
+		len(snapshot.GetProducts()) != 1 {
+		t.Fatalf("snapshot = %v", snapshot)
+	}
+
+	event, _ := storage.NewEvent(storage.EventProductAdded, watchedPvz, storage.Product{ProductId: "p2", ReceptionId: "r1"})
+	if err := hub.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(); msg.GetProductAdded().GetId() != "p2" || msg.GetSeq() != 1 {
+		t.Errorf("event = %v", msg)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.Canceled {
+			t.Errorf("watch ended with %v, want Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watch did not stop after cancel")
+	}
+}
@@ -0,0 +1,140 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// CheckFunc проверяет одну зависимость. Детали попадают в JSON-ответ /readyz
+// и возвращаются даже при ошибке, чтобы было видно, что именно не так.
+type CheckFunc func(ctx context.Context) (map[string]any, error)
+
+type Result struct {
+	Status   string         `json:"status"`
+	Error    string         `json:"error,omitempty"`
+	Duration string         `json:"duration"`
+	Details  map[string]any `json:"details,omitempty"`
+}
+
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+func (r Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker выполняет проверки готовности параллельно, каждую со своим таймаутом
+type Checker struct {
+	timeout time.Duration
+	checks  []check
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+func (c *Checker) Register(name string, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, ch := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := c.run(ctx, ch.fn)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[ch.name] = res
+			if res.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, fn CheckFunc) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := fn(ctx)
+	res := Result{Status: StatusOK, Duration: time.Since(start).String(), Details: details}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			res.Error = fmt.Sprintf("timed out after %s", c.timeout)
+		}
+	}
+	return res
+}
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+func DatabaseCheck(db Pinger) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		return nil, db.Ping(ctx)
+	}
+}
+
+type MigrationSource interface {
+	MigrationVersion(ctx context.Context) (int, error)
+	LatestMigration() int
+}
+
+// MigrationCheck не готов, пока в базе не применены все миграции, вшитые в бинарник
+func MigrationCheck(src MigrationSource) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		latest := src.LatestMigration()
+		applied, err := src.MigrationVersion(ctx)
+		details := map[string]any{"applied": applied, "expected": latest}
+		if err != nil {
+			return details, err
+		}
+		if applied < latest {
+			return details, fmt.Errorf("database schema is at version %d, expected %d", applied, latest)
+		}
+		return details, nil
+	}
+}
+
+type PoolStater interface {
+	PoolStat() (acquired, max int32)
+}
+
+// PoolCheck не готов, когда занята доля соединений пула не меньше threshold:
+// новые запросы всё равно встанут в очередь на соединение.
+func PoolCheck(src PoolStater, threshold float64) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		acquired, max := src.PoolStat()
+		details := map[string]any{"acquired": acquired, "max": max}
+		if max > 0 && float64(acquired)/float64(max) >= threshold {
+			return details, fmt.Errorf("connection pool saturated: %d of %d connections in use", acquired, max)
+		}
+		return details, nil
+	}
+}
@@ -0,0 +1,93 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeDB struct {
+	pingErr  error
+	applied  int
+	latest   int
+	acquired int32
+	max      int32
+}
+
+func (f fakeDB) Ping(ctx context.Context) error {
+	return f.pingErr
+}
+
+func (f fakeDB) MigrationVersion(ctx context.Context) (int, error) {
+	return f.applied, nil
+}
+
+func (f fakeDB) LatestMigration() int {
+	return f.latest
+}
+
+func (f fakeDB) PoolStat() (int32, int32) {
+	return f.acquired, f.max
+}
+
+func newChecker(db fakeDB) *Checker {
+	c := NewChecker(50 * time.Millisecond)
+	c.Register("database", DatabaseCheck(db))
+	c.Register("migrations", MigrationCheck(db))
+	c.Register("pool", PoolCheck(db, 0.9))
+	return c
+}
+
+func TestCheckerReady(t *testing.T) {
+	report := newChecker(fakeDB{applied: 8, latest: 8, acquired: 1, max: 4}).Run(context.Background())
+	if !report.Ready() {
+		t.Fatalf("report = %+v, want ready", report)
+	}
+	if len(report.Checks) != 3 {
+		t.Errorf("checks = %d, want 3", len(report.Checks))
+	}
+	if got := report.Checks["migrations"].Details["applied"]; got != 8 {
+		t.Errorf("applied migration = %v, want 8", got)
+	}
+}
+
+func TestCheckerFailures(t *testing.T) {
+	tests := map[string]struct {
+		db     fakeDB
+		failed string
+	}{
+		"database down":     {db: fakeDB{pingErr: errors.New("connection refused"), applied: 8, latest: 8, max: 4}, failed: "database"},
+		"migration missing": {db: fakeDB{applied: 7, latest: 8, max: 4}, failed: "migrations"},
+		"pool saturated":    {db: fakeDB{applied: 8, latest: 8, acquired: 4, max: 4}, failed: "pool"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			report := newChecker(tt.db).Run(context.Background())
+			if report.Ready() {
+				t.Fatal("want not ready")
+			}
+			for check, res := range report.Checks {
+				want := StatusOK
+				if check == tt.failed {
+					want = StatusFail
+				}
+				if res.Status != want {
+					t.Errorf("%s = %s (%s), want %s", check, res.Status, res.Error, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	c := NewChecker(10 * time.Millisecond)
+	c.Register("slow", func(ctx context.Context) (map[string]any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	res := c.Run(context.Background()).Checks["slow"]
+	if res.Status != StatusFail || res.Error != "timed out after 10ms" {
+		t.Errorf("result = %+v", res)
+	}
+}
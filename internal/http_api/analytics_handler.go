@@ -0,0 +1,52 @@
+package http_api
+
+import (
+	"avito_intr/internal/http_api/openapi"
+	"avito_intr/internal/storage"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+	"net/http"
+)
+
+func newDailyProducts(rows []storage.DailyProducts) []openapi.DailyProducts {
+	res := make([]openapi.DailyProducts, 0, len(rows))
+	for _, row := range rows {
+		item := openapi.DailyProducts{Day: openapi_types.Date{Time: row.Day}, City: openapi.DailyProductsCity(row.City),
+			Type: openapi.DailyProductsType(row.ProductType), Count: row.Count}
+		if row.PvzId != "" {
+			item.PvzId = idPtr(row.PvzId)
+		}
+		res = append(res, item)
+	}
+	return res
+}
+
+func (s *Server) GetAnalytics(w http.ResponseWriter, r *http.Request, params openapi.GetAnalyticsParams) {
+	filter := storage.AnalyticsFilter{
+		StartDate: dateParam(params.StartDate),
+		EndDate:   dateParam(params.EndDate),
+		City:      storage.City(stringParam(params.City)),
+	}
+
+	stats, err := s.analytics.Get(r.Context(), filter)
+	if err != nil {
+		s.writeStorageError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	resp := openapi.Analytics{
+		ProductsPerPvz:  newDailyProducts(stats.ProductsPerPvz),
+		ProductsPerCity: newDailyProducts(stats.ProductsPerCity),
+		ReceptionDuration: openapi.ReceptionDurations{
+			Closed:         stats.Durations.Closed,
+			AverageSeconds: stats.Durations.Average.Seconds(),
+			P95Seconds:     stats.Durations.P95.Seconds(),
+		},
+		ReceptionsPerEmployee: make([]openapi.EmployeeReceptions, 0, len(stats.ReceptionsPerEmployee)),
+	}
+	for _, e := range stats.ReceptionsPerEmployee {
+		resp.ReceptionsPerEmployee = append(resp.ReceptionsPerEmployee, openapi.EmployeeReceptions{
+			EmployeeId: parseID(e.EmployeeId), Email: openapi_types.Email(e.Email), Receptions: e.Receptions})
+	}
+
+	s.writeJSON(w, r, http.StatusOK, resp)
+}
@@ -0,0 +1,182 @@
+package http_api
+
+import (
+	"avito_intr/internal/http_api/openapi"
+	"avito_intr/internal/version"
+	"bytes"
+	_ "embed"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gorilla/mux"
+	swaggerFiles "github.com/swaggo/files"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+	"html/template"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	specJSONPath = "/openapi.json"
+	specYAMLPath = "/openapi.yaml"
+)
+
+//go:embed explorer/index.html
+var explorerHTML string
+
+var explorerPage = template.Must(template.New("explorer").Parse(explorerHTML))
+
+type explorerAsset struct {
+	contentType string
+	data        []byte
+}
+
+// Swagger UI встроен в бинарник целиком, страница работает без доступа к CDN
+var explorerAssets = map[string]explorerAsset{
+	"swagger-ui.css":                  {"text/css; charset=utf-8", swaggerFiles.FileSwaggerUICSS},
+	"index.css":                       {"text/css; charset=utf-8", swaggerFiles.FileIndexCSS},
+	"swagger-ui-bundle.js":            {"text/javascript; charset=utf-8", swaggerFiles.FileSwaggerUIBundleJs},
+	"swagger-ui-standalone-preset.js": {"text/javascript; charset=utf-8", swaggerFiles.FileSwaggerUIStandalonePresetJs},
+	"favicon-32x32.png":               {"image/png", swaggerFiles.FileFavicon32x32Png},
+	"favicon-16x16.png":               {"image/png", swaggerFiles.FileFavicon16x16Png},
+}
+
+// время старта процесса служит Last-Modified для встроенных файлов
+var assetsModTime = time.Now()
+
+func (s *Server) registerDocs(router *mux.Router, explorerPath string) {
+	router.HandleFunc(specJSONPath, s.specJSONHandler).Methods("GET")
+	router.HandleFunc(specYAMLPath, s.specYAMLHandler).Methods("GET")
+	if explorerPath == "" {
+		return
+	}
+
+	prefix := strings.TrimRight(explorerPath, "/")
+	// относительный Location сохраняет префикс, под которым сервис опубликован за прокси.
+	// http.Redirect превратил бы его в абсолютный путь.
+	location := path.Base(prefix) + "/"
+	router.HandleFunc(prefix, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", location)
+		w.WriteHeader(http.StatusMovedPermanently)
+	}).Methods("GET")
+	router.HandleFunc(prefix+"/", s.explorerHandler).Methods("GET")
+	router.HandleFunc(prefix+"/{asset}", s.explorerAssetHandler).Methods("GET")
+}
+
+// baseURL - адрес, по которому клиент обратился к сервису, если он не задан явно в конфигурации.
+// Заголовки обратного прокси учитываются только от доверенных прокси: иначе клиент подменил бы
+// адреса в спецификации и флаг Secure у cookie ленты.
+func (s *Server) baseURL(r *http.Request) string {
+	if s.publicURL != "" {
+		return strings.TrimRight(s.publicURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if !s.trustedProxies.Trusted(remoteHost(r)) {
+		return scheme + "://" + r.Host
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	host := r.Host
+	if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+		host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return scheme + "://" + host + strings.TrimRight(r.Header.Get("X-Forwarded-Prefix"), "/")
+}
+
+var buildMetadataUnsafe = regexp.MustCompile(`[^0-9A-Za-z.-]`)
+
+// specVersion дополняет версию контракта версией сборки в виде build metadata semver: 1.0.0+4f2a9c1e7b3d
+func specVersion(contract, build string) string {
+	return contract + "+" + buildMetadataUnsafe.ReplaceAllString(build, "-")
+}
+
+// specDocument возвращает встроенную спецификацию с адресами сервера и версией сборки.
+// Относительные servers из swagger.yaml (/v1 и / у служебных путей) дополняются адресом сервиса.
+func (s *Server) specDocument(r *http.Request) (*openapi3.T, error) {
+	doc, err := openapi.GetSwagger()
+	if err != nil {
+		return nil, err
+	}
+	// генератор переписывает operationId в стиле Go, клиентам отдаём вид из swagger.yaml
+	for _, item := range doc.Paths.Map() {
+		for _, op := range item.Operations() {
+			first, size := utf8.DecodeRuneInString(op.OperationID)
+			op.OperationID = string(unicode.ToLower(first)) + op.OperationID[size:]
+		}
+	}
+	base := s.baseURL(r)
+	for _, server := range doc.Servers {
+		server.URL = base + strings.TrimRight(server.URL, "/")
+	}
+	dropOperationServers(doc)
+	for _, item := range doc.Paths.Map() {
+		for _, server := range item.Servers {
+			server.URL = base + strings.TrimRight(server.URL, "/")
+		}
+	}
+	doc.Info.Version = specVersion(doc.Info.Version, version.String())
+	return doc, nil
+}
+
+func (s *Server) specJSONHandler(w http.ResponseWriter, r *http.Request) {
+	doc, err := s.specDocument(r)
+	if err != nil {
+		s.writeInternalError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	s.writeJSON(w, r, http.StatusOK, doc)
+}
+
+func (s *Server) specYAMLHandler(w http.ResponseWriter, r *http.Request) {
+	doc, err := s.specDocument(r)
+	if err != nil {
+		s.writeInternalError(w, r, err)
+		return
+	}
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		s.writeInternalError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(out); err != nil {
+		s.requestLogger(r).Error("failed to write response", zap.Error(err))
+	}
+}
+
+func (s *Server) explorerHandler(w http.ResponseWriter, r *http.Request) {
+	var page bytes.Buffer
+	err := explorerPage.Execute(&page, struct{ Title, SpecURL string }{"backend service API", s.baseURL(r) + specJSONPath})
+	if err != nil {
+		s.writeInternalError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(page.Bytes()); err != nil {
+		s.requestLogger(r).Error("failed to write response", zap.Error(err))
+	}
+}
+
+func (s *Server) explorerAssetHandler(w http.ResponseWriter, r *http.Request) {
+	asset, ok := explorerAssets[mux.Vars(r)["asset"]]
+	if !ok {
+		s.notFoundHandler(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", asset.contentType)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	http.ServeContent(w, r, "", assetsModTime, bytes.NewReader(asset.data))
+}
@@ -0,0 +1,140 @@
+package http_api
+
+import (
+	"avito_intr/internal/ratelimit"
+	"avito_intr/internal/version"
+	"encoding/json"
+	"github.com/getkin/kin-openapi/openapi3"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSpecDocument(t *testing.T) {
+	version.Version = "1.4.0"
+	defer func() { version.Version = "" }()
+
+	// httptest.NewRequest приходит с 192.0.2.1
+	proxies, _ := ratelimit.ParseTrustedProxies([]string{"192.0.2.0/24"})
+	s := NewServer(nil, nil, nil, Options{TrustedProxies: proxies}, zap.NewNop())
+	for _, path := range []string{specJSONPath, specYAMLPath} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Host = "pvz.internal:8080"
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Prefix", "/pvz-api/")
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d", path, rr.Code)
+		}
+
+		var raw any
+		if path == specJSONPath {
+			err := json.Unmarshal(rr.Body.Bytes(), &raw)
+			if err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+		} else if err := yaml.Unmarshal(rr.Body.Bytes(), &raw); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		data, _ := json.Marshal(raw)
+		doc, err := openapi3.NewLoader().LoadFromData(data)
+		if err != nil {
+			t.Fatalf("%s: served document does not load: %v", path, err)
+		}
+
+		if len(doc.Servers) != 1 || doc.Servers[0].URL != "https://pvz.internal:8080/pvz-api/v1" {
+			t.Errorf("%s: servers = %+v", path, doc.Servers)
+		}
+		if servers := doc.Paths.Find("/healthz").Servers; len(servers) != 1 || servers[0].URL != "https://pvz.internal:8080/pvz-api" {
+			t.Errorf("%s: /healthz servers = %+v, service paths are not versioned", path, servers)
+		}
+		if doc.Info.Version != "1.0.0+1.4.0" {
+			t.Errorf("%s: info.version = %q", path, doc.Info.Version)
+		}
+		if op := doc.Paths.Find("/pvz").Post; op == nil || op.OperationID != "createPvz" {
+			t.Errorf("%s: operationId must match swagger.yaml", path)
+		}
+	}
+}
+
+func TestSpecDocumentUntrustedForwardedHeaders(t *testing.T) {
+	proxies, _ := ratelimit.ParseTrustedProxies([]string{"10.0.0.0/8"})
+	s := NewServer(nil, nil, nil, Options{TrustedProxies: proxies}, zap.NewNop())
+	req := httptest.NewRequest("GET", specJSONPath, nil)
+	req.Host = "pvz.internal:8080"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "evil.example.com")
+	req.Header.Set("X-Forwarded-Prefix", "/phish")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	var doc struct {
+		Servers []struct{ URL string } `json:"servers"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Servers) != 1 || doc.Servers[0].URL != "http://pvz.internal:8080/v1" {
+		t.Errorf("servers = %+v, headers from an untrusted client must be ignored", doc.Servers)
+	}
+}
+
+func TestSpecDocumentPublicURL(t *testing.T) {
+	s := NewServer(nil, nil, nil, Options{PublicURL: "https://api.example.com/"}, zap.NewNop())
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", specJSONPath, nil))
+
+	var doc struct {
+		Servers []struct{ URL string } `json:"servers"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Servers) != 1 || doc.Servers[0].URL != "https://api.example.com/v1" {
+		t.Errorf("servers = %+v", doc.Servers)
+	}
+}
+
+func TestExplorer(t *testing.T) {
+	s := NewServer(nil, nil, nil, Options{ExplorerPath: "/docs"}, zap.NewNop())
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/docs", nil))
+	if rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "docs/" {
+		t.Errorf("GET /docs = %d, Location %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "http://pvz.internal/docs/", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"http://pvz.internal/openapi.json"`) {
+		t.Errorf("GET /docs/ = %d, body must point to the spec: %s", rr.Code, rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "https://") {
+		t.Error("explorer page must not load anything from external hosts")
+	}
+
+	for asset, contentType := range map[string]string{"swagger-ui-bundle.js": "text/javascript", "swagger-ui.css": "text/css"} {
+		rr = httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest("GET", "/docs/"+asset, nil))
+		if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), contentType) || rr.Body.Len() == 0 {
+			t.Errorf("GET /docs/%s = %d, %s", asset, rr.Code, rr.Header().Get("Content-Type"))
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/docs/secret.txt", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("unknown asset = %d, want 404", rr.Code)
+	}
+
+	disabled := NewServer(nil, nil, nil, Options{}, zap.NewNop())
+	rr = httptest.NewRecorder()
+	disabled.ServeHTTP(rr, httptest.NewRequest("GET", "/docs/", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("explorer disabled: GET /docs/ = %d, want 404", rr.Code)
+	}
+}
@@ -0,0 +1,68 @@
+package http_api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const preconditionFailedMessage = "resource version does not match If-Match"
+
+var errMultipleETags = errors.New("If-Match must contain a single entity tag")
+
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", etag(version))
+}
+
+// parseIfMatch разбирает заголовок If-Match в ожидаемую версию для хранилища (0 - без проверки).
+// Слабые и нечисловые ETag не совпадают со строгим сравнением ни с какой версией, поэтому
+// ok = false означает, что запрос надо отклонить с 412, не обращаясь к хранилищу.
+func parseIfMatch(header *string) (version int64, ok bool, err error) {
+	if header == nil || strings.TrimSpace(*header) == "" {
+		return 0, true, nil
+	}
+	var versions []int64
+	for _, tag := range strings.Split(*header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return 0, true, nil
+		}
+		raw, found := strings.CutPrefix(tag, `"`)
+		raw, closed := strings.CutSuffix(raw, `"`)
+		if !found || !closed {
+			continue
+		}
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || v <= 0 {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	switch len(versions) {
+	case 0:
+		return 0, false, nil
+	case 1:
+		return versions[0], true, nil
+	}
+	return 0, false, errMultipleETags
+}
+
+// expectedVersion достаёт из If-Match ожидаемую версию. Если запрос заведомо не может пройти
+// проверку, ответ уже записан и ok = false.
+func (s *Server) expectedVersion(w http.ResponseWriter, r *http.Request, header *string) (version int64, ok bool) {
+	version, ok, err := parseIfMatch(header)
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return 0, false
+	}
+	if !ok {
+		s.writeError(w, r, http.StatusPreconditionFailed, codePreconditionFailed, preconditionFailedMessage)
+		return 0, false
+	}
+	return version, true
+}
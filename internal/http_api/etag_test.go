@@ -0,0 +1,130 @@
+package http_api
+
+import (
+	"avito_intr/internal/http_api/openapi"
+	"avito_intr/internal/storage"
+	"context"
+	"encoding/json"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// versionStore открывает приёмку, только если версия ПВЗ совпадает с ожидаемой
+type versionStore struct {
+	storage.Storage
+	pvzVersion int64
+}
+
+func (v versionStore) OpenReception(ctx context.Context, author, pvz string, expectedVersion int64) (*storage.ReceptionInfo, error) {
+	if expectedVersion != 0 && expectedVersion != v.pvzVersion {
+		return nil, storage.ErrVersionMismatch
+	}
+	return &storage.ReceptionInfo{ReceptionId: "44444444-4444-4444-4444-444444444444", PvzId: pvz,
+		DateTime: time.Date(2025, 4, 15, 12, 0, 0, 0, time.UTC), Status: storage.Active, Version: 8}, nil
+}
+
+func TestIfMatch(t *testing.T) {
+	s := NewServer(versionStore{pvzVersion: 7}, stubAuth{}, nil, Options{ValidateResponses: true}, zap.NewNop())
+	tests := []struct {
+		name    string
+		ifMatch string
+		code    int
+		errCode openapi.ErrorCode
+	}{
+		{"no header", "", http.StatusCreated, ""},
+		{"any version", "*", http.StatusCreated, ""},
+		{"current version", `"7"`, http.StatusCreated, ""},
+		{"one of tags is current", `"7", W/"6"`, http.StatusCreated, ""},
+		{"stale version", `"6"`, http.StatusPreconditionFailed, codePreconditionFailed},
+		{"weak tag", `W/"7"`, http.StatusPreconditionFailed, codePreconditionFailed},
+		{"not a version", `"abc"`, http.StatusPreconditionFailed, codePreconditionFailed},
+		{"several versions", `"6", "7"`, http.StatusBadRequest, codeInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/v1/receptions", strings.NewReader(`{"pvzId":"11111111-1111-1111-1111-111111111111"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer valid")
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			if rr.Code != tt.code {
+				t.Fatalf("status = %d, want %d, body %s", rr.Code, tt.code, rr.Body.String())
+			}
+			if tt.errCode == "" {
+				if got := rr.Header().Get("ETag"); got != `"8"` {
+					t.Errorf("ETag = %q, want %q", got, `"8"`)
+				}
+				var body openapi.Reception
+				if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Version == nil || *body.Version != 8 {
+					t.Errorf("body = %s, want version 8", rr.Body.String())
+				}
+				return
+			}
+			var body openapi.Error
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Code != tt.errCode {
+				t.Errorf("body = %s, want %s error", rr.Body.String(), tt.errCode)
+			}
+		})
+	}
+}
+
+func (v versionStore) GetPvzById(ctx context.Context, pvzId string) (*storage.PvzInfo, error) {
+	if pvzId != "11111111-1111-1111-1111-111111111111" {
+		return nil, storage.ErrPvzNotFound
+	}
+	date := time.Date(2025, 4, 15, 12, 0, 0, 0, time.UTC)
+	return &storage.PvzInfo{PvzId: &pvzId, RegistrationDate: &date, City: storage.Moscow, Version: v.pvzVersion}, nil
+}
+
+func (v versionStore) GetOpenReception(ctx context.Context, pvzId string) (*storage.ReceptionInfo, error) {
+	if _, err := v.GetPvzById(ctx, pvzId); err != nil {
+		return nil, err
+	}
+	if v.pvzVersion == 0 {
+		return nil, nil
+	}
+	return &storage.ReceptionInfo{ReceptionId: "44444444-4444-4444-4444-444444444444", PvzId: pvzId,
+		DateTime: time.Date(2025, 4, 15, 12, 0, 0, 0, time.UTC), Status: storage.Active, Version: 8,
+		Products: []storage.Product{{ProductId: "55555555-5555-5555-5555-555555555555", ProductType: storage.Shoes,
+			ReceptionId: "44444444-4444-4444-4444-444444444444"}}}, nil
+}
+
+func TestReadETag(t *testing.T) {
+	tests := []struct {
+		name       string
+		pvzVersion int64
+		path       string
+		code       int
+		etag       string
+	}{
+		{"pvz", 7, "/v1/pvz/11111111-1111-1111-1111-111111111111", http.StatusOK, `"7"`},
+		{"unknown pvz", 7, "/v1/pvz/22222222-2222-2222-2222-222222222222", http.StatusNotFound, ""},
+		{"open reception", 7, "/v1/pvz/11111111-1111-1111-1111-111111111111/open_reception", http.StatusOK, `"8"`},
+		{"no open reception", 0, "/v1/pvz/11111111-1111-1111-1111-111111111111/open_reception", http.StatusNotFound, ""},
+		{"open reception of unknown pvz", 7, "/v1/pvz/22222222-2222-2222-2222-222222222222/open_reception", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(versionStore{pvzVersion: tt.pvzVersion}, stubAuth{}, nil, Options{ValidateResponses: true}, zap.NewNop())
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Authorization", "Bearer valid")
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			if rr.Code != tt.code || rr.Header().Get("ETag") != tt.etag {
+				t.Errorf("status = %d, ETag = %q, want %d, %q; body %s", rr.Code, rr.Header().Get("ETag"), tt.code, tt.etag, rr.Body.String())
+			}
+			if tt.code == http.StatusNotFound && errorCode(t, rr) != codeNotFound {
+				t.Errorf("body = %s, want not_found", rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package http_api
+
+import (
+	"avito_intr/internal/feed"
+	"avito_intr/internal/http_api/openapi"
+	"avito_intr/internal/storage"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	eventStreamContentType = "text/event-stream"
+	keepaliveInterval      = 15 * time.Second
+	// eventRetry - через сколько миллисекунд EventSource переподключается после обрыва
+	eventRetry = 3000
+
+	// eventsCookie хранит токен ленты для EventSource, который не умеет ставить заголовок Authorization
+	eventsCookie = "pvz_events"
+	// eventsScope - токен с ним принимается только лентой и не годится для остального API
+	eventsScope     = "events"
+	eventSessionTTL = time.Hour
+)
+
+// CreateEventSession ставит cookie с токеном ленты. EventSource шлёт её сам, в том числе при переподключении.
+func (s *Server) CreateEventSession(w http.ResponseWriter, r *http.Request) {
+	token, err := s.auth.GenerateScoped(r.Context().Value("uuid").(string), eventsScope, eventSessionTTL)
+	if err != nil {
+		s.writeInternalError(w, r, err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     eventsCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(eventSessionTTL / time.Second),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(s.baseURL(r), "https://"),
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// StreamEvents отдаёт ленту в формате Server-Sent Events. id сообщения - Seq события в ленте,
+// по нему клиент с Last-Event-ID получает пропущенное, а события, которые он уже видел, не повторяются.
+func (s *Server) StreamEvents(w http.ResponseWriter, r *http.Request, params openapi.StreamEventsParams) {
+	if s.feed == nil {
+		s.writeError(w, r, http.StatusNotFound, codeNotFound, "event feed is disabled")
+		return
+	}
+	var after int64
+	if params.LastEventID != nil {
+		var err error
+		if after, err = strconv.ParseInt(*params.LastEventID, 10, 64); err != nil {
+			s.writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "Last-Event-ID must be an event id from this stream")
+			return
+		}
+	}
+	filter := feed.Filter{City: storage.City(stringParam(params.City))}
+	if params.PvzId != nil {
+		filter.PvzId = params.PvzId.String()
+	}
+
+	sub := s.feed.Subscribe(filter)
+	defer sub.Close()
+
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	w.Header().Set("Content-Type", eventStreamContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	// без этого заголовка nginx копит ответ в буфере
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetry)
+	flush()
+
+	write := func(e storage.FeedEvent) error {
+		// другой экземпляр мог отдать клиенту события, до которых лента этого ещё не дошла
+		if e.Seq <= after {
+			return nil
+		}
+		after = e.Seq
+		data, err := json.Marshal(e.Event)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Event.Type, data)
+		return err
+	}
+	if err := sub.Replay(r.Context(), after, write); err != nil {
+		if r.Context().Err() == nil {
+			s.requestLogger(r).Warn("failed to replay event feed", zap.Int64("after", after), zap.Error(err))
+		}
+		return
+	}
+	flush()
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.stopping:
+			return
+		case e, ok := <-sub.C:
+			// лента закрыта: клиент отстал или сервис останавливается, EventSource переподключится сам
+			if !ok {
+				return
+			}
+			if err := write(e); err != nil {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		flush()
+	}
+}
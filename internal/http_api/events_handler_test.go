@@ -0,0 +1,211 @@
+package http_api
+
+import (
+	"avito_intr/internal/feed"
+	"avito_intr/internal/storage"
+	"bufio"
+	"context"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	watchedPvz = "11111111-1111-1111-1111-111111111111"
+	otherPvz   = "22222222-2222-2222-2222-222222222222"
+)
+
+// feedStore - лента в памяти для feed.Hub
+type feedStore struct {
+	mu     sync.Mutex
+	events []storage.FeedEvent
+	notify func()
+}
+
+func (f *feedStore) AppendFeed(ctx context.Context, event storage.Event) error {
+	f.mu.Lock()
+	f.events = append(f.events, storage.FeedEvent{Seq: int64(len(f.events) + 1), City: storage.Moscow, Event: event})
+	notify := f.notify
+	f.mu.Unlock()
+	if notify != nil {
+		notify()
+	}
+	return nil
+}
+
+func (f *feedStore) ReadFeed(ctx context.Context, filter storage.FeedFilter) ([]storage.FeedEvent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var res []storage.FeedEvent
+	for _, e := range f.events {
+		if e.Seq > filter.After && (filter.Until == 0 || e.Seq <= filter.Until) &&
+			(filter.PvzId == "" || filter.PvzId == e.Event.PvzId) && len(res) < filter.Limit {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+func (f *feedStore) LastFeedSeq(ctx context.Context) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return int64(len(f.events)), nil
+}
+
+func (f *feedStore) ListenFeed(ctx context.Context, notify func()) error {
+	f.mu.Lock()
+	f.notify = notify
+	f.mu.Unlock()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (f *feedStore) PurgeFeed(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (f *feedStore) listening() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.notify != nil
+}
+
+func appendEvent(t *testing.T, hub *feed.Hub, eventType, pvzId string) {
+	t.Helper()
+	event, err := storage.NewEvent(eventType, pvzId, map[string]string{"pvzId": pvzId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hub.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readEvent читает одно сообщение SSE, пропуская retry и комментарии
+func readEvent(t *testing.T, r *bufio.Reader) map[string]string {
+	t.Helper()
+	msg := make(map[string]string)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if msg["id"] != "" {
+				return msg
+			}
+			continue
+		}
+		if field, value, ok := strings.Cut(line, ": "); ok {
+			msg[field] = value
+		}
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	store := &feedStore{}
+	hub := feed.NewHub(store, time.Hour, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	appendEvent(t, hub, storage.EventReceptionOpened, watchedPvz)
+	appendEvent(t, hub, storage.EventProductAdded, otherPvz)
+	appendEvent(t, hub, storage.EventProductAdded, watchedPvz)
+	go hub.Run(ctx, time.Hour)
+	for !store.listening() {
+		time.Sleep(time.Millisecond)
+	}
+
+	srv := httptest.NewServer(NewServer(&webhookStore{}, stubAuth{}, nil, Options{Feed: hub}, zap.NewNop()))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/v1/events?pvzId="+watchedPvz, nil)
+	req.Header.Set("Authorization", "Bearer valid")
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != eventStreamContentType {
+		t.Fatalf("status = %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	body := bufio.NewReader(resp.Body)
+
+	// после Last-Event-ID: 1 пропущено только событие 3, событие 2 другого ПВЗ не подходит под фильтр
+	if msg := readEvent(t, body); msg["id"] != "3" || msg["event"] != storage.EventProductAdded {
+		t.Errorf("replayed = %v", msg)
+	}
+	appendEvent(t, hub, storage.EventReceptionClosed, otherPvz)
+	appendEvent(t, hub, storage.EventReceptionClosed, watchedPvz)
+	msg := readEvent(t, body)
+	if msg["id"] != "5" || msg["event"] != storage.EventReceptionClosed || !strings.Contains(msg["data"], watchedPvz) {
+		t.Errorf("live = %v", msg)
+	}
+}
+
+func TestStreamEventsBadLastEventID(t *testing.T) {
+	s := NewServer(&webhookStore{}, stubAuth{}, nil, Options{Feed: feed.NewHub(&feedStore{}, time.Hour, zap.NewNop())}, zap.NewNop())
+	req := httptest.NewRequest("GET", "/v1/events", nil)
+	req.Header.Set("Authorization", "Bearer valid")
+	req.Header.Set("Last-Event-ID", "abc")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, body %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestEventSessionCookie(t *testing.T) {
+	s := NewServer(&webhookStore{}, stubAuth{}, nil, Options{Feed: feed.NewHub(&feedStore{}, time.Hour, zap.NewNop())}, zap.NewNop())
+	req := httptest.NewRequest("POST", "/v1/events/session", nil)
+	req.Header.Set("Authorization", "Bearer valid")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	cookies := rr.Result().Cookies()
+	if rr.Code != http.StatusNoContent || len(cookies) != 1 || cookies[0].Name != eventsCookie || !cookies[0].HttpOnly {
+		t.Fatalf("status = %d, cookies %v", rr.Code, cookies)
+	}
+	if cookies[0].Secure {
+		t.Errorf("X-Forwarded-Proto is not set, cookie must not be Secure")
+	}
+
+	// X-Forwarded-Proto от недоверенного адреса не делает cookie Secure
+	req = httptest.NewRequest("POST", "/v1/events/session", nil)
+	req.Header.Set("Authorization", "Bearer valid")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	if c := rr.Result().Cookies(); len(c) != 1 || c[0].Secure {
+		t.Errorf("cookies %v, untrusted X-Forwarded-Proto must be ignored", c)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		cookie string
+		code   int
+	}{
+		// неверный Last-Event-ID отвечает 400 уже после проверки токена, поток не открывается
+		{"feed accepts cookie", "GET", "/v1/events", cookies[0].Value, http.StatusBadRequest},
+		{"login token is not a feed cookie", "GET", "/v1/events", "valid", http.StatusUnauthorized},
+		{"cookie only for feed", "GET", "/v1/webhooks", cookies[0].Value, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.AddCookie(&http.Cookie{Name: eventsCookie, Value: tt.cookie})
+			req.Header.Set("Last-Event-ID", "abc")
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+			if rr.Code != tt.code {
+				t.Errorf("status = %d, want %d, body %s", rr.Code, tt.code, rr.Body.String())
+			}
+		})
+	}
+}
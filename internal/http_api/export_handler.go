@@ -0,0 +1,116 @@
+package http_api
+
+import (
+	"avito_intr/internal/export"
+	"avito_intr/internal/http_api/openapi"
+	"avito_intr/internal/storage"
+	"go.uber.org/zap"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const exportFlushEvery = 1000
+
+var exportHeader = []string{"pvz_id", "city", "reception_id", "reception_date", "reception_status",
+	"product_id", "product_type", "product_date"}
+
+// exportFormat выбирает формат по параметру format, а без него - по заголовку Accept.
+// Допустимые значения format проверены по swagger.yaml.
+func exportFormat(r *http.Request, format *openapi.ExportReceptionsParamsFormat) export.Format {
+	if format != nil {
+		return export.Format(*format)
+	}
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return export.CSV
+		case export.XLSX.ContentType():
+			return export.XLSX
+		}
+	}
+	return export.CSV
+}
+
+func exportRecord(row storage.ExportRow) []string {
+	productDate := ""
+	if row.ProductDate != nil {
+		productDate = row.ProductDate.Format(time.RFC3339)
+	}
+	return []string{row.PvzId, string(row.City), row.ReceptionId, row.ReceptionDate.Format(time.RFC3339),
+		string(row.ReceptionStatus), row.ProductId, row.ProductType, productDate}
+}
+
+func (s *Server) ExportReceptions(w http.ResponseWriter, r *http.Request, params openapi.ExportReceptionsParams) {
+	format := exportFormat(r, params.Format)
+
+	filter := storage.ExportFilter{
+		StartDate: dateParam(params.StartDate),
+		EndDate:   dateParam(params.EndDate),
+		City:      storage.City(stringParam(params.City)),
+	}
+	if params.PvzId != nil {
+		filter.PvzId = params.PvzId.String()
+	}
+
+	var out export.Writer
+	started := false
+	begin := func() error {
+		started = true
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", `attachment; filename="receptions.`+string(format)+`"`)
+		w.WriteHeader(http.StatusOK)
+		var err error
+		out, err = export.NewWriter(format, w)
+		if err != nil {
+			return err
+		}
+		return out.Write(exportHeader)
+	}
+
+	flusher, _ := w.(http.Flusher)
+	rows := 0
+	err := s.store.ExportReceptions(r.Context(), filter, func(row storage.ExportRow) error {
+		if !started {
+			if err := begin(); err != nil {
+				return err
+			}
+		}
+		if err := out.Write(exportRecord(row)); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushEvery == 0 && flusher != nil {
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err == nil && !started {
+		err = begin()
+	}
+	if err != nil && !started {
+		s.writeStorageError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		// заголовки уже отправлены, остаётся оборвать ответ
+		s.requestLogger(r).Error("export interrupted", zap.Int("rows", rows), zap.Error(err))
+		if out != nil {
+			_ = out.Close()
+		}
+		panic(http.ErrAbortHandler)
+	}
+
+	if err := out.Close(); err != nil {
+		s.requestLogger(r).Error("failed to write export", zap.Error(err))
+	}
+}
@@ -0,0 +1,31 @@
+package http_api
+
+import (
+	"encoding/json"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// Healthz отвечает, пока процесс жив и обслуживает запросы, зависимости не проверяются
+func (s *Server) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(`{"status":"ok"}` + "\n"))
+}
+
+func (s *Server) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := s.health.Run(r.Context())
+
+	code := http.StatusOK
+	if !report.Ready() {
+		code = http.StatusServiceUnavailable
+		s.requestLogger(r).Warn("readiness check failed", zap.Any("checks", report.Checks))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		s.requestLogger(r).Error("failed to write response", zap.Error(err))
+	}
+}
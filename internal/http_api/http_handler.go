@@ -1,31 +1,89 @@
 package http_api
 
 import (
+	"avito_intr/internal/analytics"
 	"avito_intr/internal/auth"
+	"avito_intr/internal/feed"
+	"avito_intr/internal/health"
+	"avito_intr/internal/http_api/openapi"
+	"avito_intr/internal/idempotency"
+	"avito_intr/internal/ratelimit"
+	"avito_intr/internal/requestid"
 	"avito_intr/internal/storage"
+	"avito_intr/internal/tracing"
 	"context"
 	"encoding/json"
 	"github.com/gorilla/mux"
-	"github.com/prometheus/client_golang/prometheus"
+	openapi_types "github.com/oapi-codegen/runtime/types"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"io"
 	"log"
+	"net"
 	"net/http"
-	"regexp"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+const analyticsCacheTTL = time.Minute
+
+// Options - необязательные настройки HTTP-сервера
+type Options struct {
+	// ValidateResponses включает сверку JSON-ответов со swagger.yaml
+	ValidateResponses bool
+	// OnSpecViolation вызывается для каждого ответа, не прошедшего сверку: тесты через него падают
+	// при расхождении кода и swagger.yaml. nil - расхождение только логируется.
+	OnSpecViolation func(r *http.Request, err error)
+	// ExplorerPath - путь страницы Swagger UI, пустой отключает её
+	ExplorerPath string
+	// PublicURL подставляется в servers отдаваемой спецификации вместо адреса из запроса
+	PublicURL string
+	// LegacyDeprecated - дата, с которой пути без префикса версии устарели, передаётся в заголовке Deprecation.
+	// Нулевая - пути не помечаются устаревшими.
+	LegacyDeprecated time.Time
+	// LegacySunset - дата отключения путей без префикса версии, передаётся в заголовке Sunset.
+	// Нулевая - дата ещё не назначена, отправляется только Deprecation.
+	LegacySunset time.Time
+	// IdempotencyTTL - сколько хранится первый ответ на запрос с Idempotency-Key, 0 - idempotency.DefaultTTL
+	IdempotencyTTL time.Duration
+	// Feed раздаёт события для GET /events, nil - лента отключена
+	Feed *feed.Hub
+	// RateLimiter ограничивает частоту запросов, nil - без ограничений
+	RateLimiter *ratelimit.Limiter
+	// TrustedProxies - прокси, от которых адрес клиента для лимитов берётся из X-Forwarded-For.
+	// Пустой список - учитывается только адрес соединения.
+	TrustedProxies ratelimit.TrustedProxies
+}
+
 type Server struct {
 	handler        http.Handler
 	metricsHandler http.Handler
 	store          storage.Storage
+	analytics      *analytics.Cache
+	health         *health.Checker
 	auth           auth.Authorization
-	logger         *zap.Logger
+	spec           *specValidator
+	publicURL      string
+	idempotencyTTL time.Duration
+	feed           *feed.Hub
+	limiter        *ratelimit.Limiter
+	trustedProxies ratelimit.TrustedProxies
+	onViolation    func(r *http.Request, err error)
+	// stopping закрывается в начале остановки, чтобы долгие потоки завершились, не дожидаясь срока
+	stopping chan struct{}
+	stopOnce sync.Once
+	logger   *zap.Logger
 }
 
+var _ openapi.ServerInterface = (*Server)(nil)
+
 type metricsRouter struct {
 	*mux.Router
 	logger *zap.Logger
@@ -45,70 +103,151 @@ func (w *logWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+func (w *logWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// routeTemplate возвращает шаблон маршрута mux (например /pvz/{pvzId}/close_last_reception),
+// чтобы число серий метрик не зависело от идентификаторов в пути.
+func (s *metricsRouter) routeTemplate(r *http.Request) string {
+	var match mux.RouteMatch
+	if !s.Router.Match(r, &match) || match.Route == nil {
+		return unmatchedRoute
+	}
+	tpl, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return unmatchedRoute
+	}
+	return tpl
+}
+
 func (s *metricsRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	start := time.Now()
-
-	httpRequestsTotal.WithLabelValues(r.Method, r.URL.Path).Inc()
+	route := s.routeTemplate(r)
+
+	requestID := requestid.FromOrNew(r.Header.Get(requestid.Header))
+	w.Header().Set(requestid.Header, requestID)
+	ctx, logger := requestid.With(r.Context(), requestID, s.logger)
+
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+	ctx, span := tracing.Tracer().Start(ctx, r.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.HTTPRoute(route),
+			semconv.URLPath(r.URL.Path),
+			semconv.ClientAddress(r.RemoteAddr),
+			attribute.String("request.id", requestID),
+		),
+	)
+	r = r.WithContext(ctx)
 
 	newW := &logWriter{ResponseWriter: w, code: http.StatusOK}
 
-	timer := prometheus.NewTimer(httpRequestDuration.WithLabelValues(r.Method, "/"))
-	defer timer.ObserveDuration()
+	defer func() {
+		duration := time.Since(start)
+		method, status := normalizeMethod(r.Method), statusClass(newW.code)
 
-	s.Router.ServeHTTP(newW, r)
+		span.SetAttributes(semconv.HTTPResponseStatusCode(newW.code))
+		if newW.code >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(newW.code))
+		}
+		span.End()
+		httpRequestsTotal.WithLabelValues(method, route, status).Inc()
+		httpRequestDuration.WithLabelValues(method, route, status).Observe(duration.Seconds())
+
+		if newW.code >= 200 && newW.code < 400 {
+			logger.Info("HTTP Request",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.String("client_ip", r.RemoteAddr),
+				zap.Int("status", newW.code),
+				zap.Duration("duration", duration),
+			)
+		} else {
+			logger.Warn("HTTP Request",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.String("client_ip", r.RemoteAddr),
+				zap.Int("status", newW.code),
+				zap.Duration("duration", duration),
+			)
+		}
+	}()
 
-	duration := time.Since(start)
-
-	if newW.code >= 200 && newW.code < 400 {
-		s.logger.Info("HTTP Request",
-			zap.String("method", r.Method),
-			zap.String("path", r.URL.Path),
-			zap.String("client_ip", r.RemoteAddr),
-			zap.Int("status", newW.code),
-			zap.Duration("duration", duration),
-		)
-	} else {
-		s.logger.Warn("HTTP Request",
-			zap.String("method", r.Method),
-			zap.String("path", r.URL.Path),
-			zap.String("client_ip", r.RemoteAddr),
-			zap.Int("status", newW.code),
-			zap.Duration("duration", duration),
-		)
-	}
+	s.Router.ServeHTTP(newW, r)
 }
 
-func NewServer(store storage.Storage, authorizator auth.Authorization, logger *zap.Logger) *Server {
+// NewServer регистрирует маршруты по swagger.yaml: пути, методы и разбор параметров берутся
+// из сгенерированного openapi.HandlerWithOptions, а запросы проверяются по спецификации до обработчиков.
+// Операции регистрируются под префиксом каждой версии из apiVersions и без префикса для старых клиентов.
+func NewServer(store storage.Storage, authorizator auth.Authorization, checker *health.Checker, opts Options, logger *zap.Logger) *Server {
 	router := newMetricsRouter(logger)
 	metrics := newMetricsRouter(logger)
 
-	server := &Server{handler: router, metricsHandler: metrics, store: store, auth: authorizator, logger: logger}
-	router.HandleFunc("/ping", server.pingHandler).Methods("GET")
-	router.HandleFunc("/dummyLogin", server.dummyLoginHandler).Methods("POST")
-	router.HandleFunc("/register", server.registerHandler).Methods("POST")
-	router.HandleFunc("/login", server.loginHandler).Methods("POST")
-	router.HandleFunc("/pvz", server.authHandler(server.pvzPostHandler)).Methods("POST")
-	router.HandleFunc("/pvz", server.authHandler(server.pvzGetHandler)).Methods("GET")
-	router.HandleFunc("/pvz/{pvzId}/close_last_reception", server.authHandler(server.closeLastReceptionHandler)).Methods("POST")
-	router.HandleFunc("/pvz/{pvzId}/delete_last_product", server.authHandler(server.deleteLastProductHandler)).Methods("POST")
-	router.HandleFunc("/receptions", server.authHandler(server.receptionsHandler)).Methods("POST")
-	router.HandleFunc("/products", server.authHandler(server.productsHandler)).Methods("POST")
+	spec, err := newSpecValidator(opts.ValidateResponses, deprecation{since: opts.LegacyDeprecated, sunset: opts.LegacySunset})
+	if err != nil {
+		panic(err)
+	}
 
+	server := &Server{handler: router, metricsHandler: metrics, store: store, auth: authorizator, logger: logger,
+		analytics: analytics.NewCache(store, analyticsCacheTTL), health: checker, spec: spec, publicURL: opts.PublicURL,
+		idempotencyTTL: opts.IdempotencyTTL, feed: opts.Feed, limiter: opts.RateLimiter,
+		trustedProxies: opts.TrustedProxies, onViolation: opts.OnSpecViolation, stopping: make(chan struct{})}
+	if server.idempotencyTTL <= 0 {
+		server.idempotencyTTL = idempotency.DefaultTTL
+	}
+
+	router.Use(server.specMiddleware)
+	for _, prefix := range append(apiVersions, "") {
+		openapi.HandlerWithOptions(server, openapi.GorillaServerOptions{
+			BaseURL:    prefix,
+			BaseRouter: router.Router,
+			ErrorHandlerFunc: func(w http.ResponseWriter, r *http.Request, err error) {
+				server.writeError(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			},
+		})
+	}
+	server.registerDocs(router.Router, opts.ExplorerPath)
+
+	router.NotFoundHandler = http.HandlerFunc(server.notFoundHandler)
+	router.MethodNotAllowedHandler = http.HandlerFunc(server.methodNotAllowedHandler)
 	metrics.Handle("/metrics", promhttp.Handler())
 
 	return server
 }
 
-func (s *Server) ListenAndServe(programPort, metricsPort string) error {
+func (s *Server) stopStreams() {
+	s.stopOnce.Do(func() {
+		if s.stopping != nil {
+			close(s.stopping)
+		}
+	})
+}
+
+// ListenAndServe обслуживает запросы, пока не отменён ctx или не упал один из листенеров.
+// После этого оба сервера перестают принимать соединения и дожидаются текущих запросов
+// не дольше drainTimeout, затем контексты оставшихся запросов отменяются.
+func (s *Server) ListenAndServe(ctx context.Context, programPort, metricsPort string, drainTimeout time.Duration) error {
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	baseContext := func(net.Listener) context.Context { return requestCtx }
+
 	programSrv := &http.Server{
-		Addr:    ":" + programPort,
-		Handler: s.handler,
+		Addr:        ":" + programPort,
+		Handler:     s.handler,
+		BaseContext: baseContext,
 	}
 
+	programSrv.RegisterOnShutdown(s.stopStreams)
+
 	metricsSrv := &http.Server{
-		Addr:    ":" + metricsPort,
-		Handler: s.metricsHandler,
+		Addr:        ":" + metricsPort,
+		Handler:     s.metricsHandler,
+		BaseContext: baseContext,
 	}
 
 	errCh := make(chan error, 2)
@@ -121,51 +260,55 @@ func (s *Server) ListenAndServe(programPort, metricsPort string) error {
 		errCh <- metricsSrv.ListenAndServe()
 	}()
 
-	err := <-errCh
-	go func() {
-		err := programSrv.Shutdown(context.Background())
-		if err != nil {
-			s.logger.Error("problem with closing program: " + err.Error())
-		}
-	}()
-	go func() {
-		err := metricsSrv.Shutdown(context.Background())
-		if err != nil {
-			s.logger.Error("problem with closing metrics: " + err.Error())
-		}
-	}()
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errCh:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for name, srv := range map[string]*http.Server{"program": programSrv, "metrics": metricsSrv} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				s.logger.Error("problem with closing "+name, zap.Error(err))
+			}
+		}()
+	}
+	wg.Wait()
 	return err
 }
 
-func (s *Server) authHandler(f func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if len(r.Header.Values("Authorization")) == 0 {
-			w.WriteHeader(http.StatusUnauthorized)
-			_, _ = w.Write([]byte("token missed\n"))
-			return
-		}
-		token := strings.Split(r.Header.Values("Authorization")[0], " ")
-		if len(token) != 2 {
-			w.WriteHeader(http.StatusUnauthorized)
-			_, _ = w.Write([]byte("invalid token header"))
-			return
-		}
-		uuid, err := s.auth.Validate(token[1])
-		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			_, _ = w.Write([]byte("invalid token header"))
-			return
-		}
-		r = r.WithContext(context.WithValue(r.Context(), "uuid", uuid))
-		f(w, r)
+// authenticate проверяет bearer-токен и возвращает идентификатор пользователя.
+// Вызывается для операций, у которых в swagger.yaml указан bearerAuth.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if len(r.Header.Values("Authorization")) == 0 {
+		s.writeError(w, r, http.StatusUnauthorized, codeUnauthorized, "token missed")
+		return "", false
+	}
+	token := strings.Split(r.Header.Values("Authorization")[0], " ")
+	if len(token) != 2 {
+		s.writeError(w, r, http.StatusUnauthorized, codeUnauthorized, "invalid token header")
+		return "", false
 	}
+	uuid, err := s.auth.Validate(token[1])
+	if err != nil {
+		s.writeError(w, r, http.StatusUnauthorized, codeUnauthorized, "invalid token header")
+		return "", false
+	}
+	return uuid, true
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.handler.ServeHTTP(w, r)
 }
 
-func (s *Server) pingHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) Ping(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	_, err := w.Write([]byte("pong"))
 	if err != nil {
@@ -173,369 +316,257 @@ func (s *Server) pingHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Server) dummyLoginHandler(w http.ResponseWriter, r *http.Request) {
-	type RequestData struct {
-		Role string `json:"role"`
-	}
-
-	qq := RequestData{}
-
-	err := s.getBody(r, &qq)
-	if err != nil {
-		s.logger.Error("failed to read request body", zap.Error(err))
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(err.Error()))
-		return
-	}
-
-	if qq.Role != "moderator" && qq.Role != "employee" {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte("\"invalid request\""))
+func (s *Server) DummyLogin(w http.ResponseWriter, r *http.Request) {
+	var req openapi.DummyLoginJSONRequestBody
+	if err := s.getBody(r, &req); err != nil {
+		s.writeError(w, r, http.StatusBadRequest, codeInvalidRequest, invalidBodyMessage)
 		return
 	}
 
-	generate, err := s.auth.Generate("", qq.Role)
+	generate, err := s.auth.Generate("", string(req.Role))
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		_, _ = w.Write([]byte(err.Error()))
+		s.writeInternalError(w, r, err)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "application/json")
-	_, _ = w.Write([]byte("\"" + generate + "\""))
+	s.writeJSON(w, r, http.StatusOK, generate)
 }
 
+// getBody разбирает тело, уже проверенное по схеме в specMiddleware
 func (s *Server) getBody(r *http.Request, RequestData any) error {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		s.logger.Error("failed to read request body", zap.Error(err))
+		s.requestLogger(r).Error("failed to read request body", zap.Error(err))
 		return err
 	}
 
 	err = json.Unmarshal(body, RequestData)
 	if err != nil {
-		s.logger.Error("failed to read request body", zap.Error(err))
+		s.requestLogger(r).Warn("invalid request body", zap.Error(err))
 		return err
 	}
 
 	return nil
 }
 
-func (s *Server) registerHandler(w http.ResponseWriter, r *http.Request) {
-	type RequestData struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-		Role     string `json:"role"`
+func (s *Server) Register(w http.ResponseWriter, r *http.Request) {
+	var req openapi.RegisterJSONRequestBody
+	if err := s.getBody(r, &req); err != nil {
+		s.writeError(w, r, http.StatusBadRequest, codeInvalidRequest, invalidBodyMessage)
+		return
 	}
 
-	qq := RequestData{}
-
-	err := s.getBody(r, &qq)
+	user, err := s.store.CreateUser(r.Context(), string(req.Email), req.Password, []storage.Role{storage.Role(req.Role)})
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(err.Error()))
+		s.writeStorageError(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	if qq.Email == "" || qq.Password == "" ||
-		(qq.Role != "moderator" && qq.Role != "employee") {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte("\"invalid request. Some headers missed\""))
-		return
-	}
+	resp := openapi.User{Id: idPtr(user.UserId), Email: openapi_types.Email(user.Email), Role: openapi.UserRole(user.Roles[0])}
+	s.writeJSON(w, r, http.StatusCreated, resp)
+}
 
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
-	if !emailRegex.MatchString(qq.Email) {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte("\"invalid request. Email invalid\""))
+func (s *Server) Login(w http.ResponseWriter, r *http.Request) {
+	var req openapi.LoginJSONRequestBody
+	if err := s.getBody(r, &req); err != nil {
+		s.writeError(w, r, http.StatusBadRequest, codeInvalidRequest, invalidBodyMessage)
 		return
 	}
 
-	user, err := s.store.CreateUser(qq.Email, qq.Password, []storage.Role{storage.Role(qq.Role)})
+	user, err := s.store.LoginUser(r.Context(), string(req.Email), req.Password)
 	if err != nil {
-		s.logger.Error("failed to create user in storage", zap.Error(err))
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(err.Error()))
+		s.writeStorageError(w, r, http.StatusUnauthorized, err)
 		return
 	}
-
-	type ResponseData struct {
-		Id    string `json:"id"`
-		Email string `json:"email"`
-		Role  string `json:"role"`
+	token, err := s.auth.Generate(user.UserId, string(user.Roles[0]))
+	if err != nil {
+		s.writeInternalError(w, r, err)
+		return
 	}
 
-	answer := ResponseData{Id: user.UserId, Email: user.Email, Role: string(user.Roles[0])}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	err = json.NewEncoder(w).Encode(answer)
+	s.writeJSON(w, r, http.StatusOK, token)
 }
 
-func (s *Server) loginHandler(w http.ResponseWriter, r *http.Request) {
-	type RequestData struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
-
-	qq := RequestData{}
-
-	err := s.getBody(r, &qq)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(err.Error()))
+func (s *Server) CreatePvz(w http.ResponseWriter, r *http.Request, _ openapi.CreatePvzParams) {
+	var req openapi.CreatePvzJSONRequestBody
+	if err := s.getBody(r, &req); err != nil {
+		s.writeError(w, r, http.StatusBadRequest, codeInvalidRequest, invalidBodyMessage)
 		return
 	}
 
-	if qq.Email == "" || qq.Password == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte("\"invalid request. Some headers missed\""))
-		return
+	params := storage.PvzInfo{City: storage.City(req.City), RegistrationDate: req.RegistrationDate}
+	if req.Id != nil {
+		id := req.Id.String()
+		params.PvzId = &id
 	}
 
-	user, err := s.store.LoginUser(qq.Email, qq.Password)
+	pvz, err := s.store.CreatePvz(r.Context(), r.Context().Value("uuid").(string), params)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		_, _ = w.Write([]byte(err.Error()))
-		return
-	}
-	token, err := s.auth.Generate(user.UserId, string(user.Roles[0]))
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(err.Error()))
+		s.writeStorageError(w, r, http.StatusForbidden, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(token)
+	setETag(w, pvz.Version)
+	s.writeJSON(w, r, http.StatusCreated, newPvz(*pvz))
 }
 
-func (s *Server) pvzPostHandler(w http.ResponseWriter, r *http.Request) {
-	type RequestData struct {
-		Id               string    `json:"id"`
-		RegistrationDate time.Time `json:"registrationDate"`
-		City             string    `json:"city"`
-	}
+// pvzFilter переводит параметры GET /pvz в фильтр хранилища. Диапазоны и перечисления
+// уже проверены по swagger.yaml, значения дат и курсор дополнительно проверяет хранилище.
+func pvzFilter(params openapi.ListPvzParams) storage.PvzFilter {
+	filter := storage.PvzFilter{
+		StartDate:     dateParam(params.StartDate),
+		EndDate:       dateParam(params.EndDate),
+		Page:          1,
+		Limit:         10,
+		Cursor:        stringParam(params.Cursor),
+		City:          storage.City(stringParam(params.City)),
+		OpenReception: params.OpenReception,
+		ProductType:   stringParam(params.ProductType),
+		Sort:          storage.PvzSort(stringParam(params.Sort)),
+		Ascending:     params.Order != nil && *params.Order == openapi.Asc,
+	}
+	if params.Page != nil {
+		filter.Page = *params.Page
+	}
+	if params.Limit != nil {
+		filter.Limit = *params.Limit
+	}
+	if params.Author != nil {
+		filter.Author = params.Author.String()
+	}
+	return filter
+}
 
-	qq := RequestData{}
+func (s *Server) ListPvz(w http.ResponseWriter, r *http.Request, params openapi.ListPvzParams) {
+	filter := pvzFilter(params)
 
-	err := s.getBody(r, &qq)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(err.Error()))
+	if acceptsNDJSON(r) {
+		s.streamPvz(w, r, filter)
 		return
 	}
 
-	if qq.City != "Москва" && qq.City != "Санкт-Петербург" && qq.City != "Казань" {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte("\"invalid request. Some headers missed\""))
+	pvzs, err := s.store.GetPvzInfo(r.Context(), filter)
+	if err != nil {
+		s.writeStorageError(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	meow := storage.PvzInfo{}
-	if (qq.RegistrationDate != time.Time{}) {
-		meow.RegistrationDate = &qq.RegistrationDate
-	}
-	if qq.Id != "" {
-		meow.PvzId = &qq.Id
+	resp := make([]openapi.PvzListItem, 0, len(pvzs.Items))
+	for _, pvz := range pvzs.Items {
+		resp = append(resp, newPvzListItem(pvz))
 	}
-	if qq.City == "" {
-		s.logger.Error("failed to create pvz in storage", zap.Error(err))
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte("Please provide a valid city"))
-		return
-	}
-	meow.City = storage.City(qq.City)
 
-	pvz, err := s.store.CreatePvz(r.Context().Value("uuid").(string), meow)
-	if err != nil {
-
-		w.WriteHeader(http.StatusForbidden)
-		_, _ = w.Write([]byte(err.Error()))
-		return
+	if pvzs.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", pvzs.NextCursor)
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
 
-	type ResponseData struct {
-		Id               string    `json:"id"`
-		RegistrationDate time.Time `json:"registrationDate"`
-		City             string    `json:"city"`
+	_, span := tracing.Tracer().Start(r.Context(), "encode response",
+		trace.WithAttributes(attribute.Int("pvz.count", len(resp))))
+	err = json.NewEncoder(w).Encode(resp)
+	span.End()
+	if err != nil {
+		s.requestLogger(r).Error("failed to write response", zap.Error(err))
 	}
+}
 
-	resp := ResponseData{Id: *pvz.PvzId, RegistrationDate: *pvz.RegistrationDate, City: string(pvz.City)}
-
-	pvzCreatedTotal.Inc()
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	err = json.NewEncoder(w).Encode(resp)
+func (s *Server) GetPvz(w http.ResponseWriter, r *http.Request, pvzId openapi_types.UUID) {
+	pvz, err := s.store.GetPvzById(r.Context(), pvzId.String())
 	if err != nil {
-		s.logger.Error("failed to write response", zap.Error(err))
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte("response cannot be converted to json. Something went wrong"))
+		s.writeStorageError(w, r, http.StatusBadRequest, err)
 		return
 	}
-
+	setETag(w, pvz.Version)
+	s.writeJSON(w, r, http.StatusOK, newPvz(*pvz))
 }
 
-func (s *Server) pvzGetHandler(w http.ResponseWriter, r *http.Request) {
-	start := r.URL.Query().Get("startDate")
-	end := r.URL.Query().Get("endDate")
-	page := 1
-	limit := 10
-	var err error
-	if r.URL.Query().Get("page") != "" {
-		page, err = strconv.Atoi(r.URL.Query().Get("page"))
-	}
-	if r.URL.Query().Get("limit") != "" {
-		limit, err = strconv.Atoi(r.URL.Query().Get("limit"))
-	}
+func (s *Server) GetOpenReception(w http.ResponseWriter, r *http.Request, pvzId openapi_types.UUID) {
+	reception, err := s.store.GetOpenReception(r.Context(), pvzId.String())
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte("Please provide a valid page and limit"))
+		s.writeStorageError(w, r, http.StatusBadRequest, err)
 		return
 	}
-
-	if start == "" {
-		start = time.Time{}.Format(time.RFC3339)
+	if reception == nil {
+		s.writeError(w, r, http.StatusNotFound, codeNotFound, "no open reception in pvz")
+		return
 	}
-
-	if end == "" {
-		end = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC).Format(time.RFC3339)
+	products := make([]openapi.Product, 0, len(reception.Products))
+	for _, p := range reception.Products {
+		products = append(products, newProduct(p))
 	}
+	setETag(w, reception.Version)
+	s.writeJSON(w, r, http.StatusOK, openapi.ReceptionWithProducts{Reception: newReception(*reception), Products: products})
+}
 
-	resp, err := s.store.GetPvzInfo(start, end, page, limit)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(err.Error()))
+func (s *Server) CloseLastReception(w http.ResponseWriter, r *http.Request, pvzId openapi_types.UUID, params openapi.CloseLastReceptionParams) {
+	version, ok := s.expectedVersion(w, r, params.IfMatch)
+	if !ok {
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(resp)
+	reception, err := s.store.CloseLastReception(r.Context(), pvzId.String(), version)
 	if err != nil {
-		s.logger.Error("failed to write response", zap.Error(err))
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(err.Error()))
+		s.writeStorageError(w, r, http.StatusBadRequest, err)
 		return
 	}
+	setETag(w, reception.Version)
+	s.writeJSON(w, r, http.StatusOK, newReception(*reception))
 }
 
-func (s *Server) closeLastReceptionHandler(w http.ResponseWriter, r *http.Request) {
-
-	PvzId := mux.Vars(r)["pvzId"]
-
-	_, err := s.store.CloseLastReception(PvzId)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(err.Error()))
+func (s *Server) DeleteLastProduct(w http.ResponseWriter, r *http.Request, pvzId openapi_types.UUID, params openapi.DeleteLastProductParams) {
+	expected, ok := s.expectedVersion(w, r, params.IfMatch)
+	if !ok {
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-}
-
-func (s *Server) deleteLastProductHandler(w http.ResponseWriter, r *http.Request) {
-	PvzId := mux.Vars(r)["pvzId"]
-
-	err := s.store.DeleteLastProduct(PvzId)
+	version, err := s.store.DeleteLastProduct(r.Context(), pvzId.String(), expected)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(err.Error()))
+		s.writeStorageError(w, r, http.StatusBadRequest, err)
 		return
 	}
 
+	setETag(w, version)
 	w.WriteHeader(http.StatusOK)
 }
 
-func (s *Server) receptionsHandler(w http.ResponseWriter, r *http.Request) {
-	type RequestData struct {
-		PvzId string `json:"pvzId"`
-	}
-	qq := RequestData{}
-
-	err := s.getBody(r, &qq)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(err.Error()))
+func (s *Server) CreateReception(w http.ResponseWriter, r *http.Request, params openapi.CreateReceptionParams) {
+	var req openapi.CreateReceptionJSONRequestBody
+	if err := s.getBody(r, &req); err != nil {
+		s.writeError(w, r, http.StatusBadRequest, codeInvalidRequest, invalidBodyMessage)
 		return
 	}
 
-	reception, err := s.store.OpenReception(r.Context().Value("uuid").(string), qq.PvzId)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(err.Error()))
+	version, ok := s.expectedVersion(w, r, params.IfMatch)
+	if !ok {
 		return
 	}
 
-	type ResponseData struct {
-		Id       string    `json:"id"`
-		DateTime time.Time `json:"dateTime"`
-		PvzId    string    `json:"pvzId"`
-		status   string
-	}
-	resp := ResponseData{Id: reception.ReceptionId, DateTime: reception.DateTime, PvzId: reception.PvzId, status: "in_progress"}
-
-	receptionsTotal.Inc()
-
-	w.WriteHeader(http.StatusCreated)
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(resp)
+	reception, err := s.store.OpenReception(r.Context(), r.Context().Value("uuid").(string), req.PvzId.String(), version)
 	if err != nil {
-		s.logger.Error("failed to write response", zap.Error(err))
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(err.Error()))
+		s.writeStorageError(w, r, http.StatusBadRequest, err)
 		return
 	}
+	setETag(w, reception.Version)
+
+	s.writeJSON(w, r, http.StatusCreated, newReception(*reception))
 }
 
-func (s *Server) productsHandler(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(err.Error()))
-		return
-	}
-	type RequestData struct {
-		PvzId string `json:"pvzId"`
-		Type  string `json:"type"`
-	}
-	qq := RequestData{}
-	err = json.Unmarshal(body, &qq)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(err.Error()))
+func (s *Server) AddProduct(w http.ResponseWriter, r *http.Request, params openapi.AddProductParams) {
+	var req openapi.AddProductJSONRequestBody
+	if err := s.getBody(r, &req); err != nil {
+		s.writeError(w, r, http.StatusBadRequest, codeInvalidRequest, invalidBodyMessage)
 		return
 	}
-	if qq.PvzId == "" ||
-		(qq.Type != "электроника" &&
-			qq.Type != "одежда" &&
-			qq.Type != "обувь") {
-	}
 
-	product, err := s.store.AddProduct(qq.PvzId, r.Context().Value("uuid").(string), qq.Type)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(err.Error()))
+	version, ok := s.expectedVersion(w, r, params.IfMatch)
+	if !ok {
 		return
 	}
 
-	type ResponseData struct {
-		Id          string    `json:"id"`
-		DateTime    time.Time `json:"dateTime"`
-		Type        string    `json:"type"`
-		ReceptionId string    `json:"receptionId"`
-	}
-	resp := ResponseData{Id: product.ProductId, DateTime: product.DateTime, Type: product.ProductType, ReceptionId: product.ReceptionId}
-
-	productAddedTotal.Inc()
-
-	w.WriteHeader(http.StatusCreated)
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(resp)
+	product, err := s.store.AddProduct(r.Context(), req.PvzId.String(), r.Context().Value("uuid").(string), string(req.Type), version)
 	if err != nil {
-		s.logger.Error("failed to write response", zap.Error(err))
-		w.WriteHeader(http.StatusBadRequest)
-		_, _ = w.Write([]byte(err.Error()))
+		s.writeStorageError(w, r, http.StatusBadRequest, err)
 		return
 	}
+	setETag(w, product.ReceptionVersion)
+
+	s.writeJSON(w, r, http.StatusCreated, newProduct(*product))
 }
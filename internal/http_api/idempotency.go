@@ -0,0 +1,109 @@
+package http_api
+
+import (
+	"avito_intr/internal/idempotency"
+	"avito_intr/internal/storage"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/getkin/kin-openapi/openapi3"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// replayedHeaders - заголовки, которые обработчики ставят на ответ и которые нужно сохранить для повтора.
+// Остальные заголовки (X-Request-ID, Deprecation, X-RateLimit-*) ставятся до обработчика и у повтора свои.
+var replayedHeaders = []string{"ETag", "Location"}
+
+// acceptsIdempotencyKey сообщает, что операция объявляет в swagger.yaml заголовок Idempotency-Key
+func acceptsIdempotencyKey(op *openapi3.Operation) bool {
+	return op.Parameters.GetByInAndName(openapi3.ParameterInHeader, idempotency.Header) != nil
+}
+
+// canonicalJSON приводит тело к виду, не зависящему от пробелов и порядка полей,
+// чтобы повтор, заново сериализованный клиентом, не считался другим запросом.
+func canonicalJSON(body []byte) []byte {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// serveIdempotent выполняет запрос с ключом не больше одного раза для вызывающего.
+// Повтор с тем же ключом и телом получает сохранённый первый ответ.
+func (s *Server) serveIdempotent(w http.ResponseWriter, r *http.Request, next http.Handler, operation, key string) {
+	if !idempotency.ValidKey(key) {
+		s.writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "Idempotency-Key must be printable ASCII")
+		return
+	}
+	caller, _ := r.Context().Value("uuid").(string)
+	if caller == "" {
+		// у всех токенов из /dummyLogin пустой идентификатор, их ключи пересекались бы между клиентами
+		s.writeError(w, r, http.StatusBadRequest, codeInvalidRequest,
+			"Idempotency-Key requires a token of a registered user, not one from /dummyLogin")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.writeInternalError(w, r, err)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	fingerprint := idempotency.Fingerprint(operation, canonicalJSON(body))
+	stored, err := idempotency.Begin(r.Context(), s.store, "http", caller, key, fingerprint, s.idempotencyTTL)
+	switch {
+	case errors.Is(err, storage.ErrIdempotencyKeyInUse):
+		w.Header().Set("Retry-After", "1")
+		s.writeError(w, r, http.StatusConflict, codeIdempotencyKeyInUse, "request with this Idempotency-Key is still in progress")
+		return
+	case errors.Is(err, storage.ErrIdempotencyKeyReused):
+		s.writeError(w, r, http.StatusUnprocessableEntity, codeIdempotencyKeyReused,
+			"Idempotency-Key was already used for a different request")
+		return
+	case err != nil:
+		s.writeInternalError(w, r, err)
+		return
+	case stored != nil:
+		s.replay(w, r, stored)
+		return
+	}
+
+	rec := &specRecorder{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(rec, r)
+	resp := storage.IdempotentResponse{Status: rec.status, ContentType: rec.Header().Get("Content-Type")}
+	for _, name := range replayedHeaders {
+		if v := rec.Header().Get(name); v != "" {
+			if resp.Headers == nil {
+				resp.Headers = map[string]string{}
+			}
+			resp.Headers[name] = v
+		}
+	}
+	if rec.body != nil {
+		resp.Body = rec.body.Bytes()
+	}
+	idempotency.Finish(r.Context(), s.store, caller, key, resp, rec.status < http.StatusInternalServerError, s.logger)
+}
+
+func (s *Server) replay(w http.ResponseWriter, r *http.Request, stored *storage.IdempotentResponse) {
+	if stored.ContentType != "" {
+		w.Header().Set("Content-Type", stored.ContentType)
+	}
+	for name, v := range stored.Headers {
+		w.Header().Set(name, v)
+	}
+	w.Header().Set(idempotency.ReplayedHeader, "true")
+	w.Header().Set("Content-Length", strconv.Itoa(len(stored.Body)))
+	w.WriteHeader(stored.Status)
+	if _, err := w.Write(stored.Body); err != nil {
+		s.requestLogger(r).Error("failed to write response", zap.Error(err))
+	}
+}
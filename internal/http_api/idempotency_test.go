@@ -0,0 +1,180 @@
+package http_api
+
+import (
+	"avito_intr/internal/http_api/openapi"
+	"avito_intr/internal/idempotency"
+	"avito_intr/internal/storage"
+	"context"
+	"encoding/json"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type idempotencyEntry struct {
+	fingerprint string
+	resp        *storage.IdempotentResponse
+}
+
+// memoryStore хранит ключи в памяти и считает приёмки, реально открытые хранилищем
+type memoryStore struct {
+	storage.Storage
+	mu         sync.Mutex
+	keys       map[string]idempotencyEntry
+	receptions int
+	fail       bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{keys: map[string]idempotencyEntry{}}
+}
+
+func (m *memoryStore) OpenReception(ctx context.Context, author, pvz string, _ int64) (*storage.ReceptionInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail {
+		return nil, context.DeadlineExceeded
+	}
+	if m.receptions > 0 {
+		return nil, storage.ReceptionFailed{Message: "opened reception already exists"}
+	}
+	m.receptions++
+	return &storage.ReceptionInfo{ReceptionId: "44444444-4444-4444-4444-444444444444", PvzId: pvz,
+		DateTime: time.Date(2025, 4, 15, 12, 0, 0, 0, time.UTC), Status: storage.Active, Version: 7}, nil
+}
+
+func (m *memoryStore) BeginIdempotent(ctx context.Context, caller, key, fingerprint string, ttl time.Duration) (*storage.IdempotentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.keys[caller+"/"+key]
+	switch {
+	case !ok:
+		m.keys[caller+"/"+key] = idempotencyEntry{fingerprint: fingerprint}
+		return nil, nil
+	case entry.fingerprint != fingerprint:
+		return nil, storage.ErrIdempotencyKeyReused
+	case entry.resp == nil:
+		return nil, storage.ErrIdempotencyKeyInUse
+	}
+	return entry.resp, nil
+}
+
+func (m *memoryStore) CompleteIdempotent(ctx context.Context, caller, key string, resp storage.IdempotentResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.keys[caller+"/"+key]
+	entry.resp = &resp
+	m.keys[caller+"/"+key] = entry
+	return nil
+}
+
+func (m *memoryStore) AbortIdempotent(ctx context.Context, caller, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.keys, caller+"/"+key)
+	return nil
+}
+
+func postReception(s *Server, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/v1/receptions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer valid")
+	if key != "" {
+		req.Header.Set(idempotency.Header, key)
+	}
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	store := newMemoryStore()
+	s := NewServer(store, stubAuth{}, nil, Options{}, zap.NewNop())
+
+	first := postReception(s, "k1", `{"pvzId":"11111111-1111-1111-1111-111111111111"}`)
+	if first.Code != http.StatusCreated || first.Header().Get(idempotency.ReplayedHeader) != "" {
+		t.Fatalf("first request = %d, %s", first.Code, first.Body.String())
+	}
+	// тот же запрос, заново сериализованный клиентом
+	retry := postReception(s, "k1", `{ "pvzId": "11111111-1111-1111-1111-111111111111" }`)
+	if retry.Code != http.StatusCreated || retry.Header().Get(idempotency.ReplayedHeader) != "true" {
+		t.Fatalf("retry = %d, %s", retry.Code, retry.Body.String())
+	}
+	if retry.Body.String() != first.Body.String() || store.receptions != 1 {
+		t.Errorf("retry must return the stored response without opening another reception: %s", retry.Body.String())
+	}
+	// по ETag повтора клиент продолжает работу с If-Match, как после первого ответа
+	if etag := retry.Header().Get("ETag"); etag != `"7"` || etag != first.Header().Get("ETag") {
+		t.Errorf("retry ETag = %q, first ETag = %q", etag, first.Header().Get("ETag"))
+	}
+
+	without := postReception(s, "", `{"pvzId":"11111111-1111-1111-1111-111111111111"}`)
+	if without.Code != http.StatusBadRequest {
+		t.Errorf("request without key must be executed again, got %d", without.Code)
+	}
+}
+
+func TestIdempotencyKeyConflicts(t *testing.T) {
+	store := newMemoryStore()
+	s := NewServer(store, stubAuth{}, nil, Options{}, zap.NewNop())
+	postReception(s, "k1", `{"pvzId":"11111111-1111-1111-1111-111111111111"}`)
+
+	rr := postReception(s, "k1", `{"pvzId":"22222222-2222-2222-2222-222222222222"}`)
+	if rr.Code != http.StatusUnprocessableEntity || errorCode(t, rr) != codeIdempotencyKeyReused {
+		t.Errorf("other payload = %d, %s", rr.Code, rr.Body.String())
+	}
+
+	store.keys["33333333-3333-3333-3333-333333333333/k2"] = idempotencyEntry{fingerprint: "running"}
+	rr = postReception(s, "k2", `{"pvzId":"11111111-1111-1111-1111-111111111111"}`)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("other payload while in progress = %d", rr.Code)
+	}
+
+	fingerprint := idempotency.Fingerprint("CreateReception", []byte(`{"pvzId":"11111111-1111-1111-1111-111111111111"}`))
+	store.keys["33333333-3333-3333-3333-333333333333/k3"] = idempotencyEntry{fingerprint: fingerprint}
+	rr = postReception(s, "k3", `{"pvzId":"11111111-1111-1111-1111-111111111111"}`)
+	if rr.Code != http.StatusConflict || errorCode(t, rr) != codeIdempotencyKeyInUse || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("in progress = %d, %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestIdempotencyKeyRejectedForDummyToken(t *testing.T) {
+	store := newMemoryStore()
+	s := NewServer(store, stubAuth{}, nil, Options{}, zap.NewNop())
+	req := httptest.NewRequest("POST", "/v1/receptions", strings.NewReader(`{"pvzId":"11111111-1111-1111-1111-111111111111"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer dummy")
+	req.Header.Set(idempotency.Header, "k1")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != codeInvalidRequest || store.receptions != 0 || len(store.keys) != 0 {
+		t.Errorf("dummy token with key = %d, %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestIdempotencyKeyReleasedOnInternalError(t *testing.T) {
+	store := newMemoryStore()
+	store.fail = true
+	s := NewServer(store, stubAuth{}, nil, Options{}, zap.NewNop())
+
+	if rr := postReception(s, "k1", `{"pvzId":"11111111-1111-1111-1111-111111111111"}`); rr.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d", rr.Code)
+	}
+	store.fail = false
+	if rr := postReception(s, "k1", `{"pvzId":"11111111-1111-1111-1111-111111111111"}`); rr.Code != http.StatusCreated {
+		t.Errorf("retry after internal error = %d, want the request to be executed", rr.Code)
+	}
+}
+
+func errorCode(t *testing.T, rr *httptest.ResponseRecorder) openapi.ErrorCode {
+	t.Helper()
+	var body openapi.Error
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return body.Code
+}
@@ -1,49 +1,60 @@
 package http_api
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"strconv"
+)
+
+const unmatchedRoute = "unmatched"
+
+// Интервалы времени рассчитаны на ответы быстрее 100 мс, хвост - до нескольких секунд
+var latencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
 
 var httpRequestsTotal = prometheus.NewCounterVec(
 	prometheus.CounterOpts{
 		Name: "http_requests_total",
 		Help: "Total number of HTTP requests",
 	},
-	[]string{"method", "endpoint"},
+	[]string{"method", "route", "status"},
 )
 
 var httpRequestDuration = prometheus.NewHistogramVec(
 	prometheus.HistogramOpts{
 		Name:    "http_request_duration_seconds",
 		Help:    "HTTP request duration in seconds",
-		Buckets: []float64{0.1, 0.5, 1, 2, 5}, // Интервалы времени
+		Buckets: latencyBuckets,
 	},
-	[]string{"method", "endpoint"},
+	[]string{"method", "route", "status"},
 )
 
-var pvzCreatedTotal = prometheus.NewCounter(
+// httpSpecViolationsTotal считает ответы, не прошедшие проверку по swagger.yaml
+// (только при включённой проверке ответов)
+var httpSpecViolationsTotal = prometheus.NewCounterVec(
 	prometheus.CounterOpts{
-		Name: "pvz_created_total",
-		Help: "Total number of created pickup points (PVZ)",
+		Name: "http_openapi_response_violations_total",
+		Help: "Total number of HTTP responses that do not match the OpenAPI spec",
 	},
+	[]string{"method", "route"},
 )
 
-var receptionsTotal = prometheus.NewCounter(
-	prometheus.CounterOpts{
-		Name: "receptions_created_total",
-		Help: "Total number of created order acceptances",
-	},
-)
+// normalizeMethod не даёт произвольным методам из запросов порождать новые серии.
+func normalizeMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
 
-var productAddedTotal = prometheus.NewCounter(
-	prometheus.CounterOpts{
-		Name: "product_added_total",
-		Help: "Total number of added products",
-	},
-)
+// statusClass сворачивает код ответа до класса: 200 -> 2xx, 404 -> 4xx.
+func statusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
+}
 
 func init() {
 	prometheus.MustRegister(httpRequestsTotal)
 	prometheus.MustRegister(httpRequestDuration)
-	prometheus.MustRegister(pvzCreatedTotal)
-	prometheus.MustRegister(receptionsTotal)
-	prometheus.MustRegister(productAddedTotal)
+	prometheus.MustRegister(httpSpecViolationsTotal)
 }
@@ -0,0 +1,107 @@
+package http_api
+
+import (
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsRouterLabels(t *testing.T) {
+	httpRequestsTotal.Reset()
+	httpRequestDuration.Reset()
+	router := newMetricsRouter(zap.NewNop())
+	router.HandleFunc("/pvz/{pvzId}/close_last_reception", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}).Methods("POST")
+
+	for _, id := range []string{"11111111-1111-1111-1111-111111111111", "22222222-2222-2222-2222-222222222222"} {
+		req := httptest.NewRequest("POST", "/pvz/"+id+"/close_last_reception", nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/no/such/route", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/no/such/route", nil))
+
+	route := "/pvz/{pvzId}/close_last_reception"
+	if got := testutil.ToFloat64(httpRequestsTotal.WithLabelValues("POST", route, "4xx")); got != 2 {
+		t.Errorf("requests for %s = %v, want 2", route, got)
+	}
+	if got := testutil.ToFloat64(httpRequestsTotal.WithLabelValues("GET", unmatchedRoute, "4xx")); got != 1 {
+		t.Errorf("unmatched requests = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(httpRequestsTotal.WithLabelValues("OTHER", unmatchedRoute, "4xx")); got != 1 {
+		t.Errorf("requests with unknown method = %v, want 1", got)
+	}
+	if got := testutil.CollectAndCount(httpRequestDuration); got != 3 {
+		t.Errorf("duration series = %d, want 3", got)
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	for code, want := range map[int]string{200: "2xx", 201: "2xx", 304: "3xx", 404: "4xx", 503: "5xx"} {
+		if got := statusClass(code); got != want {
+			t.Errorf("statusClass(%d) = %s, want %s", code, got, want)
+		}
+	}
+}
+
+func TestMetricsRouterTraceparent(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	router := newMetricsRouter(zap.NewNop())
+	router.HandleFunc("/pvz", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+
+	req := httptest.NewRequest("GET", "/pvz", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("ended spans = %d, want 1", len(spans))
+	}
+	if got := spans[0].Name(); got != "GET /pvz" {
+		t.Errorf("span name = %q, want %q", got, "GET /pvz")
+	}
+	if got := spans[0].SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace id = %s, want the one from traceparent", got)
+	}
+	if got := spans[0].Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("parent span id = %s, want 00f067aa0ba902b7", got)
+	}
+}
+
+func TestMetricsRouterRequestID(t *testing.T) {
+	s := &Server{logger: zap.NewNop()}
+	router := newMetricsRouter(zap.NewNop())
+	router.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		s.writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "boom")
+	})
+
+	req := httptest.NewRequest("GET", "/fail", nil)
+	req.Header.Set("X-Request-ID", "client-id-1")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if got := rr.Header().Get("X-Request-ID"); got != "client-id-1" {
+		t.Errorf("X-Request-ID = %q, want client-id-1", got)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, `"requestId":"client-id-1"`) {
+		t.Errorf("error body %s does not contain request id", body)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/fail", nil))
+	if rr.Header().Get("X-Request-ID") == "" {
+		t.Error("X-Request-ID is not generated")
+	}
+}
@@ -0,0 +1,78 @@
+package http_api
+
+import (
+	"avito_intr/internal/http_api/openapi"
+	"avito_intr/internal/storage"
+	"github.com/google/uuid"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+	"time"
+)
+
+// Ответы собираются из моделей, сгенерированных по swagger.yaml, чтобы код и контракт не расходились.
+
+// parseID переводит идентификатор из хранилища в тип модели. В базе все идентификаторы - uuid.
+func parseID(id string) openapi_types.UUID {
+	parsed, _ := uuid.Parse(id)
+	return parsed
+}
+
+func idPtr(id string) *openapi_types.UUID {
+	parsed := parseID(id)
+	return &parsed
+}
+
+// dateParam возвращает дату из параметра запроса в формате, который ожидает хранилище
+func dateParam(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func stringParam[T ~string](v *T) string {
+	if v == nil {
+		return ""
+	}
+	return string(*v)
+}
+
+func newPvz(info storage.PvzInfo) openapi.PVZ {
+	pvz := openapi.PVZ{City: openapi.PVZCity(info.City), RegistrationDate: info.RegistrationDate}
+	if info.Version != 0 {
+		pvz.Version = &info.Version
+	}
+	if info.PvzId != nil {
+		pvz.Id = idPtr(*info.PvzId)
+	}
+	return pvz
+}
+
+func newReception(rec storage.ReceptionInfo) openapi.Reception {
+	reception := openapi.Reception{Id: idPtr(rec.ReceptionId), DateTime: rec.DateTime, PvzId: parseID(rec.PvzId),
+		Status: openapi.ReceptionStatus(rec.Status)}
+	if rec.Version != 0 {
+		reception.Version = &rec.Version
+	}
+	return reception
+}
+
+func newProduct(p storage.Product) openapi.Product {
+	dateTime := p.DateTime
+	return openapi.Product{Id: idPtr(p.ProductId), DateTime: &dateTime, Type: openapi.ProductType(p.ProductType),
+		ReceptionId: parseID(p.ReceptionId)}
+}
+
+func newPvzListItem(info storage.PvzInfo) openapi.PvzListItem {
+	item := openapi.PvzListItem{
+		Pvz:        newPvz(info),
+		Receptions: make([]openapi.ReceptionWithProducts, 0, len(info.Receptions)),
+	}
+	for _, rec := range info.Receptions {
+		products := make([]openapi.Product, 0, len(rec.Products))
+		for _, p := range rec.Products {
+			products = append(products, newProduct(p))
+		}
+		item.Receptions = append(item.Receptions, openapi.ReceptionWithProducts{Reception: newReception(rec), Products: products})
+	}
+	return item
+}
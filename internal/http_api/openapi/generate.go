@@ -0,0 +1,5 @@
+// Package openapi содержит модели и интерфейс HTTP-сервера, сгенерированные из swagger.yaml.
+// После правки спецификации нужно выполнить go generate ./... и закоммитить результат.
+package openapi
+
+//go:generate go tool oapi-codegen -config config.yaml ../../../swagger.yaml
@@ -0,0 +1,1903 @@
+// Package openapi provides primitives to interact with the openapi HTTP API.
+//
+// Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.5.0 DO NOT EDIT.
+package openapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gorilla/mux"
+	"github.com/oapi-codegen/runtime"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+const (
+	BearerAuthScopes   = "bearerAuth.Scopes"
+	EventsCookieScopes = "eventsCookie.Scopes"
+)
+
+// Defines values for DailyProductsCity.
+const (
+	DailyProductsCityКазань         DailyProductsCity = "Казань"
+	DailyProductsCityМосква         DailyProductsCity = "Москва"
+	DailyProductsCityСанктПетербург DailyProductsCity = "Санкт-Петербург"
+)
+
+// Defines values for DailyProductsType.
+const (
+	DailyProductsTypeОбувь       DailyProductsType = "обувь"
+	DailyProductsTypeОдежда      DailyProductsType = "одежда"
+	DailyProductsTypeЭлектроника DailyProductsType = "электроника"
+)
+
+// Defines values for ErrorCode.
+const (
+	ErrorCodeForbidden            ErrorCode = "forbidden"
+	ErrorCodeIdempotencyKeyInUse  ErrorCode = "idempotency_key_in_use"
+	ErrorCodeIdempotencyKeyReused ErrorCode = "idempotency_key_reused"
+	ErrorCodeInternalError        ErrorCode = "internal_error"
+	ErrorCodeInvalidRequest       ErrorCode = "invalid_request"
+	ErrorCodeLoginFailed          ErrorCode = "login_failed"
+	ErrorCodeMethodNotAllowed     ErrorCode = "method_not_allowed"
+	ErrorCodeNotFound             ErrorCode = "not_found"
+	ErrorCodeOperationFailed      ErrorCode = "operation_failed"
+	ErrorCodePreconditionFailed   ErrorCode = "precondition_failed"
+	ErrorCodeRateLimited          ErrorCode = "rate_limited"
+	ErrorCodeUnauthorized         ErrorCode = "unauthorized"
+)
+
+// Defines values for HealthCheckStatus.
+const (
+	HealthCheckStatusFail HealthCheckStatus = "fail"
+	HealthCheckStatusOk   HealthCheckStatus = "ok"
+)
+
+// Defines values for PVZCity.
+const (
+	PVZCityКазань         PVZCity = "Казань"
+	PVZCityМосква         PVZCity = "Москва"
+	PVZCityСанктПетербург PVZCity = "Санкт-Петербург"
+)
+
+// Defines values for ProductType.
+const (
+	ProductTypeОбувь       ProductType = "обувь"
+	ProductTypeОдежда      ProductType = "одежда"
+	ProductTypeЭлектроника ProductType = "электроника"
+)
+
+// Defines values for ReadinessStatus.
+const (
+	ReadinessStatusFail ReadinessStatus = "fail"
+	ReadinessStatusOk   ReadinessStatus = "ok"
+)
+
+// Defines values for ReceptionStatus.
+const (
+	Close      ReceptionStatus = "close"
+	InProgress ReceptionStatus = "in_progress"
+)
+
+// Defines values for UserRole.
+const (
+	UserRoleEmployee  UserRole = "employee"
+	UserRoleModerator UserRole = "moderator"
+)
+
+// Defines values for WebhookDeliveryStatus.
+const (
+	WebhookDeliveryStatusDead      WebhookDeliveryStatus = "dead"
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "pending"
+)
+
+// Defines values for WebhookEventType.
+const (
+	ProductAdded    WebhookEventType = "product.added"
+	ProductDeleted  WebhookEventType = "product.deleted"
+	PvzCreated      WebhookEventType = "pvz.created"
+	ReceptionClosed WebhookEventType = "reception.closed"
+	ReceptionOpened WebhookEventType = "reception.opened"
+)
+
+// Defines values for GetAnalyticsParamsCity.
+const (
+	GetAnalyticsParamsCityКазань         GetAnalyticsParamsCity = "Казань"
+	GetAnalyticsParamsCityМосква         GetAnalyticsParamsCity = "Москва"
+	GetAnalyticsParamsCityСанктПетербург GetAnalyticsParamsCity = "Санкт-Петербург"
+)
+
+// Defines values for DummyLoginJSONBodyRole.
+const (
+	DummyLoginJSONBodyRoleEmployee  DummyLoginJSONBodyRole = "employee"
+	DummyLoginJSONBodyRoleModerator DummyLoginJSONBodyRole = "moderator"
+)
+
+// Defines values for StreamEventsParamsCity.
+const (
+	StreamEventsParamsCityКазань         StreamEventsParamsCity = "Казань"
+	StreamEventsParamsCityМосква         StreamEventsParamsCity = "Москва"
+	StreamEventsParamsCityСанктПетербург StreamEventsParamsCity = "Санкт-Петербург"
+)
+
+// Defines values for ExportReceptionsParamsFormat.
+const (
+	Csv  ExportReceptionsParamsFormat = "csv"
+	Xlsx ExportReceptionsParamsFormat = "xlsx"
+)
+
+// Defines values for ExportReceptionsParamsCity.
+const (
+	ExportReceptionsParamsCityКазань         ExportReceptionsParamsCity = "Казань"
+	ExportReceptionsParamsCityМосква         ExportReceptionsParamsCity = "Москва"
+	ExportReceptionsParamsCityСанктПетербург ExportReceptionsParamsCity = "Санкт-Петербург"
+)
+
+// Defines values for AddProductJSONBodyType.
+const (
+	AddProductJSONBodyTypeОбувь       AddProductJSONBodyType = "обувь"
+	AddProductJSONBodyTypeОдежда      AddProductJSONBodyType = "одежда"
+	AddProductJSONBodyTypeЭлектроника AddProductJSONBodyType = "электроника"
+)
+
+// Defines values for ListPvzParamsCity.
+const (
+	ListPvzParamsCityКазань         ListPvzParamsCity = "Казань"
+	ListPvzParamsCityМосква         ListPvzParamsCity = "Москва"
+	ListPvzParamsCityСанктПетербург ListPvzParamsCity = "Санкт-Петербург"
+)
+
+// Defines values for ListPvzParamsProductType.
+const (
+	ListPvzParamsProductTypeОбувь       ListPvzParamsProductType = "обувь"
+	ListPvzParamsProductTypeОдежда      ListPvzParamsProductType = "одежда"
+	ListPvzParamsProductTypeЭлектроника ListPvzParamsProductType = "электроника"
+)
+
+// Defines values for ListPvzParamsSort.
+const (
+	Activity         ListPvzParamsSort = "activity"
+	RegistrationDate ListPvzParamsSort = "registrationDate"
+)
+
+// Defines values for ListPvzParamsOrder.
+const (
+	Asc  ListPvzParamsOrder = "asc"
+	Desc ListPvzParamsOrder = "desc"
+)
+
+// Defines values for RegisterJSONBodyRole.
+const (
+	Employee  RegisterJSONBodyRole = "employee"
+	Moderator RegisterJSONBodyRole = "moderator"
+)
+
+// Defines values for ListWebhookDeliveriesParamsStatus.
+const (
+	ListWebhookDeliveriesParamsStatusDead      ListWebhookDeliveriesParamsStatus = "dead"
+	ListWebhookDeliveriesParamsStatusDelivered ListWebhookDeliveriesParamsStatus = "delivered"
+	ListWebhookDeliveriesParamsStatusPending   ListWebhookDeliveriesParamsStatus = "pending"
+)
+
+// Analytics defines model for Analytics.
+type Analytics struct {
+	ProductsPerCity []DailyProducts `json:"productsPerCity"`
+	ProductsPerPvz  []DailyProducts `json:"productsPerPvz"`
+
+	// ReceptionDuration Длительность закрытых приемок от открытия до закрытия
+	ReceptionDuration     ReceptionDurations   `json:"receptionDuration"`
+	ReceptionsPerEmployee []EmployeeReceptions `json:"receptionsPerEmployee"`
+}
+
+// DailyProducts defines model for DailyProducts.
+type DailyProducts struct {
+	City  DailyProductsCity   `json:"city"`
+	Count int                 `json:"count"`
+	Day   openapi_types.Date  `json:"day"`
+	PvzId *openapi_types.UUID `json:"pvzId,omitempty"`
+	Type  DailyProductsType   `json:"type"`
+}
+
+// DailyProductsCity defines model for DailyProducts.City.
+type DailyProductsCity string
+
+// DailyProductsType defines model for DailyProducts.Type.
+type DailyProductsType string
+
+// EmployeeReceptions defines model for EmployeeReceptions.
+type EmployeeReceptions struct {
+	Email      openapi_types.Email `json:"email"`
+	EmployeeId openapi_types.UUID  `json:"employeeId"`
+	Receptions int                 `json:"receptions"`
+}
+
+// Error defines model for Error.
+type Error struct {
+	// Code Стабильный машиночитаемый код ошибки
+	Code ErrorCode `json:"code"`
+
+	// Message Описание для человека, текст может меняться
+	Message string `json:"message"`
+
+	// RequestId Совпадает с заголовком ответа X-Request-ID
+	RequestId *string `json:"requestId,omitempty"`
+}
+
+// ErrorCode Стабильный машиночитаемый код ошибки
+type ErrorCode string
+
+// HealthCheck defines model for HealthCheck.
+type HealthCheck struct {
+	Details  *map[string]interface{} `json:"details,omitempty"`
+	Duration string                  `json:"duration"`
+	Error    *string                 `json:"error,omitempty"`
+	Status   HealthCheckStatus       `json:"status"`
+}
+
+// HealthCheckStatus defines model for HealthCheck.Status.
+type HealthCheckStatus string
+
+// PVZ defines model for PVZ.
+type PVZ struct {
+	City             PVZCity             `json:"city"`
+	Id               *openapi_types.UUID `json:"id,omitempty"`
+	RegistrationDate *time.Time          `json:"registrationDate,omitempty"`
+
+	// Version Версия ПВЗ, меняется при открытии и закрытии его приемок. Совпадает с ETag ответа
+	// и ETag GET /pvz/{pvzId}.
+	Version *int64 `json:"version,omitempty"`
+}
+
+// PVZCity defines model for PVZ.City.
+type PVZCity string
+
+// Product defines model for Product.
+type Product struct {
+	DateTime    *time.Time          `json:"dateTime,omitempty"`
+	Id          *openapi_types.UUID `json:"id,omitempty"`
+	ReceptionId openapi_types.UUID  `json:"receptionId"`
+	Type        ProductType         `json:"type"`
+}
+
+// ProductType defines model for Product.Type.
+type ProductType string
+
+// PvzListItem defines model for PvzListItem.
+type PvzListItem struct {
+	Pvz        PVZ                     `json:"pvz"`
+	Receptions []ReceptionWithProducts `json:"receptions"`
+}
+
+// Readiness defines model for Readiness.
+type Readiness struct {
+	Checks map[string]HealthCheck `json:"checks"`
+	Status ReadinessStatus        `json:"status"`
+}
+
+// ReadinessStatus defines model for Readiness.Status.
+type ReadinessStatus string
+
+// Reception defines model for Reception.
+type Reception struct {
+	DateTime time.Time           `json:"dateTime"`
+	Id       *openapi_types.UUID `json:"id,omitempty"`
+	PvzId    openapi_types.UUID  `json:"pvzId"`
+	Status   ReceptionStatus     `json:"status"`
+
+	// Version Версия приемки, меняется при добавлении и удалении товара и при закрытии. Текущую версию
+	// открытой приемки возвращает GET /pvz/{pvzId}/open_reception.
+	Version *int64 `json:"version,omitempty"`
+}
+
+// ReceptionStatus defines model for Reception.Status.
+type ReceptionStatus string
+
+// ReceptionDurations Длительность закрытых приемок от открытия до закрытия
+type ReceptionDurations struct {
+	AverageSeconds float64 `json:"averageSeconds"`
+	Closed         int     `json:"closed"`
+	P95Seconds     float64 `json:"p95Seconds"`
+}
+
+// ReceptionWithProducts defines model for ReceptionWithProducts.
+type ReceptionWithProducts struct {
+	Products  []Product `json:"products"`
+	Reception Reception `json:"reception"`
+}
+
+// Token defines model for Token.
+type Token = string
+
+// User defines model for User.
+type User struct {
+	Email openapi_types.Email `json:"email"`
+	Id    *openapi_types.UUID `json:"id,omitempty"`
+	Role  UserRole            `json:"role"`
+}
+
+// UserRole defines model for User.Role.
+type UserRole string
+
+// Webhook defines model for Webhook.
+type Webhook struct {
+	CreatedAt *time.Time          `json:"createdAt,omitempty"`
+	Events    []WebhookEventType  `json:"events"`
+	Id        *openapi_types.UUID `json:"id,omitempty"`
+
+	// Secret Ключ HMAC-SHA256 для заголовка X-Webhook-Signature, в ответах не возвращается
+	Secret *string `json:"secret,omitempty"`
+
+	// Url Адрес http(s), на который отправляются события методом POST
+	Url string `json:"url"`
+}
+
+// WebhookDelivery defines model for WebhookDelivery.
+type WebhookDelivery struct {
+	Attempts    int                `json:"attempts"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	DeliveredAt *time.Time         `json:"deliveredAt,omitempty"`
+	Event       WebhookEventType   `json:"event"`
+	EventId     openapi_types.UUID `json:"eventId"`
+
+	// Id Совпадает с заголовком X-Webhook-Delivery и не меняется между повторами
+	Id        openapi_types.UUID `json:"id"`
+	LastError *string            `json:"lastError,omitempty"`
+
+	// LastStatusCode Код последнего ответа подписчика
+	LastStatusCode *int `json:"lastStatusCode,omitempty"`
+
+	// NextAttemptAt Время следующей попытки для доставки в статусе pending
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+
+	// Status pending - ждёт отправки или повтора, dead - попытки исчерпаны
+	Status    WebhookDeliveryStatus `json:"status"`
+	WebhookId openapi_types.UUID    `json:"webhookId"`
+}
+
+// WebhookDeliveryStatus pending - ждёт отправки или повтора, dead - попытки исчерпаны
+type WebhookDeliveryStatus string
+
+// WebhookEventType defines model for WebhookEventType.
+type WebhookEventType string
+
+// IdempotencyKey defines model for IdempotencyKey.
+type IdempotencyKey = string
+
+// IfMatch defines model for IfMatch.
+type IfMatch = string
+
+// IdempotencyKeyInUse defines model for IdempotencyKeyInUse.
+type IdempotencyKeyInUse = Error
+
+// IdempotencyKeyReused defines model for IdempotencyKeyReused.
+type IdempotencyKeyReused = Error
+
+// InternalError defines model for InternalError.
+type InternalError = Error
+
+// PreconditionFailed defines model for PreconditionFailed.
+type PreconditionFailed = Error
+
+// TooManyRequests defines model for TooManyRequests.
+type TooManyRequests = Error
+
+// Unauthorized defines model for Unauthorized.
+type Unauthorized = Error
+
+// GetAnalyticsParams defines parameters for GetAnalytics.
+type GetAnalyticsParams struct {
+	StartDate *time.Time              `form:"startDate,omitempty" json:"startDate,omitempty"`
+	EndDate   *time.Time              `form:"endDate,omitempty" json:"endDate,omitempty"`
+	City      *GetAnalyticsParamsCity `form:"city,omitempty" json:"city,omitempty"`
+}
+
+// GetAnalyticsParamsCity defines parameters for GetAnalytics.
+type GetAnalyticsParamsCity string
+
+// DummyLoginJSONBody defines parameters for DummyLogin.
+type DummyLoginJSONBody struct {
+	Role DummyLoginJSONBodyRole `json:"role"`
+}
+
+// DummyLoginJSONBodyRole defines parameters for DummyLogin.
+type DummyLoginJSONBodyRole string
+
+// StreamEventsParams defines parameters for StreamEvents.
+type StreamEventsParams struct {
+	PvzId *openapi_types.UUID     `form:"pvzId,omitempty" json:"pvzId,omitempty"`
+	City  *StreamEventsParamsCity `form:"city,omitempty" json:"city,omitempty"`
+
+	// LastEventID id последнего полученного события
+	LastEventID *string `json:"Last-Event-ID,omitempty"`
+}
+
+// StreamEventsParamsCity defines parameters for StreamEvents.
+type StreamEventsParamsCity string
+
+// ExportReceptionsParams defines parameters for ExportReceptions.
+type ExportReceptionsParams struct {
+	Format *ExportReceptionsParamsFormat `form:"format,omitempty" json:"format,omitempty"`
+
+	// StartDate Начальная дата приемки
+	StartDate *time.Time `form:"startDate,omitempty" json:"startDate,omitempty"`
+
+	// EndDate Конечная дата приемки
+	EndDate *time.Time                  `form:"endDate,omitempty" json:"endDate,omitempty"`
+	City    *ExportReceptionsParamsCity `form:"city,omitempty" json:"city,omitempty"`
+	PvzId   *openapi_types.UUID         `form:"pvzId,omitempty" json:"pvzId,omitempty"`
+}
+
+// ExportReceptionsParamsFormat defines parameters for ExportReceptions.
+type ExportReceptionsParamsFormat string
+
+// ExportReceptionsParamsCity defines parameters for ExportReceptions.
+type ExportReceptionsParamsCity string
+
+// LoginJSONBody defines parameters for Login.
+type LoginJSONBody struct {
+	Email    openapi_types.Email `json:"email"`
+	Password string              `json:"password"`
+}
+
+// AddProductJSONBody defines parameters for AddProduct.
+type AddProductJSONBody struct {
+	PvzId openapi_types.UUID     `json:"pvzId"`
+	Type  AddProductJSONBodyType `json:"type"`
+}
+
+// AddProductParams defines parameters for AddProduct.
+type AddProductParams struct {
+	// IdempotencyKey Ключ повтора запроса, уникальный для вызывающего. Первый ответ на запрос с ключом сохраняется,
+	// повтор с тем же ключом и телом возвращает его без повторного выполнения (с заголовком
+	// Idempotent-Replayed: true). Ключ хранится ограниченное время, после чего может быть использован снова.
+	// Токены из /dummyLogin не различают вызывающих, поэтому с ними ключ отклоняется с 400.
+	IdempotencyKey *IdempotencyKey `json:"Idempotency-Key,omitempty"`
+
+	// IfMatch ETag из предыдущего ответа. Операция выполняется, только если версия ресурса не изменилась,
+	// иначе возвращается 412. Без заголовка или со значением * версия не проверяется.
+	IfMatch *IfMatch `json:"If-Match,omitempty"`
+}
+
+// AddProductJSONBodyType defines parameters for AddProduct.
+type AddProductJSONBodyType string
+
+// ListPvzParams defines parameters for ListPvz.
+type ListPvzParams struct {
+	// StartDate Начальная дата диапазона (фильтрует приемки ПВЗ по дате приемки)
+	StartDate *time.Time `form:"startDate,omitempty" json:"startDate,omitempty"`
+
+	// EndDate Конечная дата диапазона (фильтрует приемки ПВЗ по дате приемки)
+	EndDate *time.Time `form:"endDate,omitempty" json:"endDate,omitempty"`
+
+	// Page Номер страницы (пагинация по ПВЗ)
+	Page *int `form:"page,omitempty" json:"page,omitempty"`
+
+	// Limit Количество ПВЗ на странице
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// City Город ПВЗ
+	City *ListPvzParamsCity `form:"city,omitempty" json:"city,omitempty"`
+
+	// OpenReception Есть ли у ПВЗ открытая приемка
+	OpenReception *bool `form:"openReception,omitempty" json:"openReception,omitempty"`
+
+	// ProductType В ПВЗ принимался товар этого типа (с учетом startDate и endDate)
+	ProductType *ListPvzParamsProductType `form:"productType,omitempty" json:"productType,omitempty"`
+
+	// Author Модератор, создавший ПВЗ
+	Author *openapi_types.UUID `form:"author,omitempty" json:"author,omitempty"`
+
+	// Sort Сортировка по дате регистрации ПВЗ или по дате последней приемки
+	Sort *ListPvzParamsSort `form:"sort,omitempty" json:"sort,omitempty"`
+
+	// Order Направление сортировки
+	Order *ListPvzParamsOrder `form:"order,omitempty" json:"order,omitempty"`
+
+	// Cursor Непрозрачный курсор из заголовка X-Next-Cursor предыдущего ответа. Если указан, page игнорируется
+	Cursor *string `form:"cursor,omitempty" json:"cursor,omitempty"`
+}
+
+// ListPvzParamsCity defines parameters for ListPvz.
+type ListPvzParamsCity string
+
+// ListPvzParamsProductType defines parameters for ListPvz.
+type ListPvzParamsProductType string
+
+// ListPvzParamsSort defines parameters for ListPvz.
+type ListPvzParamsSort string
+
+// ListPvzParamsOrder defines parameters for ListPvz.
+type ListPvzParamsOrder string
+
+// CreatePvzParams defines parameters for CreatePvz.
+type CreatePvzParams struct {
+	// IdempotencyKey Ключ повтора запроса, уникальный для вызывающего. Первый ответ на запрос с ключом сохраняется,
+	// повтор с тем же ключом и телом возвращает его без повторного выполнения (с заголовком
+	// Idempotent-Replayed: true). Ключ хранится ограниченное время, после чего может быть использован снова.
+	// Токены из /dummyLogin не различают вызывающих, поэтому с ними ключ отклоняется с 400.
+	IdempotencyKey *IdempotencyKey `json:"Idempotency-Key,omitempty"`
+}
+
+// CloseLastReceptionParams defines parameters for CloseLastReception.
+type CloseLastReceptionParams struct {
+	// IfMatch ETag из предыдущего ответа. Операция выполняется, только если версия ресурса не изменилась,
+	// иначе возвращается 412. Без заголовка или со значением * версия не проверяется.
+	IfMatch *IfMatch `json:"If-Match,omitempty"`
+}
+
+// DeleteLastProductParams defines parameters for DeleteLastProduct.
+type DeleteLastProductParams struct {
+	// IfMatch ETag из предыдущего ответа. Операция выполняется, только если версия ресурса не изменилась,
+	// иначе возвращается 412. Без заголовка или со значением * версия не проверяется.
+	IfMatch *IfMatch `json:"If-Match,omitempty"`
+}
+
+// CreateReceptionJSONBody defines parameters for CreateReception.
+type CreateReceptionJSONBody struct {
+	PvzId openapi_types.UUID `json:"pvzId"`
+}
+
+// CreateReceptionParams defines parameters for CreateReception.
+type CreateReceptionParams struct {
+	// IdempotencyKey Ключ повтора запроса, уникальный для вызывающего. Первый ответ на запрос с ключом сохраняется,
+	// повтор с тем же ключом и телом возвращает его без повторного выполнения (с заголовком
+	// Idempotent-Replayed: true). Ключ хранится ограниченное время, после чего может быть использован снова.
+	// Токены из /dummyLogin не различают вызывающих, поэтому с ними ключ отклоняется с 400.
+	IdempotencyKey *IdempotencyKey `json:"Idempotency-Key,omitempty"`
+
+	// IfMatch ETag из предыдущего ответа. Операция выполняется, только если версия ресурса не изменилась,
+	// иначе возвращается 412. Без заголовка или со значением * версия не проверяется.
+	IfMatch *IfMatch `json:"If-Match,omitempty"`
+}
+
+// RegisterJSONBody defines parameters for Register.
+type RegisterJSONBody struct {
+	Email    openapi_types.Email  `json:"email"`
+	Password string               `json:"password"`
+	Role     RegisterJSONBodyRole `json:"role"`
+}
+
+// RegisterJSONBodyRole defines parameters for Register.
+type RegisterJSONBodyRole string
+
+// CreateWebhookJSONBody defines parameters for CreateWebhook.
+type CreateWebhookJSONBody struct {
+	CreatedAt *time.Time          `json:"createdAt,omitempty"`
+	Events    []WebhookEventType  `json:"events"`
+	Id        *openapi_types.UUID `json:"id,omitempty"`
+
+	// Secret Ключ HMAC-SHA256 для заголовка X-Webhook-Signature, в ответах не возвращается
+	Secret *string `json:"secret,omitempty"`
+
+	// Url Адрес http(s), на который отправляются события методом POST
+	Url string `json:"url"`
+}
+
+// ListWebhookDeliveriesParams defines parameters for ListWebhookDeliveries.
+type ListWebhookDeliveriesParams struct {
+	Status *ListWebhookDeliveriesParamsStatus `form:"status,omitempty" json:"status,omitempty"`
+	Limit  *int                               `form:"limit,omitempty" json:"limit,omitempty"`
+}
+
+// ListWebhookDeliveriesParamsStatus defines parameters for ListWebhookDeliveries.
+type ListWebhookDeliveriesParamsStatus string
+
+// DummyLoginJSONRequestBody defines body for DummyLogin for application/json ContentType.
+type DummyLoginJSONRequestBody DummyLoginJSONBody
+
+// LoginJSONRequestBody defines body for Login for application/json ContentType.
+type LoginJSONRequestBody LoginJSONBody
+
+// AddProductJSONRequestBody defines body for AddProduct for application/json ContentType.
+type AddProductJSONRequestBody AddProductJSONBody
+
+// CreatePvzJSONRequestBody defines body for CreatePvz for application/json ContentType.
+type CreatePvzJSONRequestBody = PVZ
+
+// CreateReceptionJSONRequestBody defines body for CreateReception for application/json ContentType.
+type CreateReceptionJSONRequestBody CreateReceptionJSONBody
+
+// RegisterJSONRequestBody defines body for Register for application/json ContentType.
+type RegisterJSONRequestBody RegisterJSONBody
+
+// CreateWebhookJSONRequestBody defines body for CreateWebhook for application/json ContentType.
+type CreateWebhookJSONRequestBody CreateWebhookJSONBody
+
+// ServerInterface represents all server handlers.
+type ServerInterface interface {
+	// Аналитика по приемкам для дашбордов
+	// (GET /analytics)
+	GetAnalytics(w http.ResponseWriter, r *http.Request, params GetAnalyticsParams)
+	// Получение тестового токена
+	// (POST /dummyLogin)
+	DummyLogin(w http.ResponseWriter, r *http.Request)
+	// Живая лента событий приемок (Server-Sent Events)
+	// (GET /events)
+	StreamEvents(w http.ResponseWriter, r *http.Request, params StreamEventsParams)
+	// Выдача cookie для подключения к ленте событий из браузера
+	// (POST /events/session)
+	CreateEventSession(w http.ResponseWriter, r *http.Request)
+	// Выгрузка приемок и товаров в CSV или XLSX
+	// (GET /export/receptions)
+	ExportReceptions(w http.ResponseWriter, r *http.Request, params ExportReceptionsParams)
+	// Проверка, что процесс жив
+	// (GET /healthz)
+	Healthz(w http.ResponseWriter, r *http.Request)
+	// Авторизация пользователя
+	// (POST /login)
+	Login(w http.ResponseWriter, r *http.Request)
+	// Проверка доступности (устарело, используйте /healthz)
+	// (GET /ping)
+	Ping(w http.ResponseWriter, r *http.Request)
+	// Добавление товара в текущую приемку (только для сотрудников ПВЗ)
+	// (POST /products)
+	AddProduct(w http.ResponseWriter, r *http.Request, params AddProductParams)
+	// Получение списка ПВЗ с фильтрацией по дате приемки и пагинацией
+	// (GET /pvz)
+	ListPvz(w http.ResponseWriter, r *http.Request, params ListPvzParams)
+	// Создание ПВЗ (только для модераторов)
+	// (POST /pvz)
+	CreatePvz(w http.ResponseWriter, r *http.Request, params CreatePvzParams)
+	// Получение ПВЗ с его текущей версией
+	// (GET /pvz/{pvzId})
+	GetPvz(w http.ResponseWriter, r *http.Request, pvzId openapi_types.UUID)
+	// Закрытие последней открытой приемки товаров в рамках ПВЗ
+	// (POST /pvz/{pvzId}/close_last_reception)
+	CloseLastReception(w http.ResponseWriter, r *http.Request, pvzId openapi_types.UUID, params CloseLastReceptionParams)
+	// Удаление последнего добавленного товара из текущей приемки (LIFO, только для сотрудников ПВЗ)
+	// (POST /pvz/{pvzId}/delete_last_product)
+	DeleteLastProduct(w http.ResponseWriter, r *http.Request, pvzId openapi_types.UUID, params DeleteLastProductParams)
+	// Получение открытой приемки ПВЗ с товарами и её текущей версией
+	// (GET /pvz/{pvzId}/open_reception)
+	GetOpenReception(w http.ResponseWriter, r *http.Request, pvzId openapi_types.UUID)
+	// Готовность принимать трафик
+	// (GET /readyz)
+	Readyz(w http.ResponseWriter, r *http.Request)
+	// Создание новой приемки товаров (только для сотрудников ПВЗ)
+	// (POST /receptions)
+	CreateReception(w http.ResponseWriter, r *http.Request, params CreateReceptionParams)
+	// Регистрация пользователя
+	// (POST /register)
+	Register(w http.ResponseWriter, r *http.Request)
+	// Список подписок (только для модераторов)
+	// (GET /webhooks)
+	ListWebhooks(w http.ResponseWriter, r *http.Request)
+	// Подписка на события ПВЗ и приемок (только для модераторов)
+	// (POST /webhooks)
+	CreateWebhook(w http.ResponseWriter, r *http.Request)
+	// Удаление подписки вместе с журналом доставок (только для модераторов)
+	// (DELETE /webhooks/{webhookId})
+	DeleteWebhook(w http.ResponseWriter, r *http.Request, webhookId openapi_types.UUID)
+	// Журнал доставок подписки, начиная с последних (только для модераторов)
+	// (GET /webhooks/{webhookId}/deliveries)
+	ListWebhookDeliveries(w http.ResponseWriter, r *http.Request, webhookId openapi_types.UUID, params ListWebhookDeliveriesParams)
+}
+
+// ServerInterfaceWrapper converts contexts to parameters.
+type ServerInterfaceWrapper struct {
+	Handler            ServerInterface
+	HandlerMiddlewares []MiddlewareFunc
+	ErrorHandlerFunc   func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+type MiddlewareFunc func(http.Handler) http.Handler
+
+// GetAnalytics operation middleware
+func (siw *ServerInterfaceWrapper) GetAnalytics(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetAnalyticsParams
+
+	// ------------- Optional query parameter "startDate" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "startDate", r.URL.Query(), &params.StartDate)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "startDate", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "endDate" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "endDate", r.URL.Query(), &params.EndDate)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "endDate", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "city" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "city", r.URL.Query(), &params.City)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "city", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetAnalytics(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// DummyLogin operation middleware
+func (siw *ServerInterfaceWrapper) DummyLogin(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.DummyLogin(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// StreamEvents operation middleware
+func (siw *ServerInterfaceWrapper) StreamEvents(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	ctx = context.WithValue(ctx, EventsCookieScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params StreamEventsParams
+
+	// ------------- Optional query parameter "pvzId" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "pvzId", r.URL.Query(), &params.PvzId)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "pvzId", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "city" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "city", r.URL.Query(), &params.City)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "city", Err: err})
+		return
+	}
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "Last-Event-ID" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Last-Event-ID")]; found {
+		var LastEventID string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Last-Event-ID", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Last-Event-ID", valueList[0], &LastEventID, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Last-Event-ID", Err: err})
+			return
+		}
+
+		params.LastEventID = &LastEventID
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.StreamEvents(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// CreateEventSession operation middleware
+func (siw *ServerInterfaceWrapper) CreateEventSession(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CreateEventSession(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ExportReceptions operation middleware
+func (siw *ServerInterfaceWrapper) ExportReceptions(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ExportReceptionsParams
+
+	// ------------- Optional query parameter "format" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "format", r.URL.Query(), &params.Format)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "format", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "startDate" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "startDate", r.URL.Query(), &params.StartDate)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "startDate", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "endDate" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "endDate", r.URL.Query(), &params.EndDate)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "endDate", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "city" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "city", r.URL.Query(), &params.City)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "city", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "pvzId" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "pvzId", r.URL.Query(), &params.PvzId)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "pvzId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ExportReceptions(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// Healthz operation middleware
+func (siw *ServerInterfaceWrapper) Healthz(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.Healthz(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// Login operation middleware
+func (siw *ServerInterfaceWrapper) Login(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.Login(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// Ping operation middleware
+func (siw *ServerInterfaceWrapper) Ping(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.Ping(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// AddProduct operation middleware
+func (siw *ServerInterfaceWrapper) AddProduct(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params AddProductParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "Idempotency-Key" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Idempotency-Key")]; found {
+		var IdempotencyKey IdempotencyKey
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Idempotency-Key", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Idempotency-Key", valueList[0], &IdempotencyKey, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Idempotency-Key", Err: err})
+			return
+		}
+
+		params.IdempotencyKey = &IdempotencyKey
+
+	}
+
+	// ------------- Optional header parameter "If-Match" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("If-Match")]; found {
+		var IfMatch IfMatch
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "If-Match", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "If-Match", valueList[0], &IfMatch, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "If-Match", Err: err})
+			return
+		}
+
+		params.IfMatch = &IfMatch
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.AddProduct(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ListPvz operation middleware
+func (siw *ServerInterfaceWrapper) ListPvz(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListPvzParams
+
+	// ------------- Optional query parameter "startDate" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "startDate", r.URL.Query(), &params.StartDate)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "startDate", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "endDate" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "endDate", r.URL.Query(), &params.EndDate)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "endDate", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "page" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "page", r.URL.Query(), &params.Page)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "page", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "city" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "city", r.URL.Query(), &params.City)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "city", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "openReception" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "openReception", r.URL.Query(), &params.OpenReception)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "openReception", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "productType" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "productType", r.URL.Query(), &params.ProductType)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "productType", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "author" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "author", r.URL.Query(), &params.Author)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "author", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "sort" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "sort", r.URL.Query(), &params.Sort)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "sort", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "order" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "order", r.URL.Query(), &params.Order)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "order", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", r.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "cursor", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListPvz(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// CreatePvz operation middleware
+func (siw *ServerInterfaceWrapper) CreatePvz(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params CreatePvzParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "Idempotency-Key" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Idempotency-Key")]; found {
+		var IdempotencyKey IdempotencyKey
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Idempotency-Key", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Idempotency-Key", valueList[0], &IdempotencyKey, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Idempotency-Key", Err: err})
+			return
+		}
+
+		params.IdempotencyKey = &IdempotencyKey
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CreatePvz(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetPvz operation middleware
+func (siw *ServerInterfaceWrapper) GetPvz(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "pvzId" -------------
+	var pvzId openapi_types.UUID
+
+	err = runtime.BindStyledParameterWithOptions("simple", "pvzId", mux.Vars(r)["pvzId"], &pvzId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "pvzId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetPvz(w, r, pvzId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// CloseLastReception operation middleware
+func (siw *ServerInterfaceWrapper) CloseLastReception(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "pvzId" -------------
+	var pvzId openapi_types.UUID
+
+	err = runtime.BindStyledParameterWithOptions("simple", "pvzId", mux.Vars(r)["pvzId"], &pvzId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "pvzId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params CloseLastReceptionParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "If-Match" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("If-Match")]; found {
+		var IfMatch IfMatch
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "If-Match", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "If-Match", valueList[0], &IfMatch, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "If-Match", Err: err})
+			return
+		}
+
+		params.IfMatch = &IfMatch
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CloseLastReception(w, r, pvzId, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// DeleteLastProduct operation middleware
+func (siw *ServerInterfaceWrapper) DeleteLastProduct(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "pvzId" -------------
+	var pvzId openapi_types.UUID
+
+	err = runtime.BindStyledParameterWithOptions("simple", "pvzId", mux.Vars(r)["pvzId"], &pvzId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "pvzId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params DeleteLastProductParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "If-Match" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("If-Match")]; found {
+		var IfMatch IfMatch
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "If-Match", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "If-Match", valueList[0], &IfMatch, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "If-Match", Err: err})
+			return
+		}
+
+		params.IfMatch = &IfMatch
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.DeleteLastProduct(w, r, pvzId, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetOpenReception operation middleware
+func (siw *ServerInterfaceWrapper) GetOpenReception(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "pvzId" -------------
+	var pvzId openapi_types.UUID
+
+	err = runtime.BindStyledParameterWithOptions("simple", "pvzId", mux.Vars(r)["pvzId"], &pvzId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "pvzId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetOpenReception(w, r, pvzId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// Readyz operation middleware
+func (siw *ServerInterfaceWrapper) Readyz(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.Readyz(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// CreateReception operation middleware
+func (siw *ServerInterfaceWrapper) CreateReception(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params CreateReceptionParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "Idempotency-Key" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Idempotency-Key")]; found {
+		var IdempotencyKey IdempotencyKey
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Idempotency-Key", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Idempotency-Key", valueList[0], &IdempotencyKey, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Idempotency-Key", Err: err})
+			return
+		}
+
+		params.IdempotencyKey = &IdempotencyKey
+
+	}
+
+	// ------------- Optional header parameter "If-Match" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("If-Match")]; found {
+		var IfMatch IfMatch
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "If-Match", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "If-Match", valueList[0], &IfMatch, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "If-Match", Err: err})
+			return
+		}
+
+		params.IfMatch = &IfMatch
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CreateReception(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// Register operation middleware
+func (siw *ServerInterfaceWrapper) Register(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.Register(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ListWebhooks operation middleware
+func (siw *ServerInterfaceWrapper) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListWebhooks(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// CreateWebhook operation middleware
+func (siw *ServerInterfaceWrapper) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CreateWebhook(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// DeleteWebhook operation middleware
+func (siw *ServerInterfaceWrapper) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "webhookId" -------------
+	var webhookId openapi_types.UUID
+
+	err = runtime.BindStyledParameterWithOptions("simple", "webhookId", mux.Vars(r)["webhookId"], &webhookId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "webhookId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.DeleteWebhook(w, r, webhookId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ListWebhookDeliveries operation middleware
+func (siw *ServerInterfaceWrapper) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "webhookId" -------------
+	var webhookId openapi_types.UUID
+
+	err = runtime.BindStyledParameterWithOptions("simple", "webhookId", mux.Vars(r)["webhookId"], &webhookId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "webhookId", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, BearerAuthScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListWebhookDeliveriesParams
+
+	// ------------- Optional query parameter "status" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "status", r.URL.Query(), &params.Status)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "status", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListWebhookDeliveries(w, r, webhookId, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+type UnescapedCookieParamError struct {
+	ParamName string
+	Err       error
+}
+
+func (e *UnescapedCookieParamError) Error() string {
+	return fmt.Sprintf("error unescaping cookie parameter '%s'", e.ParamName)
+}
+
+func (e *UnescapedCookieParamError) Unwrap() error {
+	return e.Err
+}
+
+type UnmarshalingParamError struct {
+	ParamName string
+	Err       error
+}
+
+func (e *UnmarshalingParamError) Error() string {
+	return fmt.Sprintf("Error unmarshaling parameter %s as JSON: %s", e.ParamName, e.Err.Error())
+}
+
+func (e *UnmarshalingParamError) Unwrap() error {
+	return e.Err
+}
+
+type RequiredParamError struct {
+	ParamName string
+}
+
+func (e *RequiredParamError) Error() string {
+	return fmt.Sprintf("Query argument %s is required, but not found", e.ParamName)
+}
+
+type RequiredHeaderError struct {
+	ParamName string
+	Err       error
+}
+
+func (e *RequiredHeaderError) Error() string {
+	return fmt.Sprintf("Header parameter %s is required, but not found", e.ParamName)
+}
+
+func (e *RequiredHeaderError) Unwrap() error {
+	return e.Err
+}
+
+type InvalidParamFormatError struct {
+	ParamName string
+	Err       error
+}
+
+func (e *InvalidParamFormatError) Error() string {
+	return fmt.Sprintf("Invalid format for parameter %s: %s", e.ParamName, e.Err.Error())
+}
+
+func (e *InvalidParamFormatError) Unwrap() error {
+	return e.Err
+}
+
+type TooManyValuesForParamError struct {
+	ParamName string
+	Count     int
+}
+
+func (e *TooManyValuesForParamError) Error() string {
+	return fmt.Sprintf("Expected one value for %s, got %d", e.ParamName, e.Count)
+}
+
+// Handler creates http.Handler with routing matching OpenAPI spec.
+func Handler(si ServerInterface) http.Handler {
+	return HandlerWithOptions(si, GorillaServerOptions{})
+}
+
+type GorillaServerOptions struct {
+	BaseURL          string
+	BaseRouter       *mux.Router
+	Middlewares      []MiddlewareFunc
+	ErrorHandlerFunc func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// HandlerFromMux creates http.Handler with routing matching OpenAPI spec based on the provided mux.
+func HandlerFromMux(si ServerInterface, r *mux.Router) http.Handler {
+	return HandlerWithOptions(si, GorillaServerOptions{
+		BaseRouter: r,
+	})
+}
+
+func HandlerFromMuxWithBaseURL(si ServerInterface, r *mux.Router, baseURL string) http.Handler {
+	return HandlerWithOptions(si, GorillaServerOptions{
+		BaseURL:    baseURL,
+		BaseRouter: r,
+	})
+}
+
+// HandlerWithOptions creates http.Handler with additional options
+func HandlerWithOptions(si ServerInterface, options GorillaServerOptions) http.Handler {
+	r := options.BaseRouter
+
+	if r == nil {
+		r = mux.NewRouter()
+	}
+	if options.ErrorHandlerFunc == nil {
+		options.ErrorHandlerFunc = func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}
+	wrapper := ServerInterfaceWrapper{
+		Handler:            si,
+		HandlerMiddlewares: options.Middlewares,
+		ErrorHandlerFunc:   options.ErrorHandlerFunc,
+	}
+
+	r.HandleFunc(options.BaseURL+"/analytics", wrapper.GetAnalytics).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/dummyLogin", wrapper.DummyLogin).Methods("POST")
+
+	r.HandleFunc(options.BaseURL+"/events", wrapper.StreamEvents).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/events/session", wrapper.CreateEventSession).Methods("POST")
+
+	r.HandleFunc(options.BaseURL+"/export/receptions", wrapper.ExportReceptions).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/healthz", wrapper.Healthz).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/login", wrapper.Login).Methods("POST")
+
+	r.HandleFunc(options.BaseURL+"/ping", wrapper.Ping).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/products", wrapper.AddProduct).Methods("POST")
+
+	r.HandleFunc(options.BaseURL+"/pvz", wrapper.ListPvz).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/pvz", wrapper.CreatePvz).Methods("POST")
+
+	r.HandleFunc(options.BaseURL+"/pvz/{pvzId}", wrapper.GetPvz).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/pvz/{pvzId}/close_last_reception", wrapper.CloseLastReception).Methods("POST")
+
+	r.HandleFunc(options.BaseURL+"/pvz/{pvzId}/delete_last_product", wrapper.DeleteLastProduct).Methods("POST")
+
+	r.HandleFunc(options.BaseURL+"/pvz/{pvzId}/open_reception", wrapper.GetOpenReception).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/readyz", wrapper.Readyz).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/receptions", wrapper.CreateReception).Methods("POST")
+
+	r.HandleFunc(options.BaseURL+"/register", wrapper.Register).Methods("POST")
+
+	r.HandleFunc(options.BaseURL+"/webhooks", wrapper.ListWebhooks).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/webhooks", wrapper.CreateWebhook).Methods("POST")
+
+	r.HandleFunc(options.BaseURL+"/webhooks/{webhookId}", wrapper.DeleteWebhook).Methods("DELETE")
+
+	r.HandleFunc(options.BaseURL+"/webhooks/{webhookId}/deliveries", wrapper.ListWebhookDeliveries).Methods("GET")
+
+	return r
+}
+
+// Base64 encoded, gzipped, json marshaled Swagger object
+var swaggerSpec = []string{
+
+	"H4sIAAAAAAAC/+x9bXPbxrX/V8Hg3xf2/4KS7NiZ1u98bTdRr9t4LLfJNPbVwMRKQk0CDAAqlj2a0cN1",
+	"3YzUqE7TdqbTNk3bO31LyaJFSST1FXa/0Z1zzgLYBZYUKcuK7fiNLYLA4uzuefidhz18bFfDeiMMWJDE",
+	"9pXH9gJzPRbhnzfuuPPwv8fiauQ3Ej8M7Cs2/wdv8wOxLr7gLbFl8R3eFitilXfEliVWeFusinW80HIs",
+	"fsT7fFc8gX95R6xZfJcfwkN7vMWf8z4/5H2+ww94y5qeq/zUTaoLlljlh7zNd8W6+FJ8wdtwHz1wJFZ4",
+	"Hwa2HTuuLrC6C9QlSw1mX7HjJPKDeXt52bE/qdx2E3bTr/tJBf81TGKVd/kBjCbWxCZOgu+KlZRmC79b",
+	"4314I++KdQtf3uE9sQWXaaK/4W3e4x3eNtHjBwmbZ1GJoNus7voBkFom6lt4LT8Um/ygOOc+37F4W3wh",
+	"nlm8y/v8Be/BLUAk3NLiO7xDU6ElXxFbfHc8umJmWqh/w/byNt+DjVHJE6vEB7zHd/MF7Fu8B2TDjbA4",
+	"Yk2siq3hhCw7dsON3DpLJONNe6zeCBMWVJf+iy0ZiPozPxRfiqc02R25Ua0ClzgWEtcB9kKqe2KD72cs",
+	"uCM2+J7Y4Du8lTPahMW/wQnv0L2wvsDgazgt7QWWWAU2QUKASWBB+uIJbkZPbPE2Td25G6hUwlNijbd5",
+	"1+IveFsfgXfou0P6tMP7fI/vwIggbESGlIdt3BJt/j3ep++A9nQDkEPFlnVOrBL1qtT1efdukC028ECj",
+	"5i4x74qVRE12fsLKFjqdV7qhFrwru/QUXwPvb1tIb5t3xRaJEomzhfcgdcS9OJdtsUE82xGrRLHY5HtI",
+	"XIv3YEF79GHibsD/wfv8AF4kNize4XvWpNes15duhvN+ALvTtpCePX6IFMGmrpV3uSOeSLp+K9akbMPS",
+	"AJ90eSfbD9r6A1wqZTfh3ktTUxN3A9uxfeBF0pe2YwdundlXVN6tAPOqnF93H95kwXyyYF+5ePmyY9f9",
+	"IP18wTEosuk5VIllAQDNTIuA3Aiit4EaM9OXGePy1oTF/8aPkKtb4tfIDSqLKKxqiTW5Cah/2rh3nWEq",
+	"nhYeKOFdqQ0PeUusik3g+w4IDey8kZdxPS9duDhh8WfEzWWrgON1ULTgazkcaV3etf6/ThsRg/Ipr2dz",
+	"G7Zj0vIMtSrLjh2xuBEGMTOoqOng5zGDy9UwAEmCP91Go+ZXXdixyV/FsG2PlfF/ELE5+4r9/yZz6ztJ",
+	"38aTN6IolHqxoPf+pKsf5OEO72ZcS3pDmgnjHtvLToH226wZM+8MiM90yTppPrPQqwghU+UoomDU1vlz",
+	"nHCuJnFCQcKiwK3Ru1/9TL7iPbEu1lDyeogJQCGK3/AO3wa2zYAPMOI27xHOIO2uyNeOhVN4DqprlawO",
+	"/tuCOd2KWDUMPB/e+WPXr53JFn11jKATEOFHvMV3pUESqxl0A7LvhOFP3WDpNvusyeIkPgOav8Ft2BEb",
+	"hMdgTVGVizWdiRBBFfiN2AhN1QE+B+Zljbesc59UrtV8sInT18+nWmj6Vsqd0njsW2AExTo/4kdglfqq",
+	"muX7tqOi6dssiZYqV+cSFp0cZoHh2pJYj8yRBpY7fF+b9DgAMEPKpm2Q85gsPzAM3o46Uv7QIFA6+kjw",
+	"ALLJzwO3mSyEkf/oTETnryQNaylKyY0XIAgUH77L23wfVQHuIKqwNu/hVsg3AAFXA7e2lPhV/NCIwgaL",
+	"Ep+ln7xmNYlvseianyA09hNWj4+j+7rr15ZuyYdhlSUzuFHkLsFnZeBbi49Ob9yIVRmu0fVm5NJaDR/x",
+	"dvGBWBsGCLxRb9TCJcZGpjJ9IBvbQCq+5LOmHwG3fFpcD6e08qapDaLzXvay8P6vWBWFRl+50kZX5e6y",
+	"oFkHevhfUIkdgNayHXAVAXofiLUKOixrqD62QVvz5/D9nxEKgyeyqbw+hTOOXQ2bJAtFreDYnotvnguj",
+	"upvYV2zPTZhtGKKx+Gja0+5sNn3PdCddyCcjfouMf4AmtJ/6aEB2H2XkBd9NP26Ldb5jnENhw4Bqh5ZN",
+	"3ppO0rT6Bo4obQEopZo2P7pimCCTw424HpH2VoNeViemjO1kFCgjGKeXAqECU4UeM4UdwOTxbd5RveQu",
+	"byGgAfDyFLVVC906/BIM1K4KeTq2k+2uHyy6Nd+bjQgD2I7dVFWxY9fAZZudI1DjwHLd9z2PgfwAtShL",
+	"+ddBmMzOhc0A/q6zZCH0ZuGSW6uFn+MNfg5nZx+wpVk/mG3GzPBFREgXZDnHVvmLIjdhszUwIzSsBJWz",
+	"DBfTJER1FsfuvGlJweECfNsiVyXDDU8lbIVwCQJFtAIHYBM0zxidKYg1iU0ZPzEwES7vtGd4/bcmmGZy",
+	"/zVH0fqkInFbZfq6fZzApZN3iK1MbPghc2vJwrUFVn1QZkaPJa5fwz9dj/bCrd1SboEYhGFQTzEkZUFM",
+	"Gb/0TZy4STNWtVD4AJgP5OlY5SIfVl5umu6tX/zy7BW5P6rKmffjhIi/Dgq9qOEriV83qvlFFsVyuYd4",
+	"C/wb/hX/k5NxbubgU+BUhlPECkR8eAcudIghtWsyeIGPgLYBMDVhmdmZYiAK+0LAga5+cOOONdlYfDT5",
+	"GG3UMjn/2XT9IHn/EipR1/soqC0VWG2QIsadNO47WXEDi7sJu+PXx1hsf0wD8hoZYGlzVdKMi7X46KYf",
+	"J9MJqxvg7eKj47AcCFnJhI6EAjNb/7GfLAzGrEUgiOjvGHt7m7meH7DYBORA+w3RcsNpVjXosuHFp6PV",
+	"JI3mmcmJnyF3jw4sy9P3g9lGFM5HsBeOXa2FMbPvnVyr5ZrogHeGaLddkBTMwhzK8CRqOLGOGiu/JtZk",
+	"4AHTFZ3s+YImnLDyLBu49mqY88u7gaZMMQyh0WnOHBSV4mTYYMFsxtinoyMznkh3MdujocyV+3rl/fga",
+	"A/oUqNlMY2liU1szsSGeFMwGmoaC2YEN200za8pV2ymwtrvIIneezSBMjHUGD5v3awp3B836ffKbkNk8",
+	"s0/V+NHlcQYrWh4a2SnSpY07dIE1lTcwqjCyKpVDDXX4R1bHpenmQ+Rut3F6d8IHzIwDfx6z6KU8ulEN",
+	"cVjTDGvqrIGvEnrgyxhdh5KLJ706GM000Y/Z/YUwNIDoasTchHlXk4FKeIAA51NgiywYY+slKTfgqTsw",
+	"1jLmsabp2QsFhgDfz/+syeTXQMLAxT2W0phVI2OOOs0sfPjTq9cqMx9evXj5/SGVBp9U5BwqM/584CbN",
+	"iDkYjFewpHhCAe9BeSvb0ZN57+vJvPeL1Dv255GfsHx6y47djGqGufwO0wZtsWotJEnjXHzekannrCIh",
+	"z0ynmf9DsSW+JMIoRL+d67suTqiPFqpr3fpo5k6B9qlLPzyOP4HSjFGG8Od1VvMXWbRU5lM3SVi9kcRm",
+	"7Xg8F5d4waNXjfcQzuAkDI4PjohJ/JdzyHPuTFcTcQIyYwl9dAmrY32KWgkB+Q/bOZ7WmhsnNwa6zPDt",
+	"DFrva+bA0Z95P01KyDwE0FlKQMtsGIVEIJgknY0yIwTsYXKVWOWqSdC/SmsLylVC+/SWI+T8A97JFMCu",
+	"zL61+I4ERxZ9FmtiHbIrVoMFHgnpaGyUo06dPDmOVbFwU56JNV1M8e0yJ6Btl2N5zPWsCl1WpkDrBRGB",
+	"IwwBbChhtpzqTBbwb9czAt7PiatGYuKC+OMt+fO5OMi/cpDn5IKuSvUQlZGLmWJFG4uPJuTTqu81AYiV",
+	"eTkqmHA9T/vssRorPiTBk9EIx6zajPxkaQZknzTVfeZGLLraTBbyTz9Ol+snH99JU2owEn2brx9o7Nyk",
+	"XgvDBz4zFtDJHJFFngHgV6rmAPVsTdLjkzGLwUXJGHkbjdA630OGSKt9cAFnwmZUZU7JSGDOdh3VBIYV",
+	"j2SWcRf5ESRg825QUEOAna/KcC2CcqiJouI3TKzmtRtaNvuDGxnlSq1FldYgq7VoLD6apZvyVXMbPhTK",
+	"YFLND+ZCo/qk7HhHrKbLAVnX1PxlVU4UhcodK+kRgYukeF59vgNv95MabqJbfcACz4pZtOhXma34hvaF",
+	"iamJKdhR4Dy34dtX7PfwElSsJQvIMJOumq6bZ8mAHcd3iw1ZX7iLiryL2gjLlij7y9vqHJ7LAsRdUBF8",
+	"d5AjVPB7OhYG7EFB9ktfHlFKuQ+RH/RO0wK57oTF/w4kiHUYnxQkMBTUOXbw5szsIB7p8o4sg1hXA/eg",
+	"YOwPWJInMfXqvk8fE2d81gSgkDFGnLhRcp0yTXkqdhR9vOyYR2SBd6rjydRSPtiriegu3ytUGl2cmjq1",
+	"9HW+KaYU9u9gYyWTkY1eduxLp/j6odlzWfwi009qEQNScWHQ4NliTWoZf3jo4o+Of6hYsLLs2Jenpo5/",
+	"Tq85Uo0J8rhqRj69B5saN+t1N1oyLrRUCqrOavFuqupA/sVv+DZqg11UX/A+pf4RAXcY4/bokng9vydL",
+	"Gf1n6C2Ntac6lj8dn3eAr6vfJj2lVyYPFEAwMeQ/sTgNdF+PytxbGVyDYkdZSPkaCcgZ83rOzN8gCFjP",
+	"6zIxp4kQm+AEoBS1LoaYNw87mE3mN4Rk+IGVsIcJ3V6Jk4i5dSx91NxcLN0t4kTH0mCiYxVQoqU9QzAR",
+	"Co1BP79AOZMFb/CiL/LZrVKSRKzwF1RlRkVlW5bvWecQl4HPvSLr+6iirH3esXAK1jmU+SMoKrM8N3Gh",
+	"MjufCoYcrJ/MfPQzBHMtMNk7lsTe8Xkg7+9gPuDqhcvF4jBgTvWwBXqU0mtcQ+gDBWIPGGu44DFgsXta",
+	"oI00rFCltFYNl6NG8uLSUtOWWhNtLi2/6cZJBQFqZfq6lbo9xCwtiUgR4BzJ0uUecne7sLuOUoqMyaq0",
+	"xDWvtpeUnMOCRXSecK2eqZDZ4ju8m3KmKTCkoV4NLKMvR1h2MFAn2Kur3xlk2Bsp4h0BCGVh8zJoGeSs",
+	"fbd4xSmKru8NCAkcaZoidWD0vR5UpK2xkjatBricETzz359OVX507z9+YJ8EVJW0jK61DfXgAxWWJtD7",
+	"70DUaYEo53HBsy4Bqz/yDvpZW7nmbRW2o+gRnZth0SKLKjOgnElQz6sWKpVuFWIZ66ewpNP6MEkaEOVN",
+	"tUXu8MqTP6kZ7PNu2V9XCkTJ37LwJMuq4tGO4YQroYFSvFgeJoJzAjIFeZCZve4glZ9lMVelw/hvfPu2",
+	"2FDXm2KVfYlXd3grOxKCgbfs1NCvsWRDCYSlVOGZHd5PXeOe2ITSboeqp7q5HsaFaounoJ1NyvcahpBo",
+	"FeQmltTApfJ+EndZEBzEjaUzSIcSvGg13TMsqeRhniHa4q1zYb6CA0d4GqeVbYj0Vgw8A5cPFDRUkkmw",
+	"qYUAV4oTHzbCKJnU6z3MkPFfyHBd3kqZaBsCF4qsQBRVhsfbsvSla5F5dSzeyk7VpRarYg7RX60CLdY5",
+	"CqasoyI5hJXAqX5pXZv5xXk8dLWLkRLJ9H1y9PBKFotyCk5fSoIeraLZ0FGDFxrwykbGENeqhUgKrm7Q",
+	"aTYJTfGDMmarJCs3cJmVctiRwAqtnRFlVONF27Ef1uKHI4EG/ldiJhnbaqVuL6UP1OoG2zGS8nIBJENi",
+	"oweq5USkvKmRJ+e0EOl4IazFwEOP7WG9RgPHlXBuzq8yL6w26yxIJuIG5IbjBcaSem0C/9f1bUbQfT9w",
+	"oyXT0hLAA64cE9f9i7f4Pj9UZXAPN/8drntlliVf5pYpvF1UjqBx08zaJzdnPiHLsYC1c48Ue6GrvA/l",
+	"9y8ZX9KDY8aCvHtGfiwHvgwn2/ri1+iyrpKDvgoOFMQdYP6ElhRmEBtyraPFVGtjkYE9aRcW+Zv8jKys",
+	"Q39KXvGR/k58kz1wTFjm2vAA5OnGHseo4Gm4cfx5GHnmHhGm8pvsibcjLHnhzFUTJBsxX70mP2KiUQZ1",
+	"vtso5e9M6zTwPCgpkIY8xpihTTg34iYpBxQh1C3Kxo8SZ2jUXL+w/lnuOwzmTQpj2RmaFE3LLWRg7YR6",
+	"IKuWwDOt+bnlc6kzhE7hIe87hePbYp3vI7BPte55AH+VfMkqsR9UYUIXpy6+X7kwVbnww+FqRa1JNPvd",
+	"aseYwml/hMJZySxVhwyvmXXK5wmsinSQZROKQrObEoS+6nlpXWQJPJs4N79lstDwZNk5/gnZGoKw1mmo",
+	"1tfrOB9+mxYRn0wfn57+y8pdDVKYpfZLReB6qCBtpjTsJDPeI4MF3zWwzCqVegQzoGB6DXFHzyA+J4eh",
+	"U++dwUS/zrVaPsk2pRyIihHskqnlBzx74eLxzxq6OaA5vDjua2W3jtcZwH9dOgvRLpx72EmPPWbHGxRm",
+	"EuuYI8uDmmnBT7lqBdE/lsvIkK08P2QOD307NJqjst3DSuAB65XKf7MqW76DlYNprQ4Ggv6HDtASjXhN",
+	"hnsp1Av3oELspS3F9OCQoYwGDkrJ0+e6ORkjdrLLO8Dv6Pn38ZXndErzPJxygESZlxyqXbjn/Hcbhznr",
+	"ab1MTKe0W2mGWA+Hiw0MKbawxU1Pw6gZl5uJa7jzOmUem3ObtQTr8et+4NebdbU2Xzm9Y1xy6qDVlp0q",
+	"+tm6KQwraebtASThOeoBNE1hAToR9d7U2BT+Pq2Kk3TZzncdNSsQ+Ie0MA9bV61ny6ci0FbxdFlrwDQg",
+	"MnZbORBTCl3dD8MacwMjJV/lLK8ljw7z1BFhF9nQRpZqdIANsWVc5s+BpsxEG4IwUiAGsiRBpjsE48qb",
+	"cEqYsTTjv2TFGS3y9xzKNexRzSs2LNgfzjgEUMZNwZcL/sUKprRX8mZmmt5BV+o5ZbGynkG5jsrrxFVV",
+	"VUir748YGw+jAbJYPheeF5gbvnKrib+oH4MeHtIv1ujK1E9haQbRHUZUA2AiHN6lEOviJ7w4Im1tWXuy",
+	"h4v/NIXAB7LjFfRqpLSU6QTRz9jDpHKtGcVhNGILvj/IChaxDkOQQnEs0NzwmufobK/ohbaDFBu+dmi3",
+	"upetIR3tEKByktt0itqEqMbwudTRjdEP6rCBMeFUnBV/S9sj09kVZZ8Nh0mKptnB/aTyIi1Bj0bRIJgG",
+	"O3lcfvhdLuG0XRFDbeKq5BtUyKRrwc4p2FGq4vRI0SCkKOvZNLgGD8HEzKFwqkcwAvox40MvEfQ5trfC",
+	"GYdWfvFLI5+mO5OZ7jc8nPJ9DpC8lVGOb3PORMUiOdYYuuDdIioFKJHHLNKWDANjF6bYdB5CKfdhz7rx",
+	"DOi/noXOB3bnKcLK0vkeoxpDuAIno8rVA7pKeXXVBKeofl5O45xI1i+dRcPU1Jlvwz9Q3LCbifmbY8hz",
+	"2y3xtiIPaLq19E9J1Cax3n8WjjjPai0rziDXNGFhI+dc3vSj3ZbecvtLOdWJcn0jTAEKo9X4wFlI5Ljp",
+	"qVckvGoHEXP1hFJXp7Z8ab0lqRm9dDDtq63P9A3HHS+TXHlNdVlJ9g1RnWMaPJUroGRZ6wG1LiHzVdJ5",
+	"dPCJlF5DaVr3RqTXryPxoO4GZtlfU2U3MGOstgd7s1WSM2KeuJBVLvJxdrRf6ZqmlTO902Gvhw77p7pD",
+	"Jh32nLwfPRPcU0+D5l3w+F4JvOlMc+7m9I8/Kv46yVhZ4QFN707P4Spou5Edr5M0DsRGp+XmqTrGNJyH",
+	"+YAlHxWySW+6/zagp6dBSv82NPnmKAsMp5nopyLoV7T4AW8bNgpN1PfIP8xU947Fe+IZ72Yqf7jpf9P8",
+	"ymOglxIzVkSSTtt0CNGM4InCeYalIbUqX5fLMCGhvI2dYjYoNbuGewPHndYpQXveUd6Vszi5ls+oDlc8",
+	"oc4tz/OfKclaMReq7tfh3B6FYEGrd9JfE+P7Jc1ym6bzSgU97bBr/N0aqsY50qpZO+kFycBig1jqvTMi",
+	"6n/RMmHjpo208qdVJLKl/WiVpFSefRuhfvf3sshop9wPSOb68ZJMa0CS42B42a1+zu5UPAMZizQ6ACq7",
+	"qsF+I3iEM3Vi7RTiJpiHGWIG36Jq3XI369egnnacwI3GFW9L4KadimqPtzUgV0Ym7ypr31XWDsw59WSL",
+	"neMCRCcuqKUaIBapxqBo+uUdr9cRr9NuzZy9ynmZzlWnp0KxwfWANiSGE02beir9Xdsq/vdy9d2xB8LS",
+	"PkwDj5RCxdLH6U1nUYMlX2aovzJzxm5W/fLGH9l4fZW0UpimND6mbjNjlQc4AzvO6D2+B/UCNzT/zn5/",
+	"mn5j+vFd2/fu2o51F/mH/gqr1WaErbXpM7Qpu2svZw285ITEJvwWhaGb+oQFck8hLLV0X+3D3LLKnV9S",
+	"B0L8Fn4RC79HlxPd1PR0Axk72Sekz/fVX5m+GxQ7JudCfSQd+gNLnhdIyRHP5JvB9r3Asm/qztg2tIqG",
+	"9sqDW82k0nhyU+jWah/NDfRBylL/WP9BF2qQf2/53hlbo4ygY9VOCdC/q856i3Vhce/lCRJde5l6No+v",
+	"K1X7PPk461a+TNoTUoeG5qh4PZfa46Phahf004yIXzJ3wNQFR80JvPl+4dmEskv8pwe1eeu1lh9Tmk3F",
+	"cGpLyzbG3lQD1qcWwrnZPT2pmpS/OeCzkcDw9fzus5MyZ2CncfqpAkPvhXF+UmHQ+ENOvl1WT75dmDru",
+	"6Nu9M/Qgsp9uGcWT+Fr/SY13quh7oIr+mCuWslIp6iX6zSL8qRdC/9iaTisR6Ign4+oic+Ji8QKkLv5v",
+	"APqRExvHigAA",
+}
+
+// GetSwagger returns the content of the embedded swagger specification file
+// or error if failed to decode
+func decodeSpec() ([]byte, error) {
+	zipped, err := base64.StdEncoding.DecodeString(strings.Join(swaggerSpec, ""))
+	if err != nil {
+		return nil, fmt.Errorf("error base64 decoding spec: %w", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(zipped))
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing spec: %w", err)
+	}
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(zr)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing spec: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+var rawSpec = decodeSpecCached()
+
+// a naive cached of a decoded swagger spec
+func decodeSpecCached() func() ([]byte, error) {
+	data, err := decodeSpec()
+	return func() ([]byte, error) {
+		return data, err
+	}
+}
+
+// Constructs a synthetic filesystem for resolving external references when loading openapi specifications.
+func PathToRawSpec(pathToFile string) map[string]func() ([]byte, error) {
+	res := make(map[string]func() ([]byte, error))
+	if len(pathToFile) > 0 {
+		res[pathToFile] = rawSpec
+	}
+
+	return res
+}
+
+// GetSwagger returns the Swagger specification corresponding to the generated code
+// in this file. The external references of Swagger specification are resolved.
+// The logic of resolving external references is tightly connected to "import-mapping" feature.
+// Externally referenced files must be embedded in the corresponding golang packages.
+// Urls can be supported but this task was out of the scope.
+func GetSwagger() (swagger *openapi3.T, err error) {
+	resolvePath := PathToRawSpec("")
+
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+	loader.ReadFromURIFunc = func(loader *openapi3.Loader, url *url.URL) ([]byte, error) {
+		pathToFile := url.String()
+		pathToFile = path.Clean(pathToFile)
+		getSpec, ok := resolvePath[pathToFile]
+		if !ok {
+			err1 := fmt.Errorf("path not found: %s", pathToFile)
+			return nil, err1
+		}
+		return getSpec()
+	}
+	var specData []byte
+	specData, err = rawSpec()
+	if err != nil {
+		return
+	}
+	swagger, err = loader.LoadFromData(specData)
+	if err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,95 @@
+package http_api
+
+import (
+	"avito_intr/internal/ratelimit"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// clientHeader - заголовок, которым интеграция сообщает свой идентификатор для отдельного лимита
+const clientHeader = "X-Client-ID"
+
+// rateLimitGroups относит операции к группам лимитов, остальные операции не ограничиваются.
+// Ключи - OperationID встроенной спецификации, они совпадают с методами openapi.ServerInterface.
+var rateLimitGroups = map[string]string{
+	"DummyLogin":            ratelimit.GroupAuth,
+	"Register":              ratelimit.GroupAuth,
+	"Login":                 ratelimit.GroupAuth,
+	"ListPvz":               ratelimit.GroupList,
+	"ExportReceptions":      ratelimit.GroupList,
+	"GetAnalytics":          ratelimit.GroupList,
+	"GetPvz":                ratelimit.GroupRead,
+	"GetOpenReception":      ratelimit.GroupRead,
+	"ListWebhooks":          ratelimit.GroupRead,
+	"ListWebhookDeliveries": ratelimit.GroupRead,
+	"StreamEvents":          ratelimit.GroupRead,
+	"CreateEventSession":    ratelimit.GroupRead,
+	"CreatePvz":             ratelimit.GroupWrite,
+	"CreateReception":       ratelimit.GroupWrite,
+	"AddProduct":            ratelimit.GroupWrite,
+	"CloseLastReception":    ratelimit.GroupWrite,
+	"DeleteLastProduct":     ratelimit.GroupWrite,
+	"CreateWebhook":         ratelimit.GroupWrite,
+	"DeleteWebhook":         ratelimit.GroupWrite,
+}
+
+// rateCheck - проверки лимитов одного запроса: по клиенту и IP до аутентификации, по пользователю после неё.
+// Заголовки X-RateLimit-* описывают самый строгий из уже проверенных ключей.
+type rateCheck struct {
+	group   string
+	d       ratelimit.Decision
+	limited bool
+}
+
+func newRateCheck(operationID string) *rateCheck {
+	return &rateCheck{group: rateLimitGroups[operationID]}
+}
+
+// rateLimit берёт токены по keys и обновляет заголовки X-RateLimit-*.
+// false - лимит исчерпан и ответ 429 уже отправлен.
+func (s *Server) rateLimit(w http.ResponseWriter, r *http.Request, c *rateCheck, keys ratelimit.Keys) bool {
+	if s.limiter == nil || c.group == "" {
+		return true
+	}
+	d, limited := s.limiter.Allow(r.Context(), c.group, keys)
+	if !limited {
+		return true
+	}
+	if !c.limited || d.Stricter(c.d) {
+		c.d, c.limited = d, true
+	}
+	h := w.Header()
+	h.Set("X-RateLimit-Limit", strconv.Itoa(c.d.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(c.d.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(c.d.Reset)))
+	if c.d.Allowed {
+		return true
+	}
+	h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(c.d.RetryAfter), 1)))
+	s.writeError(w, r, http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded for "+c.d.Scope)
+	return false
+}
+
+func clientID(r *http.Request) string {
+	return ratelimit.ClientID(r.Header.Get(clientHeader))
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientIP - адрес соединения или, если соединение пришло от доверенного прокси, адрес из X-Forwarded-For
+func (s *Server) clientIP(r *http.Request) string {
+	return s.trustedProxies.ClientIP(remoteHost(r), r.Header.Values("X-Forwarded-For"))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,136 @@
+package http_api
+
+import (
+	"avito_intr/internal/http_api/openapi"
+	"avito_intr/internal/ratelimit"
+	"encoding/json"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRateLimit(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), map[string]ratelimit.Group{
+		ratelimit.GroupWrite: {User: ratelimit.Limit{Rate: 0.1, Burst: 2}, IP: ratelimit.Limit{Rate: 1, Burst: 100}},
+	}, zap.NewNop())
+	s := NewServer(&webhookStore{}, stubAuth{}, nil, Options{ValidateResponses: true, RateLimiter: limiter}, zap.NewNop())
+	deleteWebhook := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("DELETE", "/v1/webhooks/55555555-5555-5555-5555-555555555555", nil)
+		req.Header.Set("Authorization", "Bearer valid")
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for remaining := 1; remaining >= 0; remaining-- {
+		rr := deleteWebhook()
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("status = %d, body %s", rr.Code, rr.Body.String())
+		}
+		if rr.Header().Get("X-RateLimit-Limit") != "2" || rr.Header().Get("X-RateLimit-Remaining") != strconv.Itoa(remaining) {
+			t.Errorf("limit headers = %v", rr.Header())
+		}
+	}
+
+	rr := deleteWebhook()
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "10" {
+		t.Fatalf("status = %d, Retry-After %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	var body struct{ Code string }
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Code != string(codeRateLimited) {
+		t.Errorf("body = %s", rr.Body.String())
+	}
+
+	// операции вне групп не ограничены
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/ping", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Limit") != "" {
+		t.Errorf("ping: status %d, headers %v", rr.Code, rr.Header())
+	}
+}
+
+func TestRateLimitBeforeAuth(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), map[string]ratelimit.Group{
+		ratelimit.GroupWrite: {User: ratelimit.Limit{Rate: 0.1, Burst: 100}, IP: ratelimit.Limit{Rate: 0.1, Burst: 2}},
+	}, zap.NewNop())
+	s := NewServer(&webhookStore{}, stubAuth{}, nil, Options{RateLimiter: limiter}, zap.NewNop())
+	deleteWebhook := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("DELETE", "/v1/webhooks/55555555-5555-5555-5555-555555555555", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// неверные токены расходуют ведро IP, поэтому перебор токенов тоже ограничен
+	for i := 0; i < 2; i++ {
+		if rr := deleteWebhook("guess"); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("status = %d, body %s", rr.Code, rr.Body.String())
+		}
+	}
+	rr := deleteWebhook("guess")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "10" {
+		t.Fatalf("status = %d, Retry-After %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	if rr := deleteWebhook("valid"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("valid token from a limited ip: status = %d", rr.Code)
+	}
+}
+
+func TestRateLimitTrustedProxy(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), map[string]ratelimit.Group{
+		ratelimit.GroupAuth: {IP: ratelimit.Limit{Rate: 0.1, Burst: 1}},
+	}, zap.NewNop())
+	proxies, _ := ratelimit.ParseTrustedProxies([]string{"10.0.0.0/8"})
+	s := NewServer(&webhookStore{}, stubAuth{}, nil, Options{RateLimiter: limiter, TrustedProxies: proxies}, zap.NewNop())
+	login := func(remote, forwarded string) int {
+		req := httptest.NewRequest("POST", "/v1/dummyLogin", strings.NewReader(`{"role":"employee"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remote + ":1234"
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// клиенты за прокси считаются по X-Forwarded-For
+	if code := login("10.0.0.1", "198.51.100.1"); code != http.StatusOK {
+		t.Fatalf("first client: status = %d", code)
+	}
+	if code := login("10.0.0.1", "198.51.100.2"); code != http.StatusOK {
+		t.Errorf("second client behind the proxy: status = %d", code)
+	}
+	if code := login("10.0.0.2", "198.51.100.1"); code != http.StatusTooManyRequests {
+		t.Errorf("first client again: status = %d", code)
+	}
+	// не прокси не может подставить себе другой адрес
+	if code := login("203.0.113.1", "198.51.100.3"); code != http.StatusOK {
+		t.Fatalf("direct client: status = %d", code)
+	}
+	if code := login("203.0.113.1", "198.51.100.4"); code != http.StatusTooManyRequests {
+		t.Errorf("spoofed X-Forwarded-For: status = %d", code)
+	}
+}
+
+func TestRateLimitGroupsMatchSpec(t *testing.T) {
+	doc, err := openapi.GetSwagger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	operations := map[string]bool{}
+	for _, item := range doc.Paths.Map() {
+		for _, op := range item.Operations() {
+			operations[op.OperationID] = true
+		}
+	}
+	for id := range rateLimitGroups {
+		if !operations[id] {
+			t.Errorf("rate limit group for unknown operation %s", id)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package http_api
+
+import (
+	"avito_intr/internal/http_api/openapi"
+	"avito_intr/internal/requestid"
+	"avito_intr/internal/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// Коды ошибок стабильны, клиенты могут на них полагаться. Текст message может меняться.
+// Перечень задан в схеме Error в swagger.yaml.
+const (
+	codeInvalidRequest       = openapi.ErrorCodeInvalidRequest
+	codeUnauthorized         = openapi.ErrorCodeUnauthorized
+	codeLoginFailed          = openapi.ErrorCodeLoginFailed
+	codeForbidden            = openapi.ErrorCodeForbidden
+	codeOperationFailed      = openapi.ErrorCodeOperationFailed
+	codeNotFound             = openapi.ErrorCodeNotFound
+	codeMethodNotAllowed     = openapi.ErrorCodeMethodNotAllowed
+	codeIdempotencyKeyInUse  = openapi.ErrorCodeIdempotencyKeyInUse
+	codeIdempotencyKeyReused = openapi.ErrorCodeIdempotencyKeyReused
+	codePreconditionFailed   = openapi.ErrorCodePreconditionFailed
+	codeRateLimited          = openapi.ErrorCodeRateLimited
+	codeInternal             = openapi.ErrorCodeInternalError
+)
+
+const (
+	internalErrorMessage = "internal server error"
+	invalidBodyMessage   = "request body is not valid JSON"
+)
+
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, status int, code openapi.ErrorCode, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Del("Content-Disposition")
+	w.WriteHeader(status)
+	resp := openapi.Error{Message: message, Code: code}
+	// requestId совпадает с заголовком X-Request-ID
+	if id := requestid.FromContext(r.Context()); id != "" {
+		resp.RequestId = &id
+	}
+	err := json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		s.requestLogger(r).Error("failed to write error response", zap.Error(err))
+	}
+}
+
+// writeStorageError отдаёт клиенту текст только доменных ошибок хранилища с указанным статусом.
+// Остальные ошибки (Postgres, сеть, баги) логируются, а клиент получает 500 без подробностей.
+func (s *Server) writeStorageError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	var failed storage.ReceptionFailed
+	var login storage.LoginFailed
+	switch {
+	case errors.Is(err, storage.ErrWebhookNotFound) || errors.Is(err, storage.ErrPvzNotFound):
+		s.writeError(w, r, http.StatusNotFound, codeNotFound, err.Error())
+	case errors.Is(err, storage.ErrVersionMismatch):
+		s.writeError(w, r, http.StatusPreconditionFailed, codePreconditionFailed, preconditionFailedMessage)
+	case errors.As(err, &failed):
+		s.writeError(w, r, status, codeOperationFailed, failed.Message)
+	case errors.As(err, &login) && status == http.StatusForbidden:
+		s.writeError(w, r, status, codeForbidden, login.Message)
+	case errors.As(err, &login):
+		s.writeError(w, r, status, codeLoginFailed, login.Message)
+	default:
+		s.writeInternalError(w, r, err)
+	}
+}
+
+func (s *Server) writeInternalError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := s.requestLogger(r)
+	if errors.Is(err, context.Canceled) {
+		logger.Info("request cancelled", zap.Error(err))
+	} else {
+		logger.Error("internal error", zap.Error(err))
+	}
+	s.writeError(w, r, http.StatusInternalServerError, codeInternal, internalErrorMessage)
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.requestLogger(r).Error("failed to write response", zap.Error(err))
+	}
+}
+
+func (s *Server) notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	s.writeError(w, r, http.StatusNotFound, codeNotFound, "route not found")
+}
+
+func (s *Server) methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	s.writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "method not allowed")
+}
+
+// requestLogger возвращает логгер с request_id текущего запроса
+func (s *Server) requestLogger(r *http.Request) *zap.Logger {
+	return requestid.Logger(r.Context(), s.logger)
+}
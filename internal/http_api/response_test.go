@@ -0,0 +1,76 @@
+package http_api
+
+import (
+	"avito_intr/internal/http_api/openapi"
+	"avito_intr/internal/storage"
+	"encoding/json"
+	"errors"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteStorageError(t *testing.T) {
+	s := &Server{logger: zap.NewNop()}
+	tests := []struct {
+		name    string
+		status  int
+		err     error
+		code    int
+		errCode openapi.ErrorCode
+		message string
+	}{
+		{"domain error", http.StatusBadRequest, storage.ReceptionFailed{Message: "no open reception in pvz"},
+			http.StatusBadRequest, codeOperationFailed, "no open reception in pvz"},
+		{"login failed", http.StatusUnauthorized, storage.LoginFailed{Message: "invalid email or password"},
+			http.StatusUnauthorized, codeLoginFailed, "invalid email or password"},
+		{"no permission", http.StatusForbidden, storage.LoginFailed{Message: "user has no permission"},
+			http.StatusForbidden, codeForbidden, "user has no permission"},
+		{"internal error", http.StatusBadRequest, errors.New(`ERROR: relation "receptions" does not exist (SQLSTATE 42P01)`),
+			http.StatusInternalServerError, codeInternal, internalErrorMessage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			s.writeStorageError(rr, httptest.NewRequest("GET", "/", nil), tt.status, tt.err)
+
+			if rr.Code != tt.code {
+				t.Errorf("status = %d, want %d", rr.Code, tt.code)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			var body openapi.Error
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != tt.errCode || body.Message != tt.message {
+				t.Errorf("body = %+v, want code %s, message %q", body, tt.errCode, tt.message)
+			}
+			if strings.Contains(rr.Body.String(), "SQLSTATE") {
+				t.Errorf("internal details leaked: %s", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestUnknownRouteIsJSON(t *testing.T) {
+	s := NewServer(nil, nil, nil, Options{}, zap.NewNop())
+	for method, want := range map[string]int{"GET": http.StatusNotFound, "DELETE": http.StatusMethodNotAllowed} {
+		path := "/no/such/route"
+		if method == "DELETE" {
+			path = "/pvz"
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+		if rr.Code != want {
+			t.Errorf("%s %s = %d, want %d", method, path, rr.Code, want)
+		}
+		var body openapi.Error
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Code == "" {
+			t.Errorf("%s %s body = %s, want JSON error", method, path, rr.Body.String())
+		}
+	}
+}
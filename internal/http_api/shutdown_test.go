@@ -0,0 +1,74 @@
+package http_api
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func freePort(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestListenAndServeDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	})
+	s := &Server{handler: slow, metricsHandler: http.NotFoundHandler(), logger: zap.NewNop()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	port := freePort(t)
+	served := make(chan error, 1)
+	go func() { served <- s.ListenAndServe(ctx, port, freePort(t), 5*time.Second) }()
+
+	var resp *http.Response
+	var err error
+	got := make(chan struct{})
+	go func() {
+		defer close(got)
+		for i := 0; i < 50; i++ {
+			if resp, err = http.Get("http://127.0.0.1:" + port + "/"); err == nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	<-started
+	cancel()
+	<-got
+	if err != nil {
+		t.Fatalf("in-flight request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != "done" {
+		t.Errorf("body = %q, want done", body)
+	}
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("ListenAndServe = %v, want nil after cancel", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ListenAndServe did not return after drain")
+	}
+
+	if _, err := http.Get("http://127.0.0.1:" + port + "/"); err == nil {
+		t.Error("server still accepts connections after shutdown")
+	}
+}
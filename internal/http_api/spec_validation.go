@@ -0,0 +1,246 @@
+package http_api
+
+import (
+	"avito_intr/internal/http_api/openapi"
+	"avito_intr/internal/idempotency"
+	"avito_intr/internal/ratelimit"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"go.uber.org/zap"
+	"mime"
+	"net/http"
+)
+
+// Форматы uuid и email kin-openapi сам не проверяет. uuid проверяется только по виду,
+// без версии и варианта, как и в базе. Текст ошибки для клиента не включает всю схему.
+func init() {
+	openapi3.DefineStringFormatValidator("uuid", openapi3.NewRegexpFormatValidator(
+		`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`))
+	openapi3.DefineStringFormatValidator("email", openapi3.NewRegexpFormatValidator(
+		`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`))
+	openapi3.SchemaErrorDetailsDisabled = true
+}
+
+// specValidator сверяет запросы и ответы со спецификацией, встроенной в пакет openapi
+type specValidator struct {
+	router            routers.Router
+	validateResponses bool
+	// unversioned - пути со своим servers в swagger.yaml, они обслуживаются только без префикса версии
+	unversioned map[string]bool
+	deprecated  map[*openapi3.Operation]deprecation
+	legacy      deprecation
+}
+
+func newSpecValidator(validateResponses bool, legacy deprecation) (*specValidator, error) {
+	doc, err := openapi.GetSwagger()
+	if err != nil {
+		return nil, fmt.Errorf("load embedded openapi spec: %w", err)
+	}
+	v := &specValidator{validateResponses: validateResponses, unversioned: map[string]bool{},
+		deprecated: map[*openapi3.Operation]deprecation{}, legacy: legacy}
+
+	// Маршруты сопоставляются только по пути без префикса версии, независимо от хоста.
+	// servers путей тоже убираются: gorillamux переносит их на все следующие пути.
+	doc.Servers = nil
+	dropOperationServers(doc)
+	for path, item := range doc.Paths.Map() {
+		if unversioned(item) {
+			v.unversioned[path] = true
+			item.Servers = nil
+		}
+		for _, op := range item.Operations() {
+			if !op.Deprecated {
+				continue
+			}
+			if v.deprecated[op], err = operationDeprecation(op); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if v.router, err = gorillamux.NewRouter(doc); err != nil {
+		return nil, fmt.Errorf("build openapi router: %w", err)
+	}
+	return v, nil
+}
+
+func requiresAuth(route *routers.Route) bool {
+	return allowsScheme(route, "bearerAuth")
+}
+
+func allowsScheme(route *routers.Route, scheme string) bool {
+	security := route.Operation.Security
+	if security == nil {
+		security = &route.Spec.Security
+	}
+	for _, requirement := range *security {
+		if _, ok := requirement[scheme]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// findRoute ищет операцию по пути без префикса версии
+func (v *specValidator) findRoute(r *http.Request) (*routers.Route, map[string]string, string, error) {
+	version, path := splitVersion(r.URL.Path)
+	if version == "" {
+		route, params, err := v.router.FindRoute(r)
+		return route, params, version, err
+	}
+	u := *r.URL
+	u.Path, u.RawPath = path, ""
+	stripped := r.WithContext(r.Context())
+	stripped.URL = &u
+	route, params, err := v.router.FindRoute(stripped)
+	return route, params, version, err
+}
+
+// specMiddleware выполняется до сгенерированных обработчиков: проверяет лимиты клиента и IP, токен, если операция
+// требует bearerAuth, и лимит пользователя, затем параметры и тело запроса. Ответ сверяется, только если это включено.
+// Устаревшие маршруты получают заголовки Deprecation и Sunset до любых проверок.
+func (s *Server) specMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, pathParams, version, err := s.spec.findRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch unversioned := s.spec.unversioned[route.Path]; {
+		case version != "" && unversioned:
+			s.notFoundHandler(w, r)
+			return
+		case version == "" && !unversioned:
+			if !s.spec.legacy.since.IsZero() {
+				s.spec.legacy.setHeaders(w.Header())
+			}
+			w.Header().Add("Link", fmt.Sprintf(`<%s%s%s>; rel="successor-version"`, s.baseURL(r), legacyVersion, r.URL.Path))
+		}
+		if d, ok := s.spec.deprecated[route.Operation]; ok {
+			d.setHeaders(w.Header())
+		}
+
+		// лимиты по клиенту и IP проверяются до токена, чтобы перебор токенов тоже упирался в них
+		limits := newRateCheck(route.Operation.OperationID)
+		if !s.rateLimit(w, r, limits, ratelimit.Keys{Client: clientID(r), IP: s.clientIP(r)}) {
+			return
+		}
+		if requiresAuth(route) {
+			uuid, ok := s.authenticateRoute(w, r, route)
+			if !ok {
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), "uuid", uuid))
+			if uuid != "" && !s.rateLimit(w, r, limits, ratelimit.Keys{User: uuid}) {
+				return
+			}
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+				SkipSettingDefaults: true,
+			},
+		}
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			s.writeError(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+
+		handler := next
+		if key := r.Header.Get(idempotency.Header); key != "" && acceptsIdempotencyKey(route.Operation) {
+			handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				s.serveIdempotent(w, r, next, route.Operation.OperationID, key)
+			})
+		}
+
+		if !s.spec.validateResponses {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		rec := &specRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(rec, r)
+		s.checkResponse(r, input, rec)
+	})
+}
+
+// authenticateRoute принимает cookie ленты только там, где её разрешает спецификация, и только без заголовка Authorization
+func (s *Server) authenticateRoute(w http.ResponseWriter, r *http.Request, route *routers.Route) (string, bool) {
+	cookie, err := r.Cookie(eventsCookie)
+	if err != nil || len(r.Header.Values("Authorization")) != 0 || !allowsScheme(route, "eventsCookie") {
+		return s.authenticate(w, r)
+	}
+	uuid, err := s.auth.ValidateScoped(cookie.Value, eventsScope)
+	if err != nil {
+		s.writeError(w, r, http.StatusUnauthorized, codeUnauthorized, "invalid or expired events cookie")
+		return "", false
+	}
+	return uuid, true
+}
+
+// checkResponse не меняет уже отправленный ответ, расхождение логируется, считается в метрике
+// и передаётся в Options.OnSpecViolation
+func (s *Server) checkResponse(r *http.Request, req *openapi3filter.RequestValidationInput, rec *specRecorder) {
+	if rec.body == nil {
+		return
+	}
+	input := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: req,
+		Status:                 rec.status,
+		Header:                 rec.Header(),
+		Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+	}
+	input.SetBodyBytes(rec.body.Bytes())
+	if err := openapi3filter.ValidateResponse(r.Context(), input); err != nil {
+		httpSpecViolationsTotal.WithLabelValues(normalizeMethod(r.Method), req.Route.Path).Inc()
+		s.requestLogger(r).Warn("response does not match openapi spec",
+			zap.String("operation", req.Route.Operation.OperationID), zap.Int("status", rec.status), zap.Error(err))
+		if s.onViolation != nil {
+			s.onViolation(r, err)
+		}
+	}
+}
+
+// specRecorder копирует JSON-ответ для проверки или сохранения, не задерживая его отправку клиенту.
+// Остальные ответы (CSV, XLSX, NDJSON) проходят без копирования.
+type specRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        *bytes.Buffer
+}
+
+func (w *specRecorder) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = code
+		if mediaType, _, err := mime.ParseMediaType(w.Header().Get("Content-Type")); err == nil && mediaType == "application/json" {
+			w.body = &bytes.Buffer{}
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *specRecorder) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.body != nil {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *specRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,185 @@
+package http_api
+
+import (
+	"avito_intr/internal/http_api/openapi"
+	"avito_intr/internal/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Сгенерированный пакет должен совпадать со swagger.yaml: после правки спецификации нужен go generate ./...
+func TestEmbeddedSpecMatchesSwagger(t *testing.T) {
+	loader := openapi3.NewLoader()
+	want, err := loader.LoadFromFile("../../swagger.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := want.Validate(loader.Context); err != nil {
+		t.Fatalf("swagger.yaml is not valid: %v", err)
+	}
+	got, err := openapi.GetSwagger()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dropOperationServers(got)
+	// генератор переписывает operationId в стиле Go: createPvz -> CreatePvz
+	for _, doc := range []*openapi3.T{want, got} {
+		for _, item := range doc.Paths.Map() {
+			for _, op := range item.Operations() {
+				op.OperationID = strings.ToLower(op.OperationID)
+			}
+		}
+	}
+	wantJSON, _ := json.Marshal(want)
+	gotJSON, _ := json.Marshal(got)
+	if string(wantJSON) != string(gotJSON) {
+		t.Error("internal/http_api/openapi is out of date with swagger.yaml, run go generate ./...")
+	}
+}
+
+type stubAuth struct{}
+
+func (stubAuth) Generate(id, role string) (string, error) { return role, nil }
+
+func (stubAuth) Validate(token string) (string, error) {
+	// токены из /dummyLogin не содержат идентификатора пользователя
+	if token == "dummy" {
+		return "", nil
+	}
+	if token != "valid" {
+		return "", errors.New("invalid token")
+	}
+	return "33333333-3333-3333-3333-333333333333", nil
+}
+
+// GenerateScoped кодирует scope в токен, чтобы ValidateScoped отличал токен ленты от обычного
+func (stubAuth) GenerateScoped(id, scope string, ttl time.Duration) (string, error) {
+	return scope + ":" + id, nil
+}
+
+func (stubAuth) ValidateScoped(token, scope string) (string, error) {
+	id, ok := strings.CutPrefix(token, scope+":")
+	if !ok {
+		return "", errors.New("invalid token scope")
+	}
+	return id, nil
+}
+
+type stubStore struct {
+	storage.Storage
+	reception storage.ReceptionInfo
+}
+
+func (s stubStore) OpenReception(ctx context.Context, author, pvz string, _ int64) (*storage.ReceptionInfo, error) {
+	rec := s.reception
+	rec.PvzId = pvz
+	return &rec, nil
+}
+
+func newStubServer(validateResponses bool, status storage.Status) *Server {
+	store := stubStore{reception: storage.ReceptionInfo{ReceptionId: "44444444-4444-4444-4444-444444444444",
+		DateTime: time.Date(2025, 4, 15, 12, 0, 0, 0, time.UTC), Status: status}}
+	return NewServer(store, stubAuth{}, nil, Options{ValidateResponses: validateResponses}, zap.NewNop())
+}
+
+func TestSpecMiddleware(t *testing.T) {
+	s := newStubServer(false, storage.Active)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		code   int
+	}{
+		{"no token", "POST", "/receptions", "", `{"pvzId":"11111111-1111-1111-1111-111111111111"}`, http.StatusUnauthorized},
+		{"token checked before body", "POST", "/pvz", "invalid", `{"city":"Тверь"}`, http.StatusUnauthorized},
+		{"city not in enum", "POST", "/pvz", "valid", `{"city":"Тверь"}`, http.StatusBadRequest},
+		{"pvzId not uuid", "POST", "/receptions", "valid", `{"pvzId":"not-a-uuid"}`, http.StatusBadRequest},
+		{"missing required field", "POST", "/products", "valid", `{"type":"обувь"}`, http.StatusBadRequest},
+		{"limit out of range", "GET", "/pvz?limit=31", "valid", "", http.StatusBadRequest},
+		{"unknown role", "POST", "/dummyLogin", "", `{"role":"admin"}`, http.StatusBadRequest},
+		{"invalid email", "POST", "/register", "", `{"email":"user@","password":"p","role":"employee"}`, http.StatusBadRequest},
+		{"path param not uuid", "POST", "/pvz/42/close_last_reception", "valid", "", http.StatusBadRequest},
+		{"valid request", "POST", "/receptions", "valid", `{"pvzId":"11111111-1111-1111-1111-111111111111"}`, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			if rr.Code != tt.code {
+				t.Fatalf("status = %d, want %d, body %s", rr.Code, tt.code, rr.Body.String())
+			}
+			if rr.Code == http.StatusBadRequest {
+				var body openapi.Error
+				if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Code != codeInvalidRequest {
+					t.Errorf("body = %s, want invalid_request error", rr.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestReceptionResponseHasStatus(t *testing.T) {
+	s := newStubServer(false, storage.Active)
+	req := httptest.NewRequest("POST", "/receptions", strings.NewReader(`{"pvzId":"11111111-1111-1111-1111-111111111111"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer valid")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	var body openapi.Reception
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Status != openapi.InProgress || body.PvzId.String() != "11111111-1111-1111-1111-111111111111" {
+		t.Errorf("reception = %+v", body)
+	}
+}
+
+func TestResponseValidation(t *testing.T) {
+	httpSpecViolationsTotal.Reset()
+	for _, validate := range []bool{false, true} {
+		s := newStubServer(validate, "unknown")
+		req := httptest.NewRequest("POST", "/receptions", strings.NewReader(`{"pvzId":"11111111-1111-1111-1111-111111111111"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer valid")
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		if rr.Code != http.StatusCreated {
+			t.Errorf("validate=%v: status = %d, response must be sent as is", validate, rr.Code)
+		}
+	}
+	if got := testutil.ToFloat64(httpSpecViolationsTotal.WithLabelValues("POST", "/receptions")); got != 1 {
+		t.Errorf("violations = %v, want 1", got)
+	}
+}
+
+func TestOnSpecViolation(t *testing.T) {
+	var violations []string
+	s := newStubServer(true, "unknown")
+	s.onViolation = func(r *http.Request, err error) { violations = append(violations, r.URL.Path) }
+	req := httptest.NewRequest("POST", "/receptions", strings.NewReader(`{"pvzId":"11111111-1111-1111-1111-111111111111"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer valid")
+	s.ServeHTTP(httptest.NewRecorder(), req)
+	if len(violations) != 1 || violations[0] != "/receptions" {
+		t.Errorf("violations = %v, want the reception with an unknown status", violations)
+	}
+}
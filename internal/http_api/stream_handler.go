@@ -0,0 +1,77 @@
+package http_api
+
+import (
+	"avito_intr/internal/storage"
+	"encoding/json"
+	"go.uber.org/zap"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	ndjsonContentType = "application/x-ndjson"
+	streamFlushEvery  = 100
+	streamFlushPeriod = 500 * time.Millisecond
+)
+
+func acceptsNDJSON(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mediaType == ndjsonContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// streamPvz отдаёт все ПВЗ по фильтру построчно в формате NDJSON, по одному ПВЗ на строку.
+// Ответ сбрасывается клиенту каждые streamFlushEvery ПВЗ или streamFlushPeriod,
+// а отмена контекста запроса прерывает чтение из базы.
+func (s *Server) streamPvz(w http.ResponseWriter, r *http.Request, filter storage.PvzFilter) {
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	started := false
+	sent, pending := 0, 0
+	lastFlush := time.Now()
+	flush := func() {
+		if flusher != nil && pending > 0 {
+			flusher.Flush()
+		}
+		pending = 0
+		lastFlush = time.Now()
+	}
+
+	err := s.store.StreamPvzInfo(r.Context(), filter, func(pvz storage.PvzInfo) error {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", ndjsonContentType)
+			w.WriteHeader(http.StatusOK)
+		}
+		if err := enc.Encode(newPvzListItem(pvz)); err != nil {
+			return err
+		}
+		sent++
+		pending++
+		if pending >= streamFlushEvery || time.Since(lastFlush) >= streamFlushPeriod {
+			flush()
+		}
+		return nil
+	})
+	if err != nil && !started {
+		s.writeStorageError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		s.requestLogger(r).Warn("pvz stream interrupted", zap.Int("sent", sent), zap.Error(err))
+		panic(http.ErrAbortHandler)
+	}
+
+	if !started {
+		w.Header().Set("Content-Type", ndjsonContentType)
+		w.WriteHeader(http.StatusOK)
+	}
+	flush()
+}
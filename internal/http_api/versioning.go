@@ -0,0 +1,77 @@
+package http_api
+
+import (
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiVersions - префиксы, под которыми опубликованы операции swagger.yaml. Версии разделяют
+// обработчики Server; следующая версия может переопределить часть из них, встроив *Server в свой тип.
+var apiVersions = []string{"/v1"}
+
+// legacyVersion - версия, псевдонимами которой служат пути без префикса.
+// Такие пути оставлены для терминалов, написанных до появления версий.
+const legacyVersion = "/v1"
+
+// deprecation описывает заголовки устаревшего маршрута: Deprecation (RFC 9745) и Sunset (RFC 8594)
+type deprecation struct {
+	since  time.Time
+	sunset time.Time
+}
+
+func (d deprecation) setHeaders(h http.Header) {
+	h.Set("Deprecation", "@"+strconv.FormatInt(d.since.Unix(), 10))
+	if !d.sunset.IsZero() {
+		h.Set("Sunset", d.sunset.UTC().Format(http.TimeFormat))
+	}
+}
+
+// splitVersion отделяет префикс версии от пути запроса. Для путей без префикса version пустая.
+func splitVersion(path string) (version, rest string) {
+	for _, v := range apiVersions {
+		if rest, ok := strings.CutPrefix(path, v); ok && strings.HasPrefix(rest, "/") {
+			return v, rest
+		}
+	}
+	return "", path
+}
+
+// operationDeprecation читает даты из расширений x-deprecated-since и x-sunset операции,
+// помеченной в swagger.yaml как deprecated.
+func operationDeprecation(op *openapi3.Operation) (deprecation, error) {
+	var d deprecation
+	for name, field := range map[string]*time.Time{"x-deprecated-since": &d.since, "x-sunset": &d.sunset} {
+		value, ok := op.Extensions[name]
+		if !ok {
+			continue
+		}
+		s, _ := value.(string)
+		t, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			return d, fmt.Errorf("operation %s: %s must be a date YYYY-MM-DD, got %v", op.OperationID, name, value)
+		}
+		*field = t
+	}
+	if d.since.IsZero() {
+		return d, fmt.Errorf("operation %s is deprecated but has no x-deprecated-since", op.OperationID)
+	}
+	return d, nil
+}
+
+// unversioned сообщает, что путь объявлен в swagger.yaml со своим servers и не входит в версии API
+func unversioned(item *openapi3.PathItem) bool {
+	return len(item.Servers) > 0
+}
+
+// dropOperationServers убирает servers, которые генератор копирует из пути в каждую его операцию
+func dropOperationServers(doc *openapi3.T) {
+	for _, item := range doc.Paths.Map() {
+		for _, op := range item.Operations() {
+			op.Servers = nil
+		}
+	}
+}
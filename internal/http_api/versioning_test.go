@@ -0,0 +1,79 @@
+package http_api
+
+import (
+	"avito_intr/internal/storage"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVersionedRoutes(t *testing.T) {
+	store := stubStore{reception: storage.ReceptionInfo{ReceptionId: "44444444-4444-4444-4444-444444444444",
+		DateTime: time.Date(2025, 4, 15, 12, 0, 0, 0, time.UTC), Status: storage.Active}}
+	since, sunset := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), time.Date(2027, 4, 1, 0, 0, 0, 0, time.UTC)
+	s := NewServer(store, stubAuth{}, nil, Options{LegacyDeprecated: since, LegacySunset: sunset}, zap.NewNop())
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		code        int
+		deprecation string
+		sunset      string
+		link        string
+	}{
+		{"current version", "POST", "/v1/receptions", http.StatusCreated, "", "", ""},
+		{"legacy alias", "POST", "/receptions", http.StatusCreated, "@1792281600", "Thu, 01 Apr 2027 00:00:00 GMT",
+			`<http://pvz.internal/v1/receptions>; rel="successor-version"`},
+		{"legacy alias on error", "POST", "/pvz", http.StatusBadRequest, "@1792281600", "Thu, 01 Apr 2027 00:00:00 GMT",
+			`<http://pvz.internal/v1/pvz>; rel="successor-version"`},
+		{"invalid request under version", "POST", "/v1/pvz", http.StatusBadRequest, "", "", ""},
+		{"service path", "GET", "/healthz", http.StatusOK, "", "", ""},
+		{"service path is not versioned", "GET", "/v1/healthz", http.StatusNotFound, "", "", ""},
+		{"deprecated operation", "GET", "/ping", http.StatusOK, "@1792281600", "", ""},
+		{"unknown version", "POST", "/v2/receptions", http.StatusNotFound, "", "", ""},
+		{"method not allowed under version", "DELETE", "/v1/pvz", http.StatusMethodNotAllowed, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"pvzId":"11111111-1111-1111-1111-111111111111"}`
+			if strings.HasSuffix(tt.path, "/pvz") {
+				body = `{"city":"Тверь"}`
+			}
+			req := httptest.NewRequest(tt.method, "http://pvz.internal"+tt.path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer valid")
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			if rr.Code != tt.code {
+				t.Fatalf("status = %d, want %d, body %s", rr.Code, tt.code, rr.Body.String())
+			}
+			for header, want := range map[string]string{"Deprecation": tt.deprecation, "Sunset": tt.sunset, "Link": tt.link} {
+				if got := rr.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLegacySunsetNotScheduled(t *testing.T) {
+	since := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	s := NewServer(nil, stubAuth{}, nil, Options{LegacyDeprecated: since}, zap.NewNop())
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/pvz", nil))
+	if rr.Header().Get("Deprecation") != "@1792281600" || rr.Header().Get("Sunset") != "" {
+		t.Errorf("Deprecation = %q, Sunset = %q", rr.Header().Get("Deprecation"), rr.Header().Get("Sunset"))
+	}
+
+	// без даты в настройках пути без префикса не помечаются устаревшими
+	rr = httptest.NewRecorder()
+	NewServer(nil, stubAuth{}, nil, Options{}, zap.NewNop()).ServeHTTP(rr, httptest.NewRequest("GET", "/pvz", nil))
+	if rr.Header().Get("Deprecation") != "" || rr.Header().Get("Link") == "" {
+		t.Errorf("Deprecation = %q, Link = %q", rr.Header().Get("Deprecation"), rr.Header().Get("Link"))
+	}
+}
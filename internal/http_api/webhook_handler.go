@@ -0,0 +1,134 @@
+package http_api
+
+import (
+	"avito_intr/internal/http_api/openapi"
+	"avito_intr/internal/storage"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+	"net/http"
+	"net/url"
+)
+
+const defaultDeliveriesLimit = 50
+
+func newWebhook(hook storage.Webhook) openapi.Webhook {
+	res := openapi.Webhook{Id: idPtr(hook.WebhookId), Url: hook.URL, Events: make([]openapi.WebhookEventType, 0, len(hook.Events))}
+	if !hook.CreatedAt.IsZero() {
+		createdAt := hook.CreatedAt
+		res.CreatedAt = &createdAt
+	}
+	for _, event := range hook.Events {
+		res.Events = append(res.Events, openapi.WebhookEventType(event))
+	}
+	return res
+}
+
+func newWebhookDelivery(d storage.WebhookDelivery) openapi.WebhookDelivery {
+	res := openapi.WebhookDelivery{Id: parseID(d.DeliveryId), WebhookId: parseID(d.WebhookId), EventId: parseID(d.EventId),
+		Event: openapi.WebhookEventType(d.EventType), Status: openapi.WebhookDeliveryStatus(d.Status), Attempts: d.Attempts,
+		CreatedAt: d.CreatedAt, DeliveredAt: d.DeliveredAt}
+	if d.LastStatusCode != 0 {
+		res.LastStatusCode = &d.LastStatusCode
+	}
+	if d.LastError != "" {
+		res.LastError = &d.LastError
+	}
+	if d.Status == storage.DeliveryPending {
+		res.NextAttemptAt = &d.NextAttemptAt
+	}
+	return res
+}
+
+// validWebhookURL допускает только абсолютные адреса http(s)
+func validWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// webhookAuthor возвращает автора запроса к подпискам. Токены из /dummyLogin не несут ни пользователя,
+// ни проверяемой роли, поэтому управлять подписками с ними нельзя.
+func (s *Server) webhookAuthor(w http.ResponseWriter, r *http.Request) (string, bool) {
+	author := r.Context().Value("uuid").(string)
+	if author == "" {
+		s.writeError(w, r, http.StatusForbidden, codeForbidden, "user has no permission")
+		return "", false
+	}
+	return author, true
+}
+
+func (s *Server) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	author, ok := s.webhookAuthor(w, r)
+	if !ok {
+		return
+	}
+	var req openapi.CreateWebhookJSONRequestBody
+	if err := s.getBody(r, &req); err != nil {
+		s.writeError(w, r, http.StatusBadRequest, codeInvalidRequest, invalidBodyMessage)
+		return
+	}
+	if !validWebhookURL(req.Url) {
+		s.writeError(w, r, http.StatusBadRequest, codeInvalidRequest, "url must be an absolute http(s) URL")
+		return
+	}
+
+	hook := storage.Webhook{URL: req.Url, Secret: stringParam(req.Secret), Events: make([]string, 0, len(req.Events))}
+	for _, event := range req.Events {
+		hook.Events = append(hook.Events, string(event))
+	}
+	created, err := s.store.CreateWebhook(r.Context(), author, hook)
+	if err != nil {
+		s.writeStorageError(w, r, http.StatusForbidden, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusCreated, newWebhook(*created))
+}
+
+func (s *Server) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	author, ok := s.webhookAuthor(w, r)
+	if !ok {
+		return
+	}
+	hooks, err := s.store.ListWebhooks(r.Context(), author)
+	if err != nil {
+		s.writeStorageError(w, r, http.StatusForbidden, err)
+		return
+	}
+	resp := make([]openapi.Webhook, 0, len(hooks))
+	for _, hook := range hooks {
+		resp = append(resp, newWebhook(hook))
+	}
+	s.writeJSON(w, r, http.StatusOK, resp)
+}
+
+func (s *Server) DeleteWebhook(w http.ResponseWriter, r *http.Request, webhookId openapi_types.UUID) {
+	author, ok := s.webhookAuthor(w, r)
+	if !ok {
+		return
+	}
+	err := s.store.DeleteWebhook(r.Context(), author, webhookId.String())
+	if err != nil {
+		s.writeStorageError(w, r, http.StatusForbidden, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request, webhookId openapi_types.UUID, params openapi.ListWebhookDeliveriesParams) {
+	author, ok := s.webhookAuthor(w, r)
+	if !ok {
+		return
+	}
+	filter := storage.DeliveryFilter{Status: storage.DeliveryStatus(stringParam(params.Status)), Limit: defaultDeliveriesLimit}
+	if params.Limit != nil {
+		filter.Limit = *params.Limit
+	}
+	deliveries, err := s.store.ListWebhookDeliveries(r.Context(), author, webhookId.String(), filter)
+	if err != nil {
+		s.writeStorageError(w, r, http.StatusForbidden, err)
+		return
+	}
+	resp := make([]openapi.WebhookDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		resp = append(resp, newWebhookDelivery(d))
+	}
+	s.writeJSON(w, r, http.StatusOK, resp)
+}
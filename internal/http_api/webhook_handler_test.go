@@ -0,0 +1,119 @@
+package http_api
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// webhookStore хранит одну подписку и отвечает ErrWebhookNotFound на остальные id
+type webhookStore struct {
+	storage.Storage
+	created []storage.Webhook
+}
+
+func (w *webhookStore) CreateWebhook(ctx context.Context, author string, hook storage.Webhook) (*storage.Webhook, error) {
+	w.created = append(w.created, hook)
+	hook.WebhookId = "55555555-5555-5555-5555-555555555555"
+	hook.CreatedAt = time.Date(2025, 4, 15, 12, 0, 0, 0, time.UTC)
+	return &hook, nil
+}
+
+func (w *webhookStore) DeleteWebhook(ctx context.Context, author, webhookId string) error {
+	if webhookId != "55555555-5555-5555-5555-555555555555" {
+		return storage.ErrWebhookNotFound
+	}
+	return nil
+}
+
+func TestCreateWebhook(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"valid", `{"url":"https://example.com/hook","events":["pvz.created","reception.closed"],"secret":"0123456789abcdef"}`, http.StatusCreated},
+		{"not http", `{"url":"ftp://example.com/hook","events":["pvz.created"],"secret":"0123456789abcdef"}`, http.StatusBadRequest},
+		{"relative url", `{"url":"/hook","events":["pvz.created"],"secret":"0123456789abcdef"}`, http.StatusBadRequest},
+		{"unknown event", `{"url":"https://example.com/hook","events":["pvz.deleted"],"secret":"0123456789abcdef"}`, http.StatusBadRequest},
+		{"no events", `{"url":"https://example.com/hook","events":[],"secret":"0123456789abcdef"}`, http.StatusBadRequest},
+		{"short secret", `{"url":"https://example.com/hook","events":["pvz.created"],"secret":"short"}`, http.StatusBadRequest},
+		{"no secret", `{"url":"https://example.com/hook","events":["pvz.created"]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &webhookStore{}
+			s := NewServer(store, stubAuth{}, nil, Options{ValidateResponses: true}, zap.NewNop())
+			req := httptest.NewRequest("POST", "/v1/webhooks", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer valid")
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			if rr.Code != tt.code {
+				t.Fatalf("status = %d, want %d, body %s", rr.Code, tt.code, rr.Body.String())
+			}
+			if tt.code != http.StatusCreated {
+				if len(store.created) != 0 {
+					t.Error("invalid webhook must not reach the store")
+				}
+				return
+			}
+			if len(store.created) != 1 || store.created[0].Secret != "0123456789abcdef" {
+				t.Errorf("stored = %+v", store.created)
+			}
+			if strings.Contains(rr.Body.String(), "0123456789abcdef") {
+				t.Errorf("response leaks the secret: %s", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeleteWebhookNotFound(t *testing.T) {
+	s := NewServer(&webhookStore{}, stubAuth{}, nil, Options{}, zap.NewNop())
+	req := httptest.NewRequest("DELETE", "/v1/webhooks/66666666-6666-6666-6666-666666666666", nil)
+	req.Header.Set("Authorization", "Bearer valid")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound || errorCode(t, rr) != codeNotFound {
+		t.Errorf("status = %d, body %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestWebhooksForbiddenForDummyToken(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"POST", "/v1/webhooks", `{"url":"https://example.com/hook","events":["pvz.created"],"secret":"0123456789abcdef"}`},
+		{"GET", "/v1/webhooks", ""},
+		{"DELETE", "/v1/webhooks/55555555-5555-5555-5555-555555555555", ""},
+		{"GET", "/v1/webhooks/55555555-5555-5555-5555-555555555555/deliveries", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			store := &webhookStore{}
+			s := NewServer(store, stubAuth{}, nil, Options{ValidateResponses: true}, zap.NewNop())
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			// токен из /dummyLogin, например с ролью employee: пользователя в нём нет
+			req.Header.Set("Authorization", "Bearer dummy")
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusForbidden || errorCode(t, rr) != codeForbidden {
+				t.Errorf("status = %d, body %s", rr.Code, rr.Body.String())
+			}
+			if len(store.created) != 0 {
+				t.Error("forbidden request must not reach the store")
+			}
+		})
+	}
+}
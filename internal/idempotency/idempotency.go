@@ -0,0 +1,121 @@
+package idempotency
+
+import (
+	"avito_intr/internal/requestid"
+	"avito_intr/internal/storage"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	Header         = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+	// MetadataKey - то же для gRPC: ключ сессии ScanSession, повтор команды помечается полем duplicate ответа
+	MetadataKey = "idempotency-key"
+
+	MaxKeyLength = 255
+	DefaultTTL   = 24 * time.Hour
+)
+
+// Store - часть storage.Storage, в которой хранятся ключи и первые ответы
+type Store interface {
+	BeginIdempotent(ctx context.Context, caller, key, fingerprint string, ttl time.Duration) (*storage.IdempotentResponse, error)
+	CompleteIdempotent(ctx context.Context, caller, key string, resp storage.IdempotentResponse) error
+	AbortIdempotent(ctx context.Context, caller, key string) error
+	PurgeIdempotencyKeys(ctx context.Context) (int64, error)
+}
+
+var requestsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "idempotent_requests_total",
+		Help: "Requests with an idempotency key by outcome: executed, replayed, in_use, reused",
+	},
+	[]string{"transport", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(requestsTotal)
+}
+
+// ValidKey допускает ключи из печатных ASCII-символов длиной до MaxKeyLength
+func ValidKey(key string) bool {
+	if key == "" || len(key) > MaxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Fingerprint идентифицирует содержимое запроса: повтор с тем же ключом должен совпасть с первым запросом
+func Fingerprint(operation string, payload []byte) string {
+	h := sha256.New()
+	h.Write([]byte(operation))
+	h.Write([]byte{0})
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Begin занимает ключ за вызывающим. Сохранённый ответ означает повтор, nil - запрос нужно
+// выполнить и передать результат в Finish.
+func Begin(ctx context.Context, store Store, transport, caller, key, fingerprint string, ttl time.Duration) (*storage.IdempotentResponse, error) {
+	stored, err := store.BeginIdempotent(ctx, caller, key, fingerprint, ttl)
+	switch {
+	case errors.Is(err, storage.ErrIdempotencyKeyInUse):
+		requestsTotal.WithLabelValues(transport, "in_use").Inc()
+	case errors.Is(err, storage.ErrIdempotencyKeyReused):
+		requestsTotal.WithLabelValues(transport, "reused").Inc()
+	case err != nil:
+	case stored != nil:
+		requestsTotal.WithLabelValues(transport, "replayed").Inc()
+	default:
+		requestsTotal.WithLabelValues(transport, "executed").Inc()
+	}
+	return stored, err
+}
+
+// Finish сохраняет ответ для повторов, если keep, иначе освобождает ключ, чтобы повтор выполнил
+// запрос заново. Так делается для внутренних ошибок: их повтор может завершиться успешно.
+// Запрос уже завершён, поэтому отмена ctx клиентом не должна мешать записи.
+func Finish(ctx context.Context, store Store, caller, key string, resp storage.IdempotentResponse, keep bool, logger *zap.Logger) {
+	ctx = context.WithoutCancel(ctx)
+	var err error
+	if keep {
+		err = store.CompleteIdempotent(ctx, caller, key, resp)
+	} else {
+		err = store.AbortIdempotent(ctx, caller, key)
+	}
+	if err != nil {
+		requestid.Logger(ctx, logger).Error("failed to finish idempotent request",
+			zap.String("idempotency_key", key), zap.Bool("keep", keep), zap.Error(err))
+	}
+}
+
+// RunPurger периодически удаляет истёкшие ключи, пока не отменён ctx
+func RunPurger(ctx context.Context, store Store, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		n, err := store.PurgeIdempotencyKeys(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Warn("failed to purge idempotency keys", zap.Error(err))
+			continue
+		}
+		if n > 0 {
+			logger.Debug("purged expired idempotency keys", zap.Int64("count", n))
+		}
+	}
+}
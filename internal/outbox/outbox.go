@@ -0,0 +1,144 @@
+package outbox
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	defaultBatchSize = 100
+	// purgeInterval - как часто удаляются опубликованные сообщения старше Retention
+	purgeInterval = 10 * time.Minute
+)
+
+// Store - часть storage.Storage, в которой хранится outbox
+type Store interface {
+	PublishOutbox(ctx context.Context, limit int, handle storage.OutboxHandler) (int, error)
+	PurgeOutbox(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+// Publisher доставляет событие дальше: в очередь webhooks, брокер и т.п. Событие может прийти
+// повторно, если сервис упал после публикации, поэтому получатели отбрасывают дубли по EventId.
+type Publisher interface {
+	Publish(ctx context.Context, event storage.Event) error
+}
+
+type Config struct {
+	BatchSize int
+	// InitialBackoff - пауза перед повтором неудачной публикации, дальше она удваивается до MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Retention - сколько хранятся опубликованные сообщения
+	Retention time.Duration
+}
+
+var eventsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "outbox_events_total",
+		Help: "Outbox publish attempts by event type and result: published, failed",
+	},
+	[]string{"event", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(eventsTotal)
+}
+
+// Dispatcher переносит события из outbox в Publisher
+type Dispatcher struct {
+	store     Store
+	publisher Publisher
+	cfg       Config
+	logger    *zap.Logger
+}
+
+func NewDispatcher(store Store, publisher Publisher, cfg Config, logger *zap.Logger) *Dispatcher {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	return &Dispatcher{store: store, publisher: publisher, cfg: cfg, logger: logger}
+}
+
+// Run публикует события, пока не отменён ctx. Если проход что-то опубликовал или пытался опубликовать,
+// следующий начинается сразу: за ним могут ждать ещё сообщения. Проход, в котором все выбранные
+// сообщения ждут других экземпляров, ничего не публикует, и следующий ждёт interval.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastPurge := time.Now()
+	for {
+		n, err := d.store.PublishOutbox(ctx, d.cfg.BatchSize, d.publish)
+		if err != nil && ctx.Err() == nil {
+			d.logger.Warn("failed to publish outbox", zap.Error(err))
+		}
+		if time.Since(lastPurge) >= purgeInterval {
+			lastPurge = time.Now()
+			d.purge(ctx)
+		}
+		if n > 0 && ctx.Err() == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) publish(ctx context.Context, msg storage.OutboxMessage) (time.Duration, error) {
+	err := d.publisher.Publish(ctx, msg.Event)
+	if err == nil {
+		eventsTotal.WithLabelValues(msg.Event.Type, "published").Inc()
+		return 0, nil
+	}
+	if ctx.Err() != nil {
+		return 0, err
+	}
+	retryIn := d.backoff(msg.Attempts + 1)
+	eventsTotal.WithLabelValues(msg.Event.Type, "failed").Inc()
+	d.logger.Warn("failed to publish event, will retry", zap.String("event_id", msg.Event.EventId),
+		zap.String("event", msg.Event.Type), zap.String("pvz_id", msg.PvzId), zap.Int("attempt", msg.Attempts+1),
+		zap.Duration("retry_in", retryIn), zap.Error(err))
+	return retryIn, err
+}
+
+func (d *Dispatcher) purge(ctx context.Context) {
+	n, err := d.store.PurgeOutbox(ctx, d.cfg.Retention)
+	if err != nil && ctx.Err() == nil {
+		d.logger.Warn("failed to purge outbox", zap.Error(err))
+		return
+	}
+	if n > 0 {
+		d.logger.Debug("purged published outbox messages", zap.Int64("count", n))
+	}
+}
+
+// backoff - пауза перед повтором после attempt неудачных попыток: InitialBackoff * 2^(attempt-1),
+// не больше MaxBackoff, плюс до 10% случайного разброса.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.cfg.MaxBackoff
+	if shift := attempt - 1; shift < 32 {
+		if next := d.cfg.InitialBackoff << shift; next > 0 && next < delay {
+			delay = next
+		}
+	}
+	return delay + rand.N(delay/10+1)
+}
+
+// Publishers публикует событие во все получатели по очереди. Если один из них не принял событие,
+// оно будет опубликовано повторно во все, поэтому получатели отбрасывают дубли по EventId.
+type Publishers []Publisher
+
+func (p Publishers) Publish(ctx context.Context, event storage.Event) error {
+	for _, publisher := range p {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
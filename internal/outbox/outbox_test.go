@@ -0,0 +1,186 @@
+package outbox
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryStore выдаёт сообщения по порядку и, как PgStorage, останавливает ПВЗ на первой ошибке
+type memoryStore struct {
+	pending   []storage.OutboxMessage
+	published []storage.OutboxMessage
+	retryIn   []time.Duration
+}
+
+func (m *memoryStore) PublishOutbox(ctx context.Context, limit int, handle storage.OutboxHandler) (int, error) {
+	batch := m.pending
+	if len(batch) > limit {
+		batch = batch[:limit]
+	}
+	var rest []storage.OutboxMessage
+	var attempted int
+	stopped := make(map[string]bool)
+	for _, msg := range batch {
+		if stopped[msg.PvzId] {
+			rest = append(rest, msg)
+			continue
+		}
+		attempted++
+		retryIn, err := handle(ctx, msg)
+		if err != nil {
+			stopped[msg.PvzId] = true
+			msg.Attempts++
+			m.retryIn = append(m.retryIn, retryIn)
+			rest = append(rest, msg)
+			continue
+		}
+		m.published = append(m.published, msg)
+	}
+	m.pending = append(rest, m.pending[len(batch):]...)
+	return attempted, nil
+}
+
+func (m *memoryStore) PurgeOutbox(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
+// flakyPublisher не принимает события типов из down
+type flakyPublisher struct {
+	down   map[string]bool
+	events []storage.Event
+}
+
+func (p *flakyPublisher) Publish(_ context.Context, event storage.Event) error {
+	if p.down[event.Type] {
+		return errors.New("broker is unavailable")
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+func message(id int64, pvzId, eventType string) storage.OutboxMessage {
+	return storage.OutboxMessage{Id: id, PvzId: pvzId, Event: storage.Event{EventId: pvzId + eventType, Type: eventType}}
+}
+
+func TestDispatcherKeepsPvzOrder(t *testing.T) {
+	store := &memoryStore{pending: []storage.OutboxMessage{
+		message(1, "a", storage.EventReceptionOpened),
+		message(2, "b", storage.EventPvzCreated),
+		message(3, "a", storage.EventProductAdded),
+		message(4, "b", storage.EventReceptionOpened),
+	}}
+	publisher := &flakyPublisher{down: map[string]bool{storage.EventReceptionOpened: true}}
+	d := NewDispatcher(store, publisher, Config{InitialBackoff: time.Second, MaxBackoff: 4 * time.Second}, zap.NewNop())
+
+	for i := 0; i < 3; i++ {
+		if _, err := store.PublishOutbox(context.Background(), d.cfg.BatchSize, d.publish); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// обе ПВЗ застряли на reception.opened, product.added из "a" не должен обогнать его
+	if len(publisher.events) != 1 || publisher.events[0].EventId != "b"+storage.EventPvzCreated {
+		t.Fatalf("published = %+v", publisher.events)
+	}
+	if len(store.pending) != 3 {
+		t.Fatalf("pending = %+v", store.pending)
+	}
+
+	// пауза растёт с каждой неудачей и упирается в MaxBackoff
+	want := []time.Duration{time.Second, time.Second, 2 * time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}
+	if len(store.retryIn) != len(want) {
+		t.Fatalf("retries = %v", store.retryIn)
+	}
+	for i, got := range store.retryIn {
+		if got < want[i] || got > want[i]+want[i]/10 {
+			t.Errorf("retry %d in %s, want %s plus jitter", i+1, got, want[i])
+		}
+	}
+
+	publisher.down = nil
+	if _, err := store.PublishOutbox(context.Background(), d.cfg.BatchSize, d.publish); err != nil {
+		t.Fatal(err)
+	}
+	var got []int64
+	for _, msg := range store.published {
+		got = append(got, msg.Id)
+	}
+	if len(got) != 4 || got[1] != 1 || got[2] != 3 {
+		t.Errorf("publish order = %v, want 2 then 1, 3 in order", got)
+	}
+}
+
+func TestDispatcherStopsOnCancel(t *testing.T) {
+	store := &memoryStore{}
+	d := NewDispatcher(store, &flakyPublisher{}, Config{InitialBackoff: time.Second, MaxBackoff: time.Minute}, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx, time.Millisecond)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+// busyStore на каждый проход выбирает полную пачку и передаёт handle attempted сообщений
+type busyStore struct {
+	attempted int
+	passes    atomic.Int32
+}
+
+func (b *busyStore) PublishOutbox(ctx context.Context, limit int, handle storage.OutboxHandler) (int, error) {
+	b.passes.Add(1)
+	return min(b.attempted, limit), nil
+}
+
+func (b *busyStore) PurgeOutbox(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func TestDispatcherWaitsOnBlockedBatch(t *testing.T) {
+	// все выбранные сообщения ждут другой экземпляр: проход ничего не публикует
+	store := &busyStore{}
+	d := NewDispatcher(store, &flakyPublisher{}, Config{BatchSize: 10}, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx, time.Hour)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+	if n := store.passes.Load(); n != 1 {
+		t.Errorf("passes = %d, want 1 until the next tick", n)
+	}
+}
+
+func TestDispatcherStopsOnCancelWithFullBatch(t *testing.T) {
+	// PublishOutbox дописывает транзакцию и после отмены, пачки остаются полными
+	store := &busyStore{attempted: 10}
+	d := NewDispatcher(store, &flakyPublisher{}, Config{BatchSize: 10}, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx, time.Hour)
+		close(done)
+	}()
+	for store.passes.Load() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run kept polling full batches after cancel")
+	}
+}
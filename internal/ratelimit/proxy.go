@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies - адреса прокси перед сервисом. Только им можно верить в X-Forwarded-For:
+// остальные клиенты подставили бы в заголовок чужой адрес и обошли лимит по IP.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies принимает подсети в нотации CIDR и отдельные адреса
+func ParseTrustedProxies(list []string) (TrustedProxies, error) {
+	res := make(TrustedProxies, 0, len(list))
+	for _, s := range list {
+		if prefix, err := netip.ParsePrefix(s); err == nil {
+			res = append(res, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q is neither an address nor a CIDR", s)
+		}
+		res = append(res, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return res, nil
+}
+
+func (t TrustedProxies) trusted(addr netip.Addr) bool {
+	for _, prefix := range t {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Trusted сообщает, пришло ли соединение с адреса remote от доверенного прокси
+func (t TrustedProxies) Trusted(remote string) bool {
+	addr, err := netip.ParseAddr(remote)
+	return err == nil && t.trusted(addr.Unmap())
+}
+
+// ClientIP возвращает адрес клиента. remote - адрес соединения, forwarded - значения X-Forwarded-For.
+// Цепочка читается справа, пока очередной адрес принадлежит доверенному прокси: первый недоверенный
+// адрес и есть клиент. Левее него заголовок мог записать сам клиент, поэтому дальше он не читается.
+func (t TrustedProxies) ClientIP(remote string, forwarded []string) string {
+	addr, err := netip.ParseAddr(remote)
+	if err != nil {
+		return remote
+	}
+	addr = addr.Unmap()
+	if !t.trusted(addr) {
+		return addr.String()
+	}
+	var hops []string
+	for _, v := range forwarded {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0 && t.trusted(addr); i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+	}
+	return addr.String()
+}
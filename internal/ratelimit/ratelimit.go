@@ -0,0 +1,249 @@
+package ratelimit
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"math"
+	"sync"
+	"time"
+)
+
+// Группы маршрутов, у каждой свои лимиты
+const (
+	// GroupAuth - вход и регистрация
+	GroupAuth = "auth"
+	// GroupList - тяжёлые выборки: список ПВЗ, выгрузка, аналитика
+	GroupList  = "list"
+	GroupRead  = "read"
+	GroupWrite = "write"
+)
+
+// Ключи, по которым считаются запросы
+const (
+	ScopeUser   = "user"
+	ScopeClient = "client"
+	ScopeIP     = "ip"
+)
+
+// maxClientIDLength - длиннее идентификатор клиента не учитывается
+const maxClientIDLength = 64
+
+// minIdle - меньше этого ведро не удаляется, даже если успело наполниться
+const minIdle = time.Minute
+
+// Limit - ведро токенов: в среднем Rate запросов в секунду, подряд не больше Burst. Rate 0 - без ограничения.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Group - лимиты группы маршрутов по каждому ключу запроса
+type Group struct {
+	User   Limit
+	Client Limit
+	IP     Limit
+}
+
+// Keys - ключи запроса, пустые не проверяются
+type Keys struct {
+	User   string
+	Client string
+	IP     string
+}
+
+// ClientID проверяет идентификатор клиента из запроса: непечатные и слишком длинные значения не учитываются
+func ClientID(id string) string {
+	if len(id) > maxClientIDLength {
+		return ""
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return ""
+		}
+	}
+	return id
+}
+
+// Store хранит вёдра: storage.Storage, чтобы лимиты были общими для всех экземпляров, или MemoryStore
+type Store interface {
+	TakeRateLimit(ctx context.Context, key string, rate float64, burst int) (storage.RateLimitResult, error)
+	PurgeRateLimits(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+// Decision - результат проверки по самому строгому из ключей запроса
+type Decision struct {
+	Allowed   bool
+	Scope     string
+	Limit     int
+	Remaining int
+	// Reset - через сколько ведро наполнится
+	Reset time.Duration
+	// RetryAfter - через сколько появится токен, если запрос отклонён
+	RetryAfter time.Duration
+}
+
+// Stricter сравнивает решения по разным ключам: отказ строже пропуска, из отказов строже тот,
+// после которого дольше ждать, из пропусков - с меньшим остатком
+func (d Decision) Stricter(other Decision) bool {
+	if d.Allowed != other.Allowed {
+		return !d.Allowed
+	}
+	if !d.Allowed {
+		return d.RetryAfter > other.RetryAfter
+	}
+	return d.Remaining < other.Remaining
+}
+
+var requestsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "rate_limit_requests_total",
+		Help: "Rate limit checks by route group, key and result: allowed, limited, error",
+	},
+	[]string{"group", "scope", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(requestsTotal)
+}
+
+type Limiter struct {
+	store  Store
+	groups map[string]Group
+	// idle - за это время любое ведро наполняется, после него ведро можно удалить
+	idle   time.Duration
+	logger *zap.Logger
+}
+
+func New(store Store, groups map[string]Group, logger *zap.Logger) *Limiter {
+	idle := minIdle
+	for _, g := range groups {
+		for _, limit := range []Limit{g.User, g.Client, g.IP} {
+			if limit.Rate > 0 {
+				idle = max(idle, time.Duration(float64(limit.Burst)/limit.Rate*float64(time.Second)))
+			}
+		}
+	}
+	return &Limiter{store: store, groups: groups, idle: idle, logger: logger}
+}
+
+// Allow берёт токен из ведра каждого ключа запроса. limited = false - у группы нет лимитов для этих ключей.
+// Если хранилище вёдер недоступно, запрос пропускается: лимиты защищают базу, и отказы из-за её
+// сбоя только добавили бы повторных запросов.
+func (l *Limiter) Allow(ctx context.Context, group string, keys Keys) (d Decision, limited bool) {
+	g, ok := l.groups[group]
+	if !ok {
+		return Decision{Allowed: true}, false
+	}
+	checks := []struct {
+		scope, key string
+		limit      Limit
+	}{
+		{ScopeUser, keys.User, g.User},
+		{ScopeClient, keys.Client, g.Client},
+		{ScopeIP, keys.IP, g.IP},
+	}
+	d = Decision{Allowed: true}
+	for _, c := range checks {
+		if c.key == "" || c.limit.Rate <= 0 {
+			continue
+		}
+		res, err := l.store.TakeRateLimit(ctx, group+":"+c.scope+":"+c.key, c.limit.Rate, c.limit.Burst)
+		if err != nil {
+			requestsTotal.WithLabelValues(group, c.scope, "error").Inc()
+			if ctx.Err() == nil {
+				l.logger.Warn("rate limit check failed, request allowed", zap.String("group", group),
+					zap.String("scope", c.scope), zap.Error(err))
+			}
+			continue
+		}
+		next := decision(c.scope, c.limit, res)
+		result := "allowed"
+		if !next.Allowed {
+			result = "limited"
+		}
+		requestsTotal.WithLabelValues(group, c.scope, result).Inc()
+		if !limited || next.Stricter(d) {
+			d = next
+		}
+		limited = true
+	}
+	return d, limited
+}
+
+func decision(scope string, limit Limit, res storage.RateLimitResult) Decision {
+	d := Decision{Allowed: res.Allowed, Scope: scope, Limit: limit.Burst, Remaining: int(math.Floor(res.Tokens)),
+		Reset: seconds((float64(limit.Burst) - res.Tokens) / limit.Rate)}
+	if !res.Allowed {
+		d.RetryAfter = seconds((1 - res.Tokens) / limit.Rate)
+	}
+	return d
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(max(s, 0) * float64(time.Second))
+}
+
+// Run удаляет наполнившиеся вёдра раз в interval, пока не отменён ctx
+func (l *Limiter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := l.store.PurgeRateLimits(ctx, l.idle); err != nil && ctx.Err() == nil {
+			l.logger.Warn("failed to purge rate limit buckets", zap.Error(err))
+		}
+	}
+}
+
+// MemoryStore хранит вёдра в памяти экземпляра: при нескольких экземплярах каждый считает свои лимиты
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+func (m *MemoryStore) TakeRateLimit(ctx context.Context, key string, rate float64, burst int) (storage.RateLimitResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = min(float64(burst), b.tokens+max(now.Sub(b.updated).Seconds(), 0)*rate)
+	b.updated = now
+	if b.tokens < 1 {
+		return storage.RateLimitResult{Tokens: b.tokens}, nil
+	}
+	b.tokens--
+	return storage.RateLimitResult{Allowed: true, Tokens: b.tokens}, nil
+}
+
+func (m *MemoryStore) PurgeRateLimits(ctx context.Context, olderThan time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for key, b := range m.buckets {
+		if m.now().Sub(b.updated) > olderThan {
+			delete(m.buckets, key)
+			n++
+		}
+	}
+	return n, nil
+}
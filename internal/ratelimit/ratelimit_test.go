@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func clockStore() (*MemoryStore, *time.Time) {
+	now := time.Unix(0, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	return store, &now
+}
+
+func TestMemoryStoreRefill(t *testing.T) {
+	store, now := clockStore()
+	l := New(store, map[string]Group{GroupList: {IP: Limit{Rate: 2, Burst: 3}}}, zap.NewNop())
+	ctx := context.Background()
+	keys := Keys{IP: "10.0.0.1"}
+
+	for i := 2; i >= 0; i-- {
+		d, limited := l.Allow(ctx, GroupList, keys)
+		if !limited || !d.Allowed || d.Remaining != i || d.Limit != 3 {
+			t.Fatalf("request %d: %+v, limited %v", 3-i, d, limited)
+		}
+	}
+	d, _ := l.Allow(ctx, GroupList, keys)
+	if d.Allowed || d.Scope != ScopeIP || d.RetryAfter != 500*time.Millisecond || d.Reset != 1500*time.Millisecond {
+		t.Fatalf("fourth request = %+v, want limited for 500ms", d)
+	}
+
+	*now = now.Add(500 * time.Millisecond)
+	if d, _ := l.Allow(ctx, GroupList, keys); !d.Allowed || d.Remaining != 0 {
+		t.Errorf("after refill = %+v", d)
+	}
+	// у другого IP своё ведро
+	if d, _ := l.Allow(ctx, GroupList, Keys{IP: "10.0.0.2"}); !d.Allowed || d.Remaining != 2 {
+		t.Errorf("other ip = %+v", d)
+	}
+
+	*now = now.Add(time.Hour)
+	if n, _ := store.PurgeRateLimits(ctx, l.idle); n != 2 {
+		t.Errorf("purged %d buckets, want 2", n)
+	}
+}
+
+func TestAllowUsesStrictestKey(t *testing.T) {
+	store, _ := clockStore()
+	l := New(store, map[string]Group{GroupRead: {
+		User:   Limit{Rate: 1, Burst: 1},
+		Client: Limit{Rate: 1, Burst: 5},
+		IP:     Limit{Rate: 1, Burst: 10},
+	}}, zap.NewNop())
+	ctx := context.Background()
+
+	d, _ := l.Allow(ctx, GroupRead, Keys{User: "u1", Client: "crm", IP: "10.0.0.1"})
+	if !d.Allowed || d.Scope != ScopeUser || d.Remaining != 0 {
+		t.Errorf("first = %+v, want the user bucket with nothing left", d)
+	}
+	if d, _ := l.Allow(ctx, GroupRead, Keys{User: "u1", Client: "crm", IP: "10.0.0.1"}); d.Allowed || d.Scope != ScopeUser {
+		t.Errorf("second = %+v, want limited by user", d)
+	}
+	// без пользователя проверяются только клиент и IP
+	if d, _ := l.Allow(ctx, GroupRead, Keys{Client: "crm", IP: "10.0.0.1"}); !d.Allowed || d.Scope != ScopeClient || d.Remaining != 2 {
+		t.Errorf("anonymous = %+v", d)
+	}
+	if _, limited := l.Allow(ctx, GroupWrite, Keys{IP: "10.0.0.1"}); limited {
+		t.Error("group without limits must not be limited")
+	}
+}
+
+type failingStore struct{ *MemoryStore }
+
+func (failingStore) TakeRateLimit(context.Context, string, float64, int) (storage.RateLimitResult, error) {
+	return storage.RateLimitResult{}, errors.New("connection refused")
+}
+
+func TestAllowFailsOpen(t *testing.T) {
+	l := New(failingStore{NewMemoryStore()}, map[string]Group{GroupList: {IP: Limit{Rate: 1, Burst: 1}}}, zap.NewNop())
+	d, limited := l.Allow(context.Background(), GroupList, Keys{IP: "10.0.0.1"})
+	if !d.Allowed || limited {
+		t.Errorf("decision = %+v, limited %v, want the request allowed without limit headers", d, limited)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		{"direct", "203.0.113.5", nil, "203.0.113.5"},
+		{"untrusted peer cannot forward", "203.0.113.5", []string{"198.51.100.1"}, "203.0.113.5"},
+		{"one proxy", "10.0.0.1", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed left hop ignored", "10.0.0.1", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"proxy chain", "10.0.0.1", []string{"198.51.100.1", "192.168.1.1, 10.1.1.1"}, "198.51.100.1"},
+		{"only proxies", "10.0.0.1", []string{"10.0.0.2"}, "10.0.0.2"},
+		{"garbage hop", "10.0.0.1", []string{"198.51.100.1, unknown"}, "10.0.0.1"},
+		{"ipv4-mapped", "::ffff:10.0.0.1", []string{"198.51.100.1"}, "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := proxies.ClientIP(tt.remote, tt.forwarded); got != tt.want {
+				t.Errorf("ClientIP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+	if _, err := ParseTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("invalid CIDR accepted")
+	}
+}
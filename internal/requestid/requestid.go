@@ -0,0 +1,65 @@
+package requestid
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	Header      = "X-Request-ID"
+	MetadataKey = "x-request-id"
+
+	maxLength = 128
+)
+
+type ctxKey int
+
+const (
+	idKey ctxKey = iota
+	loggerKey
+)
+
+// FromOrNew возвращает присланный клиентом идентификатор, если он выглядит безопасно
+// для логов и заголовков, иначе генерирует новый UUID.
+func FromOrNew(id string) string {
+	if valid(id) {
+		return id
+	}
+	return uuid.NewString()
+}
+
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// With кладёт идентификатор и логгер с полем request_id в контекст запроса
+func With(ctx context.Context, id string, logger *zap.Logger) (context.Context, *zap.Logger) {
+	logger = logger.With(zap.String("request_id", id))
+	ctx = context.WithValue(ctx, idKey, id)
+	return context.WithValue(ctx, loggerKey, logger), logger
+}
+
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(idKey).(string)
+	return id
+}
+
+// Logger возвращает логгер запроса или fallback, если контекст создан вне запроса
+func Logger(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*zap.Logger); ok {
+		return logger
+	}
+	return fallback
+}
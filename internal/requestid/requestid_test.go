@@ -0,0 +1,41 @@
+package requestid
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"strings"
+	"testing"
+)
+
+func TestFromOrNew(t *testing.T) {
+	if got := FromOrNew("abc-123_x.y:z"); got != "abc-123_x.y:z" {
+		t.Errorf("valid id replaced with %s", got)
+	}
+	for _, bad := range []string{"", "with space", "line\nbreak", strings.Repeat("a", maxLength+1)} {
+		got := FromOrNew(bad)
+		if got == bad || len(got) != 36 {
+			t.Errorf("FromOrNew(%q) = %q, want generated UUID", bad, got)
+		}
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	fallback := zap.New(core)
+
+	if got := Logger(context.Background(), fallback); got != fallback {
+		t.Error("Logger without request context must return fallback")
+	}
+
+	ctx, _ := With(context.Background(), "req-1", fallback)
+	if got := FromContext(ctx); got != "req-1" {
+		t.Errorf("FromContext = %s, want req-1", got)
+	}
+	Logger(ctx, zap.NewNop()).Info("hello")
+
+	entries := logs.All()
+	if len(entries) != 1 || entries[0].ContextMap()["request_id"] != "req-1" {
+		t.Errorf("log entries = %+v, want one with request_id=req-1", entries)
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var pvzCreatedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "pvz_created_total",
+		Help: "Total number of created pickup points (PVZ)",
+	},
+	[]string{"city"},
+)
+
+var receptionsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "receptions_created_total",
+		Help: "Total number of created order acceptances",
+	},
+	[]string{"city"},
+)
+
+var productAddedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "product_added_total",
+		Help: "Total number of added products",
+	},
+	[]string{"city", "product_type"},
+)
+
+var receptionDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "reception_duration_seconds",
+		Help:    "Time between opening and closing a reception",
+		Buckets: []float64{60, 300, 900, 1800, 3600, 2 * 3600, 4 * 3600, 8 * 3600, 24 * 3600},
+	},
+	[]string{"city"},
+)
+
+var openReceptions = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "receptions_open",
+		Help: "Number of currently open receptions",
+	},
+	[]string{"city"},
+)
+
+var pvzTotal = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "pvz_total",
+		Help: "Total number of pickup points (PVZ)",
+	},
+	[]string{"city"},
+)
+
+func init() {
+	prometheus.MustRegister(pvzCreatedTotal)
+	prometheus.MustRegister(receptionsTotal)
+	prometheus.MustRegister(productAddedTotal)
+	prometheus.MustRegister(receptionDuration)
+	prometheus.MustRegister(openReceptions)
+	prometheus.MustRegister(pvzTotal)
+}
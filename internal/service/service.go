@@ -0,0 +1,120 @@
+package service
+
+import (
+	"avito_intr/internal/requestid"
+	"avito_intr/internal/storage"
+	"context"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const unknownCity = "unknown"
+
+// Service - общий для HTTP и gRPC слой поверх хранилища. Он реализует storage.Storage,
+// поэтому транспорты работают с ним как с обычным хранилищем, а доменные метрики
+// пишутся независимо от того, через какой API пришёл запрос.
+type Service struct {
+	storage.Storage
+	logger *zap.Logger
+
+	// город ПВЗ не меняется, поэтому его можно не запрашивать на каждую приёмку и товар
+	cities sync.Map
+}
+
+func New(store storage.Storage, logger *zap.Logger) *Service {
+	return &Service{Storage: store, logger: logger}
+}
+
+func (s *Service) cityOf(ctx context.Context, pvzId string) string {
+	if city, ok := s.cities.Load(pvzId); ok {
+		return city.(string)
+	}
+	pvz, err := s.Storage.GetPvzById(ctx, pvzId)
+	if err != nil {
+		requestid.Logger(ctx, s.logger).Warn("failed to resolve pvz city for metrics", zap.String("pvz_id", pvzId), zap.Error(err))
+		return unknownCity
+	}
+	s.cities.Store(pvzId, string(pvz.City))
+	return string(pvz.City)
+}
+
+func (s *Service) CreatePvz(ctx context.Context, author string, params storage.PvzInfo) (*storage.PvzInfo, error) {
+	pvz, err := s.Storage.CreatePvz(ctx, author, params)
+	if err != nil {
+		return nil, err
+	}
+	s.cities.Store(*pvz.PvzId, string(pvz.City))
+	pvzCreatedTotal.WithLabelValues(string(pvz.City)).Inc()
+	pvzTotal.WithLabelValues(string(pvz.City)).Inc()
+	return pvz, nil
+}
+
+func (s *Service) OpenReception(ctx context.Context, author string, pvz string, expectedVersion int64) (*storage.ReceptionInfo, error) {
+	reception, err := s.Storage.OpenReception(ctx, author, pvz, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	city := s.cityOf(ctx, reception.PvzId)
+	receptionsTotal.WithLabelValues(city).Inc()
+	openReceptions.WithLabelValues(city).Inc()
+	return reception, nil
+}
+
+func (s *Service) AddProduct(ctx context.Context, uuid, author, product string, expectedVersion int64) (*storage.Product, error) {
+	res, err := s.Storage.AddProduct(ctx, uuid, author, product, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	productAddedTotal.WithLabelValues(s.cityOf(ctx, uuid), res.ProductType).Inc()
+	return res, nil
+}
+
+func (s *Service) CloseLastReception(ctx context.Context, pvzId string, expectedVersion int64) (*storage.ReceptionInfo, error) {
+	reception, err := s.Storage.CloseLastReception(ctx, pvzId, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	city := s.cityOf(ctx, reception.PvzId)
+	openReceptions.WithLabelValues(city).Dec()
+	if reception.ClosedAt != nil {
+		receptionDuration.WithLabelValues(city).Observe(reception.ClosedAt.Sub(reception.DateTime).Seconds())
+	}
+	return reception, nil
+}
+
+// RefreshGauges выставляет гейджи по данным из базы. Между обновлениями их
+// поддерживают сами операции сервиса, а обновление исправляет расхождения
+// из-за других экземпляров и изменений в обход сервиса.
+func (s *Service) RefreshGauges(ctx context.Context) error {
+	stats, err := s.Storage.GetPvzStats(ctx)
+	if err != nil {
+		return err
+	}
+	pvz := make(map[storage.City]int)
+	open := make(map[storage.City]int)
+	for _, st := range stats {
+		pvz[st.City] = st.Pvz
+		open[st.City] = st.OpenReceptions
+	}
+	for _, city := range storage.Cities {
+		pvzTotal.WithLabelValues(string(city)).Set(float64(pvz[city]))
+		openReceptions.WithLabelValues(string(city)).Set(float64(open[city]))
+	}
+	return nil
+}
+
+func (s *Service) RunGaugeRefresher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.RefreshGauges(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Warn("failed to refresh gauges", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
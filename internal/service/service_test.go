@@ -0,0 +1,93 @@
+package service
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	storage.Storage
+	lookups int
+}
+
+func (f *fakeStore) CreatePvz(_ context.Context, author string, params storage.PvzInfo) (*storage.PvzInfo, error) {
+	id := "11111111-1111-1111-1111-111111111111"
+	now := time.Now()
+	return &storage.PvzInfo{PvzId: &id, RegistrationDate: &now, City: params.City}, nil
+}
+
+func (f *fakeStore) GetPvzById(_ context.Context, pvzId string) (*storage.PvzInfo, error) {
+	f.lookups++
+	return &storage.PvzInfo{PvzId: &pvzId, City: storage.Kazan}, nil
+}
+
+func (f *fakeStore) OpenReception(_ context.Context, author string, pvz string, _ int64) (*storage.ReceptionInfo, error) {
+	return &storage.ReceptionInfo{PvzId: pvz, DateTime: time.Now().Add(-time.Hour), Status: storage.Active}, nil
+}
+
+func (f *fakeStore) AddProduct(_ context.Context, uuid, author, product string, _ int64) (*storage.Product, error) {
+	return &storage.Product{ProductType: product}, nil
+}
+
+func (f *fakeStore) CloseLastReception(_ context.Context, pvzId string, _ int64) (*storage.ReceptionInfo, error) {
+	closed := time.Now()
+	return &storage.ReceptionInfo{PvzId: pvzId, DateTime: closed.Add(-time.Hour), ClosedAt: &closed, Status: storage.Inactive}, nil
+}
+
+func (f *fakeStore) GetPvzStats(context.Context) ([]storage.CityStats, error) {
+	return []storage.CityStats{{City: storage.Moscow, Pvz: 5, OpenReceptions: 2}}, nil
+}
+
+func TestServiceMetrics(t *testing.T) {
+	store := &fakeStore{}
+	s := New(store, zap.NewNop())
+
+	pvz, err := s.CreatePvz(context.Background(), "", storage.PvzInfo{City: storage.SPB})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(pvzCreatedTotal.WithLabelValues(string(storage.SPB))); got != 1 {
+		t.Errorf("pvz_created_total{city=SPB} = %v, want 1", got)
+	}
+
+	if _, err := s.OpenReception(context.Background(), "", *pvz.PvzId, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddProduct(context.Background(), *pvz.PvzId, "", storage.Shoes, 0); err != nil {
+		t.Fatal(err)
+	}
+	if store.lookups != 0 {
+		t.Errorf("city of a created pvz was looked up %d times", store.lookups)
+	}
+	if got := testutil.ToFloat64(productAddedTotal.WithLabelValues(string(storage.SPB), storage.Shoes)); got != 1 {
+		t.Errorf("product_added_total = %v, want 1", got)
+	}
+
+	other := "22222222-2222-2222-2222-222222222222"
+	if _, err := s.CloseLastReception(context.Background(), other, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddProduct(context.Background(), other, "", storage.Shoes, 0); err != nil {
+		t.Fatal(err)
+	}
+	if store.lookups != 1 {
+		t.Errorf("city lookups = %d, want 1", store.lookups)
+	}
+	if got := testutil.CollectAndCount(receptionDuration); got != 1 {
+		t.Errorf("reception_duration_seconds series = %d, want 1", got)
+	}
+
+	if err := s.RefreshGauges(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(pvzTotal.WithLabelValues(string(storage.Moscow))); got != 5 {
+		t.Errorf("pvz_total{city=Moscow} = %v, want 5", got)
+	}
+	if got := testutil.ToFloat64(openReceptions.WithLabelValues(string(storage.SPB))); got != 0 {
+		t.Errorf("receptions_open{city=SPB} = %v, want 0 after refresh", got)
+	}
+}
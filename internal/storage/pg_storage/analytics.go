@@ -0,0 +1,166 @@
+package pg_storage
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"time"
+)
+
+func (s *PgStorage) GetAnalytics(ctx context.Context, filter storage.AnalyticsFilter) (*storage.Analytics, error) {
+	start, err := parseDateBound(filter.StartDate)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseDateBound(filter.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	if filter.City != "" && !filter.City.Valid() {
+		return nil, storage.ReceptionFailed{Message: "invalid city " + string(filter.City)}
+	}
+	var city *string
+	if filter.City != "" {
+		c := string(filter.City)
+		city = &c
+	}
+
+	res := &storage.Analytics{
+		ProductsPerPvz:        make([]storage.DailyProducts, 0),
+		ProductsPerCity:       make([]storage.DailyProducts, 0),
+		ReceptionsPerEmployee: make([]storage.EmployeeReceptions, 0),
+	}
+	if err := s.productsPerDay(ctx, res, start, end, city); err != nil {
+		return nil, err
+	}
+	if err := s.receptionDurations(ctx, res, start, end, city); err != nil {
+		return nil, err
+	}
+	if err := s.receptionsPerEmployee(ctx, res, start, end, city); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// productsPerDay считает товары по дням сразу в двух разрезах: по ПВЗ и по городу.
+func (s *PgStorage) productsPerDay(ctx context.Context, res *storage.Analytics, start, end *time.Time, city *string) error {
+	q, err := s.pool.Query(ctx, `
+SELECT
+    date_trunc('day', products.registration_date) AS day,
+    pvz.id,
+    pvz.city,
+    products.product_type,
+    COUNT(*),
+    GROUPING(pvz.id) = 1 AS city_total
+FROM
+    products
+JOIN
+    receptions
+    ON products.reception_id = receptions.id
+JOIN
+    pvz
+    ON receptions.pvz_id = pvz.id
+WHERE
+    ($1::timestamp IS NULL OR products.registration_date >= $1)
+    AND ($2::timestamp IS NULL OR products.registration_date <= $2)
+    AND ($3::cities IS NULL OR pvz.city = $3)
+GROUP BY GROUPING SETS (
+    (date_trunc('day', products.registration_date), pvz.id, pvz.city, products.product_type),
+    (date_trunc('day', products.registration_date), pvz.city, products.product_type)
+)
+ORDER BY day, pvz.city, pvz.id NULLS FIRST, products.product_type;
+`, start, end, city)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	for q.Next() {
+		var (
+			row       storage.DailyProducts
+			pvzId     *[16]byte
+			cityName  string
+			cityTotal bool
+		)
+		if err := q.Scan(&row.Day, &pvzId, &cityName, &row.ProductType, &row.Count, &cityTotal); err != nil {
+			return err
+		}
+		row.City = storage.City(cityName)
+		if cityTotal {
+			res.ProductsPerCity = append(res.ProductsPerCity, row)
+			continue
+		}
+		row.PvzId = parseStringFromUUID(*pvzId)
+		res.ProductsPerPvz = append(res.ProductsPerPvz, row)
+	}
+	return q.Err()
+}
+
+func (s *PgStorage) receptionDurations(ctx context.Context, res *storage.Analytics, start, end *time.Time, city *string) error {
+	var avg, p95 *float64
+	err := s.pool.QueryRow(ctx, `
+SELECT
+    COUNT(*),
+    AVG(EXTRACT(EPOCH FROM receptions.closed_at - receptions.registration_date))::float8,
+    PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM receptions.closed_at - receptions.registration_date)::float8)
+FROM
+    receptions
+JOIN
+    pvz
+    ON receptions.pvz_id = pvz.id
+WHERE
+    receptions.closed_at IS NOT NULL
+    AND ($1::timestamp IS NULL OR receptions.registration_date >= $1)
+    AND ($2::timestamp IS NULL OR receptions.registration_date <= $2)
+    AND ($3::cities IS NULL OR pvz.city = $3);
+`, start, end, city).Scan(&res.Durations.Closed, &avg, &p95)
+	if err != nil {
+		return err
+	}
+	if avg != nil {
+		res.Durations.Average = time.Duration(*avg * float64(time.Second))
+	}
+	if p95 != nil {
+		res.Durations.P95 = time.Duration(*p95 * float64(time.Second))
+	}
+	return nil
+}
+
+func (s *PgStorage) receptionsPerEmployee(ctx context.Context, res *storage.Analytics, start, end *time.Time, city *string) error {
+	q, err := s.pool.Query(ctx, `
+SELECT
+    clients.id,
+    clients.email,
+    COUNT(*) AS receptions
+FROM
+    receptions
+JOIN
+    clients
+    ON receptions.author_id = clients.id
+JOIN
+    pvz
+    ON receptions.pvz_id = pvz.id
+WHERE
+    ($1::timestamp IS NULL OR receptions.registration_date >= $1)
+    AND ($2::timestamp IS NULL OR receptions.registration_date <= $2)
+    AND ($3::cities IS NULL OR pvz.city = $3)
+GROUP BY clients.id, clients.email
+ORDER BY receptions DESC, clients.email;
+`, start, end, city)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	for q.Next() {
+		var (
+			id  [16]byte
+			row storage.EmployeeReceptions
+		)
+		if err := q.Scan(&id, &row.Email, &row.Receptions); err != nil {
+			return err
+		}
+		row.EmployeeId = parseStringFromUUID(id)
+		res.ReceptionsPerEmployee = append(res.ReceptionsPerEmployee, row)
+	}
+	return q.Err()
+}
@@ -0,0 +1,30 @@
+package pg_storage
+
+import (
+	"avito_intr/internal/storage"
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	errNoRows          = errors.New("query returned no rows")
+	errNoOpenReception = storage.ReceptionFailed{Message: "no open reception in pvz"}
+)
+
+// domainError переводит ошибки Postgres, вызванные данными клиента, в storage.ReceptionFailed.
+// Остальные ошибки возвращаются как есть и не должны попадать в ответ клиенту.
+func domainError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case "23505": // unique_violation
+		return storage.ReceptionFailed{Message: "already exists"}
+	case "23503": // foreign_key_violation
+		return storage.ReceptionFailed{Message: "referenced object does not exist"}
+	case "22P02": // invalid_text_representation, в том числе неизвестное значение enum
+		return storage.ReceptionFailed{Message: "invalid value"}
+	}
+	return err
+}
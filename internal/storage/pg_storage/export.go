@@ -0,0 +1,136 @@
+package pg_storage
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+const exportFetchSize = 500
+
+// ExportReceptions читает приёмки с товарами через серверный курсор порциями по exportFetchSize строк
+// и передаёт их в fn по одной, поэтому выгрузка любого размера не загружается в память целиком.
+func (s *PgStorage) ExportReceptions(ctx context.Context, filter storage.ExportFilter, fn func(storage.ExportRow) error) error {
+	start, err := parseDateBound(filter.StartDate)
+	if err != nil {
+		return err
+	}
+	end, err := parseDateBound(filter.EndDate)
+	if err != nil {
+		return err
+	}
+	if filter.City != "" && !filter.City.Valid() {
+		return storage.ReceptionFailed{Message: "invalid city " + string(filter.City)}
+	}
+	var pvzId *[16]byte
+	if filter.PvzId != "" {
+		id, err := parseUUID(filter.PvzId)
+		if err != nil {
+			return storage.ReceptionFailed{Message: "uuid is not valid"}
+		}
+		pvzId = &id
+	}
+	var city *string
+	if filter.City != "" {
+		c := string(filter.City)
+		city = &c
+	}
+
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(context.Background()) }()
+
+	_, err = tx.Exec(ctx, `
+DECLARE export_cursor NO SCROLL CURSOR FOR
+SELECT
+    pvz.id,
+    pvz.city,
+    receptions.id,
+    receptions.registration_date,
+    receptions.activity,
+    products.id,
+    products.product_type,
+    products.registration_date
+FROM
+    receptions
+JOIN
+    pvz
+    ON receptions.pvz_id = pvz.id
+LEFT JOIN
+    products
+    ON products.reception_id = receptions.id
+WHERE
+    ($1::timestamp IS NULL OR receptions.registration_date >= $1)
+    AND ($2::timestamp IS NULL OR receptions.registration_date <= $2)
+    AND ($3::cities IS NULL OR pvz.city = $3)
+    AND ($4::uuid IS NULL OR pvz.id = $4)
+ORDER BY
+    receptions.registration_date,
+    receptions.id,
+    products.registration_date;
+`, start, end, city, pvzId)
+	if err != nil {
+		return err
+	}
+
+	fetch := fmt.Sprintf("FETCH %d FROM export_cursor", exportFetchSize)
+	for {
+		n, err := s.fetchExportRows(ctx, tx, fetch, fn)
+		if err != nil {
+			return err
+		}
+		if n < exportFetchSize {
+			break
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *PgStorage) fetchExportRows(ctx context.Context, tx pgx.Tx, fetch string, fn func(storage.ExportRow) error) (int, error) {
+	q, err := tx.Query(ctx, fetch)
+	if err != nil {
+		return 0, err
+	}
+	defer q.Close()
+
+	n := 0
+	for q.Next() {
+		var (
+			pvzId, recId [16]byte
+			city         string
+			recDate      time.Time
+			activity     bool
+			productId    *[16]byte
+			productType  *string
+			productDate  *time.Time
+		)
+		if err := q.Scan(&pvzId, &city, &recId, &recDate, &activity, &productId, &productType, &productDate); err != nil {
+			return n, err
+		}
+		n++
+
+		row := storage.ExportRow{
+			PvzId:           parseStringFromUUID(pvzId),
+			City:            storage.City(city),
+			ReceptionId:     parseStringFromUUID(recId),
+			ReceptionDate:   recDate,
+			ReceptionStatus: storage.Inactive,
+			ProductDate:     productDate,
+		}
+		if activity {
+			row.ReceptionStatus = storage.Active
+		}
+		if productId != nil {
+			row.ProductId = parseStringFromUUID(*productId)
+			row.ProductType = *productType
+		}
+		if err := fn(row); err != nil {
+			return n, err
+		}
+	}
+	return n, q.Err()
+}
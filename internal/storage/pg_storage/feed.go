@@ -0,0 +1,105 @@
+package pg_storage
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+const feedChannel = "event_feed"
+
+func (s *PgStorage) AppendFeed(ctx context.Context, event storage.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		// блокировка держится до фиксации: следующая вставка получит seq только после того,
+		// как эта станет видна читателям
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('event_feed'));"); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, `
+INSERT INTO event_feed (event_id, event_type, pvz_id, city, payload)
+SELECT $1, $2, p.id, p.city::text, $4 FROM pvz p WHERE p.id = $3
+ON CONFLICT (event_id) DO NOTHING;`, event.EventId, event.Type, event.PvzId, payload)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return nil
+		}
+		_, err = tx.Exec(ctx, "SELECT pg_notify($1, '');", feedChannel)
+		return err
+	})
+}
+
+func (s *PgStorage) ReadFeed(ctx context.Context, filter storage.FeedFilter) ([]storage.FeedEvent, error) {
+	var pvzId any
+	if filter.PvzId != "" {
+		if !IsUUID(filter.PvzId) {
+			return nil, storage.ReceptionFailed{Message: "uuid is not valid"}
+		}
+		pvzId = filter.PvzId
+	}
+	q, err := s.pool.Query(ctx, `
+SELECT seq, city, payload
+FROM event_feed
+WHERE seq > $1 AND ($2 = 0 OR seq <= $2)
+  AND ($3::uuid IS NULL OR pvz_id = $3)
+  AND ($4 = '' OR city = $4)
+ORDER BY seq
+LIMIT $5;`, filter.After, filter.Until, pvzId, string(filter.City), filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(q, func(row pgx.CollectableRow) (storage.FeedEvent, error) {
+		var (
+			e       storage.FeedEvent
+			city    string
+			payload []byte
+		)
+		if err := row.Scan(&e.Seq, &city, &payload); err != nil {
+			return e, err
+		}
+		e.City = storage.City(city)
+		return e, json.Unmarshal(payload, &e.Event)
+	})
+}
+
+func (s *PgStorage) LastFeedSeq(ctx context.Context) (int64, error) {
+	var seq int64
+	err := s.pool.QueryRow(ctx, "SELECT COALESCE(MAX(seq), 0) FROM event_feed;").Scan(&seq)
+	return seq, err
+}
+
+// ListenFeed занимает отдельное соединение: оно забирается из пула и закрывается при выходе,
+// чтобы подписка LISTEN не досталась другим запросам.
+func (s *PgStorage) ListenFeed(ctx context.Context, notify func()) error {
+	pooled, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+feedChannel+";"); err != nil {
+		return err
+	}
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return err
+		}
+		notify()
+	}
+}
+
+func (s *PgStorage) PurgeFeed(ctx context.Context, olderThan time.Duration) (int64, error) {
+	tag, err := s.pool.Exec(ctx, "DELETE FROM event_feed WHERE created_at < NOW() - $1 * INTERVAL '1 second';", olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,30 @@
+package pg_storage
+
+import (
+	"context"
+)
+
+func (s *PgStorage) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
+// MigrationVersion возвращает номер последней применённой миграции
+func (s *PgStorage) MigrationVersion(ctx context.Context) (int, error) {
+	var version int
+	err := s.pool.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// LatestMigration возвращает номер последней миграции, вшитой в бинарник
+func (s *PgStorage) LatestMigration() int {
+	files, err := migrationFiles()
+	if err != nil || len(files) == 0 {
+		return 0
+	}
+	return files[len(files)-1].version
+}
+
+func (s *PgStorage) PoolStat() (acquired, max int32) {
+	stat := s.pool.Stat()
+	return stat.AcquiredConns(), stat.MaxConns()
+}
@@ -0,0 +1,92 @@
+package pg_storage
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+// idempotencyLockTimeout - через сколько незавершённая запись считается брошенной (процесс упал
+// посреди запроса), и ключ можно занять снова, не дожидаясь конца TTL.
+const idempotencyLockTimeout = time.Minute
+
+// BeginIdempotent занимает ключ за вызывающим. Истёкшая или брошенная запись перезаписывается.
+// Если ключ занят, возвращается сохранённый ответ, storage.ErrIdempotencyKeyReused для запроса
+// с другим содержимым или storage.ErrIdempotencyKeyInUse, пока первый запрос не завершён.
+// nil без ошибки означает, что запрос нужно выполнить и затем вызвать CompleteIdempotent или AbortIdempotent.
+func (s *PgStorage) BeginIdempotent(ctx context.Context, caller, key, fingerprint string, ttl time.Duration) (*storage.IdempotentResponse, error) {
+	tag, err := s.pool.Exec(ctx, `
+INSERT INTO idempotency_keys (caller_id, key, fingerprint, expires_at)
+VALUES ($1, $2, $3, NOW() + $4 * INTERVAL '1 second')
+ON CONFLICT (caller_id, key) DO UPDATE
+    SET fingerprint  = EXCLUDED.fingerprint,
+        status       = NULL,
+        content_type = NULL,
+        headers      = NULL,
+        body         = NULL,
+        created_at   = NOW(),
+        expires_at   = EXCLUDED.expires_at
+    WHERE idempotency_keys.expires_at <= NOW()
+       OR (idempotency_keys.status IS NULL AND idempotency_keys.created_at <= NOW() - $5 * INTERVAL '1 second');`,
+		caller, key, fingerprint, ttl.Seconds(), idempotencyLockTimeout.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 1 {
+		return nil, nil
+	}
+
+	var (
+		stored      string
+		status      *int
+		contentType *string
+		headers     map[string]string
+		body        []byte
+	)
+	err = s.pool.QueryRow(ctx, `
+SELECT fingerprint, status, content_type, headers, body FROM idempotency_keys WHERE caller_id = $1 AND key = $2;`,
+		caller, key).Scan(&stored, &status, &contentType, &headers, &body)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// запись удалили между запросами: первый запрос отменён, повтор должен попробовать снова
+		return nil, storage.ErrIdempotencyKeyInUse
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stored != fingerprint {
+		return nil, storage.ErrIdempotencyKeyReused
+	}
+	if status == nil {
+		return nil, storage.ErrIdempotencyKeyInUse
+	}
+	resp := &storage.IdempotentResponse{Status: *status, Headers: headers, Body: body}
+	if contentType != nil {
+		resp.ContentType = *contentType
+	}
+	return resp, nil
+}
+
+func (s *PgStorage) CompleteIdempotent(ctx context.Context, caller, key string, resp storage.IdempotentResponse) error {
+	_, err := s.pool.Exec(ctx, `
+UPDATE idempotency_keys SET status = $3, content_type = $4, headers = $5, body = $6
+WHERE caller_id = $1 AND key = $2 AND status IS NULL;`,
+		caller, key, resp.Status, resp.ContentType, resp.Headers, resp.Body)
+	return err
+}
+
+// AbortIdempotent освобождает ключ запроса, который завершился без ответа, пригодного для повтора
+func (s *PgStorage) AbortIdempotent(ctx context.Context, caller, key string) error {
+	_, err := s.pool.Exec(ctx, "DELETE FROM idempotency_keys WHERE caller_id = $1 AND key = $2 AND status IS NULL;", caller, key)
+	return err
+}
+
+// PurgeIdempotencyKeys удаляет истёкшие ключи и возвращает их число
+func (s *PgStorage) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	tag, err := s.pool.Exec(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= NOW();")
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,7 @@
+CREATE INDEX IF NOT EXISTS pvz_registration_date_idx ON pvz (registration_date DESC, id DESC);
+CREATE INDEX IF NOT EXISTS pvz_city_idx ON pvz (city, registration_date DESC, id DESC);
+CREATE INDEX IF NOT EXISTS pvz_author_idx ON pvz (author_id);
+CREATE INDEX IF NOT EXISTS receptions_pvz_date_idx ON receptions (pvz_id, registration_date DESC);
+CREATE INDEX IF NOT EXISTS receptions_open_idx ON receptions (pvz_id) WHERE activity;
+CREATE INDEX IF NOT EXISTS products_reception_date_idx ON products (reception_id, registration_date DESC);
+CREATE INDEX IF NOT EXISTS products_type_reception_idx ON products (product_type, reception_id);
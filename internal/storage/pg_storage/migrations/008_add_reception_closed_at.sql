@@ -0,0 +1,2 @@
+ALTER TABLE receptions ADD COLUMN IF NOT EXISTS closed_at TIMESTAMP DEFAULT NULL;
+CREATE INDEX IF NOT EXISTS receptions_closed_at_idx ON receptions (registration_date) WHERE closed_at IS NOT NULL;
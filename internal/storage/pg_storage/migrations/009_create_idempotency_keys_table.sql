@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    caller_id    TEXT      NOT NULL,
+    key          TEXT      NOT NULL,
+    fingerprint  TEXT      NOT NULL,
+    status       INT       DEFAULT NULL,
+    content_type TEXT      DEFAULT NULL,
+    body         BYTEA     DEFAULT NULL,
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMP NOT NULL,
+    PRIMARY KEY (caller_id, key)
+);
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
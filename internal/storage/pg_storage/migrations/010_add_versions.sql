@@ -0,0 +1,5 @@
+-- Версии берутся из общей последовательности: номер не повторяется ни у другого ресурса,
+-- ни у того же ресурса после изменения, поэтому ETag одного объекта не совпадёт с ETag другого.
+CREATE SEQUENCE IF NOT EXISTS entity_versions;
+ALTER TABLE pvz ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT nextval('entity_versions');
+ALTER TABLE receptions ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT nextval('entity_versions');
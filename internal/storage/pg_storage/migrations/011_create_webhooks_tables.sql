@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id         uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    author_id  UUID      DEFAULT NULL,
+    url        TEXT      NOT NULL,
+    events     TEXT[]    NOT NULL,
+    secret     TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (author_id) REFERENCES clients (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
+-- payload хранится байтами, а не JSONB: подпись считается по точному телу запроса
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id               uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    webhook_id       UUID      NOT NULL,
+    event_id         UUID      NOT NULL,
+    event_type       TEXT      NOT NULL,
+    payload          BYTEA     NOT NULL,
+    status           TEXT      NOT NULL DEFAULT 'pending',
+    attempts         INT       NOT NULL DEFAULT 0,
+    next_attempt_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_status_code INT       DEFAULT NULL,
+    last_error       TEXT      DEFAULT NULL,
+    created_at       TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at     TIMESTAMP DEFAULT NULL,
+    UNIQUE (webhook_id, event_id),
+    FOREIGN KEY (webhook_id) REFERENCES webhooks (id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, created_at DESC);
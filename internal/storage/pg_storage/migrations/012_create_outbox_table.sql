@@ -0,0 +1,17 @@
+-- События пишутся в транзакции изменения под блокировкой ПВЗ или его приёмки, поэтому
+-- порядок id у событий одного ПВЗ совпадает с порядком изменений.
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id              BIGSERIAL PRIMARY KEY,
+    event_id        UUID      NOT NULL UNIQUE,
+    event_type      TEXT      NOT NULL,
+    pvz_id          UUID      NOT NULL,
+    payload         BYTEA     NOT NULL,
+    attempts        INT       NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error      TEXT      DEFAULT NULL,
+    created_at      TIMESTAMP NOT NULL DEFAULT NOW(),
+    published_at    TIMESTAMP DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (pvz_id, id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_published_idx ON outbox (published_at) WHERE published_at IS NOT NULL;
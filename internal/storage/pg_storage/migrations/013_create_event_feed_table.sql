@@ -0,0 +1,14 @@
+-- Лента событий для живых подписчиков. Вставки идут под общей advisory-блокировкой, поэтому seq
+-- растёт в порядке фиксации и читатель, дошедший до seq, уже не увидит событие с меньшим seq.
+CREATE TABLE IF NOT EXISTS event_feed
+(
+    seq        BIGSERIAL PRIMARY KEY,
+    event_id   UUID      NOT NULL UNIQUE,
+    event_type TEXT      NOT NULL,
+    pvz_id     UUID      NOT NULL,
+    city       TEXT      NOT NULL,
+    payload    BYTEA     NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS event_feed_pvz_idx ON event_feed (pvz_id, seq);
+CREATE INDEX IF NOT EXISTS event_feed_created_idx ON event_feed (created_at);
@@ -0,0 +1,10 @@
+-- Вёдра токенов ограничителя запросов, общие для всех экземпляров сервиса.
+-- allowed - результат последней попытки взять токен, его возвращает тот же UPDATE.
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limits
+(
+    key        TEXT             PRIMARY KEY,
+    tokens     DOUBLE PRECISION NOT NULL,
+    allowed    BOOLEAN          NOT NULL,
+    updated_at TIMESTAMP        NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS rate_limits_updated_idx ON rate_limits (updated_at);
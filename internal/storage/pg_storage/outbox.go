@@ -0,0 +1,125 @@
+package pg_storage
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+// productEvent - данные событий product.added и product.deleted: товар вместе с ПВЗ приёмки
+type productEvent struct {
+	storage.Product
+	PvzId string `json:"pvzId"`
+}
+
+// addOutbox записывает событие в outbox той же транзакцией, что и изменение: событие
+// сохраняется тогда и только тогда, когда сохраняется изменение.
+func addOutbox(ctx context.Context, tx pgx.Tx, pvzId, eventType string, data any) error {
+	event, err := storage.NewEvent(eventType, pvzId, data)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+INSERT INTO outbox (event_id, event_type, pvz_id, payload) VALUES ($1, $2, $3, $4);`, event.EventId, event.Type, pvzId, payload)
+	return err
+}
+
+// PublishOutbox держит блокировки выбранных строк, пока они публикуются. Строки, перед которыми в том же ПВЗ
+// есть отложенное после ошибки сообщение, не выбираются, а строки, перед которыми есть сообщение,
+// заблокированное другим экземпляром, выбираются, но не публикуются и не входят в результат.
+func (s *PgStorage) PublishOutbox(ctx context.Context, limit int, handle storage.OutboxHandler) (int, error) {
+	// транзакция завершается и при остановке сервиса, чтобы опубликованные события не ушли повторно
+	txCtx := context.WithoutCancel(ctx)
+	var attempted int
+	err := pgx.BeginFunc(txCtx, s.pool, func(tx pgx.Tx) error {
+		q, err := tx.Query(txCtx, `
+WITH batch AS (
+    SELECT o.id, o.pvz_id
+    FROM outbox o
+    WHERE o.published_at IS NULL AND o.next_attempt_at <= NOW()
+      AND NOT EXISTS (SELECT 1 FROM outbox p
+                      WHERE p.pvz_id = o.pvz_id AND p.published_at IS NULL AND p.id < o.id AND p.next_attempt_at > NOW())
+    ORDER BY o.id
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+SELECT o.id, o.pvz_id, o.payload, o.attempts,
+       EXISTS (SELECT 1 FROM outbox p
+               WHERE p.pvz_id = b.pvz_id AND p.published_at IS NULL AND p.id < b.id
+                 AND p.id NOT IN (SELECT id FROM batch))
+FROM batch b
+JOIN outbox o ON o.id = b.id
+ORDER BY o.id;`, limit)
+		if err != nil {
+			return err
+		}
+		type claim struct {
+			msg     storage.OutboxMessage
+			blocked bool
+		}
+		claims, err := pgx.CollectRows(q, func(row pgx.CollectableRow) (claim, error) {
+			var (
+				c       claim
+				pvzId   [16]byte
+				payload []byte
+			)
+			if err := row.Scan(&c.msg.Id, &pvzId, &payload, &c.msg.Attempts, &c.blocked); err != nil {
+				return c, err
+			}
+			c.msg.PvzId = parseStringFromUUID(pvzId)
+			return c, json.Unmarshal(payload, &c.msg.Event)
+		})
+		if err != nil {
+			return err
+		}
+
+		published := make([]int64, 0, len(claims))
+		// ПВЗ, в которых очередное сообщение не опубликовано: следующие их сообщения ждут
+		stopped := make(map[string]bool)
+		for _, c := range claims {
+			if ctx.Err() != nil {
+				break
+			}
+			if c.blocked || stopped[c.msg.PvzId] {
+				stopped[c.msg.PvzId] = true
+				continue
+			}
+			attempted++
+			retryIn, handleErr := handle(ctx, c.msg)
+			if handleErr == nil {
+				published = append(published, c.msg.Id)
+				continue
+			}
+			stopped[c.msg.PvzId] = true
+			if ctx.Err() != nil {
+				break
+			}
+			_, err = tx.Exec(txCtx, `
+UPDATE outbox
+SET attempts        = attempts + 1,
+    last_error      = $2,
+    next_attempt_at = NOW() + $3 * INTERVAL '1 second'
+WHERE id = $1;`, c.msg.Id, handleErr.Error(), retryIn.Seconds())
+			if err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec(txCtx, "UPDATE outbox SET published_at = NOW() WHERE id = ANY ($1);", published)
+		return err
+	})
+	return attempted, err
+}
+
+func (s *PgStorage) PurgeOutbox(ctx context.Context, olderThan time.Duration) (int64, error) {
+	tag, err := s.pool.Exec(ctx, "DELETE FROM outbox WHERE published_at < NOW() - $1 * INTERVAL '1 second';", olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -8,10 +8,12 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"golang.org/x/crypto/bcrypt"
 	"io/fs"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -20,16 +22,45 @@ import (
 var migrationFS embed.FS
 
 type PgStorage struct {
-	conn *pgx.Conn
+	pool *pgxpool.Pool
 }
 
 func NewPgStorage(connString string) (*PgStorage, error) {
-	con, _ := context.WithTimeout(context.Background(), time.Second*5)
-	conn, err := pgx.Connect(con, connString)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	cfg, err := pgxpool.ParseConfig(connString)
 	if err != nil {
 		return nil, err
 	}
-	return &PgStorage{conn: conn}, nil
+	cfg.ConnConfig.Tracer = queryTracer{}
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return &PgStorage{pool: pool}, nil
+}
+
+// Close запрещает новые запросы и ждёт, пока все соединения вернутся в пул,
+// то есть пока не завершатся текущие запросы и транзакции. Если ctx истёк раньше,
+// пул дозакрывается в фоне, а вызывающий получает ошибку.
+func (s *PgStorage) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.pool.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("close postgres pool: %d connections still in use: %w",
+			s.pool.Stat().AcquiredConns(), ctx.Err())
+	}
 }
 
 func IsUUID(str string) bool {
@@ -37,35 +68,72 @@ func IsUUID(str string) bool {
 	return uuidRegex.MatchString(str)
 }
 
-func (s *PgStorage) Migrate() error {
-	entries, err := fs.ReadDir(migrationFS, "migrations")
+func (s *PgStorage) Migrate(ctx context.Context) error {
+	entries, err := migrationFiles()
 	if err != nil {
-		return fmt.Errorf("cannot open migrations directory: %w", err)
+		return err
 	}
 
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].Name() < entries[j].Name()
-	})
+	_, err = s.pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations
+(
+    version    INT PRIMARY KEY,
+    applied_at TIMESTAMP DEFAULT NOW()
+)`)
+	if err != nil {
+		return fmt.Errorf("cannot create schema_migrations: %w", err)
+	}
 
 	for _, entry := range entries {
-		if !strings.HasSuffix(entry.Name(), ".sql") {
-			continue
-		}
-		path := "migrations/" + entry.Name()
+		path := "migrations/" + entry.name
 		content, err := migrationFS.ReadFile(path)
 		if err != nil {
-			return fmt.Errorf("cannot read migrations file %s: %w", entry.Name(), err)
+			return fmt.Errorf("cannot read migrations file %s: %w", entry.name, err)
 		}
 
-		_, err = s.conn.Exec(context.Background(), string(content))
+		_, err = s.pool.Exec(ctx, string(content))
 		if err != nil {
 			return err
 		}
+		_, err = s.pool.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES ($1) ON CONFLICT DO NOTHING", entry.version)
+		if err != nil {
+			return fmt.Errorf("cannot record migration %s: %w", entry.name, err)
+		}
 	}
 
 	return nil
 }
 
+type migrationFile struct {
+	name    string
+	version int
+}
+
+// migrationFiles возвращает вшитые миграции по возрастанию номера из префикса имени (007_...)
+func migrationFiles() ([]migrationFile, error) {
+	entries, err := fs.ReadDir(migrationFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("cannot open migrations directory: %w", err)
+	}
+
+	var files []migrationFile
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		prefix, _, _ := strings.Cut(entry.Name(), "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has no numeric prefix", entry.Name())
+		}
+		files = append(files, migrationFile{name: entry.Name(), version: version})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].version < files[j].version
+	})
+	return files, nil
+}
+
 func HashPassword(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -79,14 +147,17 @@ func ValidatePassword(password, hash string) bool {
 	return err == nil
 }
 
-func (s *PgStorage) getRow(query string, args ...any) ([]any, error) {
-	q, err := s.conn.Query(context.Background(), query, args...)
+func (s *PgStorage) getRow(ctx context.Context, query string, args ...any) ([]any, error) {
+	q, err := s.pool.Query(ctx, query, args...)
 	if err != nil {
 		q.Close()
 		return nil, err
 	}
 	if !q.Next() {
-		return nil, errors.New("query returned no rows")
+		if err := q.Err(); err != nil {
+			return nil, domainError(err)
+		}
+		return nil, errNoRows
 	}
 	user, err := q.Values()
 	q.Close()
@@ -96,7 +167,7 @@ func (s *PgStorage) getRow(query string, args ...any) ([]any, error) {
 	return user, nil
 }
 
-func (s *PgStorage) CreateUser(email, password string, roles []storage.Role) (*storage.UserInfo, error) {
+func (s *PgStorage) CreateUser(ctx context.Context, email, password string, roles []storage.Role) (*storage.UserInfo, error) {
 	moderator, employee := false, false
 	for _, role := range roles {
 		if role == storage.Employee {
@@ -111,12 +182,12 @@ func (s *PgStorage) CreateUser(email, password string, roles []storage.Role) (*s
 		return nil, err
 	}
 
-	_, err = s.conn.Exec(context.Background(), "INSERT INTO Clients (email, password_hash, employee, moderator) VALUES ($1, $2, $3, $4)", email, passwordHash, employee, moderator)
+	_, err = s.pool.Exec(ctx, "INSERT INTO Clients (email, password_hash, employee, moderator) VALUES ($1, $2, $3, $4)", email, passwordHash, employee, moderator)
 	if err != nil {
-		return nil, err
+		return nil, domainError(err)
 	}
 
-	user, err := s.getRow("SELECT * FROM Clients WHERE email = $1", email)
+	user, err := s.getRow(ctx, "SELECT * FROM Clients WHERE email = $1", email)
 	if err != nil {
 		return nil, err
 	}
@@ -160,8 +231,8 @@ func parseStringFromUUID(uuid [16]byte) string {
 	)
 }
 
-func (s *PgStorage) LoginUser(email, password string) (*storage.UserInfo, error) {
-	q, err := s.conn.Query(context.Background(), "SELECT * FROM Clients WHERE email = $1", email)
+func (s *PgStorage) LoginUser(ctx context.Context, email, password string) (*storage.UserInfo, error) {
+	q, err := s.pool.Query(ctx, "SELECT * FROM Clients WHERE email = $1", email)
 	if err != nil {
 		return nil, err
 	}
@@ -169,10 +240,10 @@ func (s *PgStorage) LoginUser(email, password string) (*storage.UserInfo, error)
 		return nil, storage.LoginFailed{Message: "invalid email or password"}
 	}
 	user, err := q.Values()
+	q.Close()
 	if err != nil {
 		return nil, err
 	}
-	q.Close()
 
 	if ValidatePassword(password, user[2].(string)) {
 		var r []storage.Role
@@ -195,313 +266,422 @@ func (s *PgStorage) LoginUser(email, password string) (*storage.UserInfo, error)
 	return nil, storage.LoginFailed{Message: "invalid email or password"}
 }
 
-func (s *PgStorage) inserter(table string, args map[string]any) ([]any, error) {
-	n := len(args)
-	if n == 0 {
-		return nil, errors.New("invalid arguments")
+// checkModerator проверяет, что автор - модератор. Пустой автор (токен из /dummyLogin) не проверяется.
+func (s *PgStorage) checkModerator(ctx context.Context, author string) error {
+	if author == "" {
+		return nil
 	}
-
-	parts := make([]string, n)
-	cols := make([]string, n)
-	i := 1
-
-	qargs := make([]any, n)
-
-	for k, v := range args {
-		parts[i-1] = fmt.Sprintf("$%d", i)
-		cols[i-1] = fmt.Sprintf("%s", k)
-		qargs[i-1] = v
-		i += 1
+	if !IsUUID(author) {
+		return storage.ReceptionFailed{Message: "uuid is not valid"}
 	}
-
-	query := fmt.Sprintf("INSERT INTO %s %s VALUES %s RETURNING *",
-		table,
-		fmt.Sprintf("(%s)", strings.Join(cols, ", ")),
-		fmt.Sprintf("(%s)", strings.Join(parts, ", ")))
-
-	ans, err := s.getRow(query, qargs...)
+	qcheck, err := s.pool.Query(ctx, "SELECT * FROM Clients WHERE id = $1", author)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	return ans, nil
-}
-
-func (s *PgStorage) CreatePvz(author string, params storage.PvzInfo) (*storage.PvzInfo, error) {
-	if author != "" {
-		if !IsUUID(author) {
-			return nil, storage.ReceptionFailed{Message: "uuid is not valid"}
-		}
-		qcheck, err := s.conn.Query(context.Background(), "SELECT * FROM Clients WHERE id = $1", author)
-		if err != nil {
-			return nil, err
-		}
-		if !qcheck.Next() {
-			qcheck.Close()
-			return nil, storage.LoginFailed{Message: "invalid author"}
-		}
-		user, err := qcheck.Values()
-		if err != nil {
-			qcheck.Close()
-			return nil, err
-		}
+	if !qcheck.Next() {
 		qcheck.Close()
-		if !user[3].(bool) {
-			return nil, storage.LoginFailed{Message: "user has no permission"}
-		}
+		return storage.LoginFailed{Message: "invalid author"}
+	}
+	user, err := qcheck.Values()
+	if err != nil {
+		qcheck.Close()
+		return err
 	}
-	paramsMap := make(map[string]any)
-	if params.PvzId != nil {
-		paramsMap["id"] = params.PvzId
+	qcheck.Close()
+	if !user[3].(bool) {
+		return storage.LoginFailed{Message: "user has no permission"}
 	}
-	if params.RegistrationDate != nil {
-		paramsMap["registration_date"] = params.RegistrationDate
+	return nil
+}
+
+func (s *PgStorage) CreatePvz(ctx context.Context, author string, params storage.PvzInfo) (*storage.PvzInfo, error) {
+	if err := s.checkModerator(ctx, author); err != nil {
+		return nil, err
 	}
-	paramsMap["city"] = params.City
+	var authorId any
 	if author != "" {
-		authorId, err := parseUUID(author)
-		if err != nil {
-			return nil, err
-		}
-		paramsMap["author_id"] = authorId
+		authorId = author
 	}
 
-	q, err := s.inserter("pvz", paramsMap)
+	res := &storage.PvzInfo{City: params.City}
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		var (
+			id        [16]byte
+			createdAt time.Time
+		)
+		err := tx.QueryRow(ctx, `
+INSERT INTO pvz (id, author_id, city, registration_date)
+VALUES (COALESCE($1, gen_random_uuid()), $2, $3, COALESCE($4, NOW()))
+RETURNING id, registration_date, version;`, params.PvzId, authorId, string(params.City), params.RegistrationDate).
+			Scan(&id, &createdAt, &res.Version)
+		if err != nil {
+			return domainError(err)
+		}
+		pvzId := parseStringFromUUID(id)
+		res.PvzId, res.RegistrationDate = &pvzId, &createdAt
+		return addOutbox(ctx, tx, pvzId, storage.EventPvzCreated, res)
+	})
 	if err != nil {
 		return nil, err
 	}
+	return res, nil
+}
 
-	uuid := q[0].([16]byte)
-
-	uuidS := fmt.Sprintf(
-		"%x-%x-%x-%x-%x",
-		uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16],
-	)
-	crT := q[3].(time.Time)
-
-	return &storage.PvzInfo{PvzId: &uuidS, RegistrationDate: &crT, City: storage.City(q[2].(string))}, nil
+func parseDateBound(date string) (*time.Time, error) {
+	if date == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return nil, storage.ReceptionFailed{Message: "invalid date " + date}
+	}
+	return &t, nil
 }
 
-func (s *PgStorage) GetPvzInfo(startDate, endDate string, page, limit int) ([]storage.PvzInfo, error) {
-	if page <= 0 || limit <= 0 {
+// listPvz выбирает страницу ПВЗ по фильтру.
+// С курсором используется keyset-пагинация, без него - page и limit.
+func (s *PgStorage) listPvz(ctx context.Context, filter storage.PvzFilter, start, end *time.Time) (*storage.PvzPage, error) {
+	if filter.Limit <= 0 || (filter.Cursor == "" && filter.Page <= 0) {
 		return nil, errors.New("invalid arguments")
 	}
-	query := fmt.Sprintf(`
-SELECT 
-    products.id AS product_id, 
-    products.product_type, 
-    products.registration_date AS product_datetime,
-    products.reception_id, 
-    receptions.registration_date AS receptions_datetime,
-    receptions.activity AS receptions_activity,
-    pvz.id AS pvz_id,
-    pvz.registration_date AS pvz_datetime,
-    pvz.city
-FROM 
-    products 
-LEFT JOIN 
-    receptions 
-    ON products.reception_id = receptions.id
-LEFT JOIN 
-    pvz                        
-    ON receptions.pvz_id = pvz.id
-WHERE 
-    products.registration_date >= '%s' 
-    AND products.registration_date <= '%s'
-ORDER BY 
-	pvz_datetime DESC,
-	receptions_datetime DESC,
-	product_datetime DESC 
-OFFSET %d
-LIMIT %d;
-`, startDate, endDate, limit*(page-1), limit)
-
-	q, err := s.conn.Query(context.Background(), query)
+	cursor, err := decodeCursor(filter.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := buildPvzQuery(filter, start, end, cursor, true)
 	if err != nil {
 		return nil, err
 	}
 
-	pvz := ""
-	rec := ""
-	var res []storage.PvzInfo
+	q, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
 
+	res := make([]storage.PvzInfo, 0, filter.Limit+1)
+	keys := make([]time.Time, 0, filter.Limit+1)
 	for q.Next() {
-		vals, err := q.Values()
-		if err != nil {
+		var (
+			id        [16]byte
+			date, key time.Time
+			city      string
+			version   int64
+		)
+		if err := q.Scan(&id, &date, &city, &version, &key); err != nil {
 			return nil, err
 		}
-		for i, v := range vals {
-			if _, ok := v.([16]byte); ok {
-				vals[i] = parseStringFromUUID(vals[i].([16]byte))
-			}
-		}
-		if vals[6] != pvz {
-			pvz = vals[6].(string)
-			date := vals[7].(time.Time)
-			res = append(res, storage.PvzInfo{PvzId: &pvz, RegistrationDate: &date, City: storage.City(vals[8].(string)), Receptions: make([]storage.ReceptionInfo, 0)})
-		}
-		if vals[3] != rec {
-			rec = vals[3].(string)
-			date := vals[4].(time.Time)
-			status := storage.Inactive
-			if vals[5].(bool) {
-				status = storage.Active
-			}
-			res[len(res)-1].Receptions = append(res[len(res)-1].Receptions, storage.ReceptionInfo{ReceptionId: rec,
-				DateTime: date, PvzId: pvz, Status: status, Products: make([]storage.Product, 0)})
-		}
-		res[len(res)-1].Receptions[len(res[len(res)-1].Receptions)-1].Products = append(res[len(res)-1].Receptions[len(res[len(res)-1].Receptions)-1].Products,
-			storage.Product{ProductId: vals[0].(string), DateTime: vals[2].(time.Time), ProductType: vals[1].(string), ReceptionId: rec})
+		pvzId := parseStringFromUUID(id)
+		res = append(res, storage.PvzInfo{PvzId: &pvzId, RegistrationDate: &date, City: storage.City(city),
+			Version: version})
+		keys = append(keys, key)
+	}
+	if err := q.Err(); err != nil {
+		return nil, err
 	}
 
-	return res, nil
+	page := &storage.PvzPage{Items: res}
+	if len(res) > filter.Limit {
+		page.Items = res[:filter.Limit]
+		page.NextCursor = encodeCursor(pvzCursor{Sort: pvzSort(filter), Ascending: filter.Ascending,
+			Key: keys[filter.Limit-1], Id: *page.Items[filter.Limit-1].PvzId})
+	}
+	return page, nil
 }
 
-func (s *PgStorage) CloseLastReception(uuid string) (*storage.ReceptionInfo, error) {
-	if !IsUUID(uuid) {
-		return nil, storage.ReceptionFailed{Message: "uuid is not valid"}
+func (s *PgStorage) GetPvzList(ctx context.Context, filter storage.PvzFilter) (*storage.PvzPage, error) {
+	start, err := parseDateBound(filter.StartDate)
+	if err != nil {
+		return nil, err
 	}
-	query := fmt.Sprintf("SELECT * FROM receptions WHERE pvz_id = '%s' AND activity = true ORDER BY registration_date DESC LIMIT 1;", uuid)
-
-	r, err := s.getRow(query)
+	end, err := parseDateBound(filter.EndDate)
 	if err != nil {
 		return nil, err
 	}
+	return s.listPvz(ctx, filter, start, end)
+}
 
-	myId := parseStringFromUUID(r[0].([16]byte))
-	pvz := parseStringFromUUID(r[2].([16]byte))
+// GetPvzInfo возвращает страницу ПВЗ (пагинация идёт по ПВЗ, а не по товарам).
+// В ответ попадают все ПВЗ страницы, а StartDate и EndDate фильтруют только их приёмки.
+func (s *PgStorage) GetPvzInfo(ctx context.Context, filter storage.PvzFilter) (*storage.PvzPage, error) {
+	start, err := parseDateBound(filter.StartDate)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseDateBound(filter.EndDate)
+	if err != nil {
+		return nil, err
+	}
 
-	query = fmt.Sprintf("update receptions set activity = false where pvz_id = '%s';", uuid)
-	_, err = s.conn.Exec(context.Background(), query)
+	page, err := s.listPvz(ctx, filter, start, end)
 	if err != nil {
 		return nil, err
 	}
+	for i := range page.Items {
+		page.Items[i].Receptions = make([]storage.ReceptionInfo, 0)
+	}
+	if len(page.Items) == 0 {
+		return page, nil
+	}
 
-	return &storage.ReceptionInfo{ReceptionId: myId, PvzId: pvz, Status: storage.Inactive, DateTime: r[4].(time.Time)}, nil
+	if err := s.fillReceptions(ctx, page.Items, start, end); err != nil {
+		return nil, err
+	}
+	return page, nil
 }
 
-func (s *PgStorage) checkReception(pvzId string) error {
-	if !IsUUID(pvzId) {
-		return storage.ReceptionFailed{Message: "uuid is not valid"}
-	}
-	query := fmt.Sprintf("SELECT * FROM receptions WHERE pvz_id = '%s' AND activity = true ORDER BY registration_date DESC LIMIT 1;", pvzId)
-	q, err := s.conn.Query(context.Background(), query)
+// fillReceptions дописывает к ПВЗ их приёмки за период вместе с товарами, включая приёмки без товаров.
+func (s *PgStorage) fillReceptions(ctx context.Context, res []storage.PvzInfo, start, end *time.Time) error {
+	ids := make([][16]byte, 0, len(res))
+	index := make(map[string]int, len(res))
+	for i, pvz := range res {
+		id, err := parseUUID(*pvz.PvzId)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		index[*pvz.PvzId] = i
+	}
+
+	q, err := s.pool.Query(ctx, `
+SELECT
+    receptions.id,
+    receptions.pvz_id,
+    receptions.registration_date,
+    receptions.activity,
+    receptions.version,
+    products.id,
+    products.product_type,
+    products.registration_date
+FROM
+    receptions
+LEFT JOIN
+    products
+    ON products.reception_id = receptions.id
+WHERE
+    receptions.pvz_id = ANY($1)
+    AND ($2::timestamp IS NULL OR receptions.registration_date >= $2)
+    AND ($3::timestamp IS NULL OR receptions.registration_date <= $3)
+ORDER BY
+    receptions.registration_date DESC,
+    receptions.id,
+    products.registration_date DESC;
+`, ids, start, end)
 	if err != nil {
 		return err
 	}
-	if q.Next() {
-		q.Close()
-		return storage.ReceptionFailed{Message: "opened reception already exists"}
+	defer q.Close()
+
+	rec := ""
+	for q.Next() {
+		var (
+			recId, pvzId [16]byte
+			recDate      time.Time
+			activity     bool
+			version      int64
+			productId    *[16]byte
+			productType  *string
+			productDate  *time.Time
+		)
+		if err := q.Scan(&recId, &pvzId, &recDate, &activity, &version, &productId, &productType, &productDate); err != nil {
+			return err
+		}
+		pvz := &res[index[parseStringFromUUID(pvzId)]]
+		if id := parseStringFromUUID(recId); id != rec {
+			rec = id
+			status := storage.Inactive
+			if activity {
+				status = storage.Active
+			}
+			pvz.Receptions = append(pvz.Receptions, storage.ReceptionInfo{ReceptionId: rec,
+				DateTime: recDate, PvzId: *pvz.PvzId, Status: status, Version: version, Products: make([]storage.Product, 0)})
+		}
+		if productId == nil {
+			continue
+		}
+		last := &pvz.Receptions[len(pvz.Receptions)-1]
+		last.Products = append(last.Products, storage.Product{ProductId: parseStringFromUUID(*productId),
+			DateTime: *productDate, ProductType: *productType, ReceptionId: rec})
 	}
-	q.Close()
-	return nil
+	return q.Err()
 }
 
-func (s *PgStorage) OpenReception(author string, pvz string) (*storage.ReceptionInfo, error) {
-	if !IsUUID(author) || !IsUUID(pvz) {
-		return nil, storage.ReceptionFailed{Message: "uuid is not valid"}
+// lockOpenReception блокирует открытую приёмку ПВЗ до конца транзакции и назначает ей новую версию.
+// Если ожидалась конкретная версия, а приёмка изменилась или уже закрыта, возвращается storage.ErrVersionMismatch.
+func lockOpenReception(ctx context.Context, tx pgx.Tx, pvzId string, expectedVersion int64) (id [16]byte, version int64, err error) {
+	err = tx.QueryRow(ctx, `
+UPDATE receptions SET version = nextval('entity_versions')
+WHERE id = (SELECT id FROM receptions WHERE pvz_id = $1 AND activity = true ORDER BY registration_date DESC LIMIT 1)
+    AND activity = true
+    AND ($2 = 0 OR version = $2)
+RETURNING id, version;`, pvzId, expectedVersion).Scan(&id, &version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if expectedVersion != 0 {
+			return id, 0, storage.ErrVersionMismatch
+		}
+		return id, 0, errNoOpenReception
 	}
-	err := s.checkReception(pvz)
+	return id, version, err
+}
+
+// bumpPvzVersion назначает ПВЗ новую версию, проверяя ожидаемую, и блокирует его до конца транзакции
+func bumpPvzVersion(ctx context.Context, tx pgx.Tx, pvzId string, expectedVersion int64) error {
+	tag, err := tx.Exec(ctx, `
+UPDATE pvz SET version = nextval('entity_versions') WHERE id = $1 AND ($2 = 0 OR version = $2);`, pvzId, expectedVersion)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	params := make(map[string]any)
-	if author != "" {
-		authorId, err := parseUUID(author)
-		if err != nil {
-			return nil, err
+	if tag.RowsAffected() == 0 {
+		if expectedVersion != 0 {
+			return storage.ErrVersionMismatch
 		}
-		params["author_id"] = authorId
+		return storage.ReceptionFailed{Message: "referenced object does not exist"}
 	}
-	pvzId, err := parseUUID(pvz)
-	if err != nil {
-		return nil, err
+	return nil
+}
+
+func (s *PgStorage) CloseLastReception(ctx context.Context, uuid string, expectedVersion int64) (*storage.ReceptionInfo, error) {
+	if !IsUUID(uuid) {
+		return nil, storage.ReceptionFailed{Message: "uuid is not valid"}
 	}
-	params["pvz_id"] = pvzId
 
-	inserter, err := s.inserter("receptions", params)
+	res := &storage.ReceptionInfo{PvzId: uuid, Status: storage.Inactive}
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		id, _, err := lockOpenReception(ctx, tx, uuid, expectedVersion)
+		if err != nil {
+			return err
+		}
+		var closedAt time.Time
+		err = tx.QueryRow(ctx, `
+UPDATE receptions SET activity = false, closed_at = NOW()
+WHERE id = $1
+RETURNING registration_date, closed_at, version;`, id).Scan(&res.DateTime, &closedAt, &res.Version)
+		if err != nil {
+			return err
+		}
+		res.ReceptionId, res.ClosedAt = parseStringFromUUID(id), &closedAt
+		if err := bumpPvzVersion(ctx, tx, uuid, 0); err != nil {
+			return err
+		}
+		return addOutbox(ctx, tx, uuid, storage.EventReceptionClosed, res)
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	status := storage.Inactive
-	if inserter[3].(bool) {
-		status = storage.Active
-	}
-	return &storage.ReceptionInfo{ReceptionId: parseStringFromUUID(inserter[0].([16]byte)),
-			PvzId:  parseStringFromUUID(inserter[2].([16]byte)),
-			Status: status, DateTime: inserter[4].(time.Time)},
-		nil
+	return res, nil
 }
 
-func (s *PgStorage) AddProduct(uuid, author, product string) (*storage.Product, error) {
-	if !IsUUID(uuid) {
-		return nil, errors.New("uuid is not valid")
+func (s *PgStorage) OpenReception(ctx context.Context, author string, pvz string, expectedVersion int64) (*storage.ReceptionInfo, error) {
+	if !IsUUID(author) || !IsUUID(pvz) {
+		return nil, storage.ReceptionFailed{Message: "uuid is not valid"}
 	}
-	query := fmt.Sprintf("SELECT * FROM receptions WHERE pvz_id = '%s' AND activity = true ORDER BY registration_date DESC LIMIT 1;", uuid)
 
-	row, err := s.getRow(query)
+	res := &storage.ReceptionInfo{PvzId: pvz, Status: storage.Active}
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		// блокировка ПВЗ не даёт двум терминалам одновременно открыть в нём приёмку
+		if err := bumpPvzVersion(ctx, tx, pvz, expectedVersion); err != nil {
+			return err
+		}
+		var open bool
+		err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM receptions WHERE pvz_id = $1 AND activity = true);", pvz).Scan(&open)
+		if err != nil {
+			return err
+		}
+		if open {
+			return storage.ErrReceptionAlreadyOpen
+		}
+		var id [16]byte
+		err = tx.QueryRow(ctx, `
+INSERT INTO receptions (author_id, pvz_id) VALUES ($1, $2)
+RETURNING id, registration_date, version;`, author, pvz).Scan(&id, &res.DateTime, &res.Version)
+		if err != nil {
+			return domainError(err)
+		}
+		res.ReceptionId = parseStringFromUUID(id)
+		return addOutbox(ctx, tx, pvz, storage.EventReceptionOpened, res)
+	})
 	if err != nil {
 		return nil, err
 	}
+	return res, nil
+}
 
-	params := make(map[string]any)
+func (s *PgStorage) AddProduct(ctx context.Context, uuid, author, product string, expectedVersion int64) (*storage.Product, error) {
+	if !IsUUID(uuid) {
+		return nil, storage.ReceptionFailed{Message: "uuid is not valid"}
+	}
+	var authorId any
 	if author != "" {
-		params["author_id"], err = parseUUID(author)
+		id, err := parseUUID(author)
 		if err != nil {
 			return nil, err
 		}
+		authorId = id
 	}
-	params["reception_id"] = row[0].([16]byte)
-	params["product_type"] = product
 
-	inserter, err := s.inserter("products", params)
+	res := &storage.Product{ProductType: product}
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		reception, version, err := lockOpenReception(ctx, tx, uuid, expectedVersion)
+		if err != nil {
+			return err
+		}
+		var id [16]byte
+		err = tx.QueryRow(ctx, `
+INSERT INTO products (author_id, reception_id, product_type) VALUES ($1, $2, $3)
+RETURNING id, registration_date;`, authorId, reception, product).Scan(&id, &res.DateTime)
+		if err != nil {
+			return domainError(err)
+		}
+		res.ProductId, res.ReceptionId, res.ReceptionVersion = parseStringFromUUID(id), parseStringFromUUID(reception), version
+		return addOutbox(ctx, tx, uuid, storage.EventProductAdded, productEvent{Product: *res, PvzId: uuid})
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	res := storage.Product{
-		ProductId:   parseStringFromUUID(inserter[0].([16]byte)),
-		ReceptionId: parseStringFromUUID(inserter[2].([16]byte)),
-		ProductType: inserter[3].(string),
-		DateTime:    inserter[4].(time.Time)}
-
-	return &res, nil
+	return res, nil
 }
 
-func (s *PgStorage) DeleteLastProduct(uuid string) error {
+func (s *PgStorage) DeleteLastProduct(ctx context.Context, uuid string, expectedVersion int64) (int64, error) {
 	if !IsUUID(uuid) {
-		return errors.New("uuid is not valid")
+		return 0, storage.ReceptionFailed{Message: "uuid is not valid"}
 	}
-	query := fmt.Sprintf("SELECT * FROM receptions WHERE pvz_id = '%s' AND activity = true ORDER BY registration_date DESC LIMIT 1;", uuid)
 
-	row, err := s.getRow(query)
-	if err != nil {
-		return err
-	}
-
-	query = fmt.Sprintf("select * from products WHERE reception_id = '%s' ORDER BY registration_date DESC LIMIT 1;", parseStringFromUUID(row[0].([16]byte)))
-
-	row, err = s.getRow(query)
-	if err != nil {
-		return err
-	}
-
-	query = fmt.Sprintf("DELETE FROM products WHERE id = '%s';", parseStringFromUUID(row[0].([16]byte)))
-	_, err = s.conn.Exec(context.Background(), query)
+	var version int64
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		reception, v, err := lockOpenReception(ctx, tx, uuid, expectedVersion)
+		if err != nil {
+			return err
+		}
+		var id [16]byte
+		product := storage.Product{ReceptionId: parseStringFromUUID(reception)}
+		err = tx.QueryRow(ctx, `
+DELETE FROM products
+WHERE id = (SELECT id FROM products WHERE reception_id = $1 ORDER BY registration_date DESC LIMIT 1)
+RETURNING id, registration_date, product_type;`, reception).Scan(&id, &product.DateTime, &product.ProductType)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// откат транзакции возвращает приёмке прежнюю версию
+			return storage.ReceptionFailed{Message: "reception has no products"}
+		}
+		if err != nil {
+			return err
+		}
+		product.ProductId, product.ReceptionVersion, version = parseStringFromUUID(id), v, v
+		return addOutbox(ctx, tx, uuid, storage.EventProductDeleted, productEvent{Product: product, PvzId: uuid})
+	})
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	return version, nil
 }
 
-func (s *PgStorage) GetOnlyPvzList() ([]storage.PvzInfo, error) {
+func (s *PgStorage) GetOnlyPvzList(ctx context.Context) ([]storage.PvzInfo, error) {
 	query := fmt.Sprintf("SELECT * FROM pvz")
 
-	row, err := s.conn.Query(context.Background(), query)
+	row, err := s.pool.Query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
+	defer row.Close()
 
 	var res []storage.PvzInfo
 
@@ -512,7 +692,8 @@ func (s *PgStorage) GetOnlyPvzList() ([]storage.PvzInfo, error) {
 		}
 		id := parseStringFromUUID(v[0].([16]byte))
 		t := v[3].(time.Time)
-		res = append(res, storage.PvzInfo{PvzId: &id, RegistrationDate: &t, City: storage.City(v[2].(string))})
+		res = append(res, storage.PvzInfo{PvzId: &id, RegistrationDate: &t, City: storage.City(v[2].(string)),
+			Version: v[4].(int64)})
 	}
 
 	return res, nil
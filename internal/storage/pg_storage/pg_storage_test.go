@@ -3,6 +3,8 @@ package pg_storage
 import (
 	"avito_intr/internal/storage"
 	"context"
+	"encoding/json"
+	"errors"
 	"github.com/jackc/pgx/v5"
 	"log"
 	"os"
@@ -24,7 +26,7 @@ CREATE SCHEMA public;
 	if err != nil {
 		log.Fatal(err)
 	}
-	err = pg.Migrate()
+	err = pg.Migrate(context.Background())
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -40,7 +42,7 @@ func setupStorage(t *testing.T) storage.Storage {
 	if err != nil {
 		log.Fatal(err)
 	}
-	err = pg.Migrate()
+	err = pg.Migrate(context.Background())
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -56,7 +58,7 @@ func TestMigrate(t *testing.T) {
 	s := setupStorage(t)
 	defer teardownStorage(t, s)
 
-	if err := s.Migrate(); err != nil {
+	if err := s.Migrate(context.Background()); err != nil {
 		t.Fatalf("Migrate() error = %v", err)
 	}
 }
@@ -78,7 +80,7 @@ func TestCreateUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := s.CreateUser(tt.email, tt.pass, tt.roles)
+			_, err := s.CreateUser(context.Background(), tt.email, tt.pass, tt.roles)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CreateUser() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -92,7 +94,7 @@ func TestLoginUser(t *testing.T) {
 
 	email := "login@test.com"
 	pass := "secret"
-	_, _ = s.CreateUser(email, pass, []storage.Role{storage.Employee})
+	_, _ = s.CreateUser(context.Background(), email, pass, []storage.Role{storage.Employee})
 
 	tests := []struct {
 		name     string
@@ -108,7 +110,7 @@ func TestLoginUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			user, err := s.LoginUser(tt.email, tt.pass)
+			user, err := s.LoginUser(context.Background(), tt.email, tt.pass)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("LoginUser() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -130,19 +132,19 @@ func TestCreateAndGetPvz(t *testing.T) {
 	s := setupStorage(t)
 	defer teardownStorage(t, s)
 
-	user, err := s.CreateUser("iop@gmail.com", "12345678", []storage.Role{storage.Moderator})
+	user, err := s.CreateUser(context.Background(), "iop@gmail.com", "12345678", []storage.Role{storage.Moderator})
 	if err != nil {
 		t.Fatal(err)
 	}
-	pvz, err := s.CreatePvz(user.UserId, storage.PvzInfo{City: storage.Moscow})
+	pvz, err := s.CreatePvz(context.Background(), user.UserId, storage.PvzInfo{City: storage.Moscow})
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = s.OpenReception(user.UserId, *pvz.PvzId)
+	_, err = s.OpenReception(context.Background(), user.UserId, *pvz.PvzId, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = s.AddProduct(*pvz.PvzId, user.UserId, "одежда")
+	_, err = s.AddProduct(context.Background(), *pvz.PvzId, user.UserId, "одежда", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -157,12 +159,13 @@ func TestCreateAndGetPvz(t *testing.T) {
 	})
 
 	t.Run("get pvz list", func(t *testing.T) {
-		pvzs, err := s.GetPvzInfo(time.Time{}.Format(time.RFC3339), time.Now().Format(time.RFC3339), 1, 10)
+		pvzs, err := s.GetPvzInfo(context.Background(), storage.PvzFilter{StartDate: time.Time{}.Format(time.RFC3339),
+			EndDate: time.Now().Format(time.RFC3339), Page: 1, Limit: 10})
 		if err != nil {
 			t.Fatal(err)
 		}
-		if len(pvzs) != 1 {
-			t.Errorf("GetPvzInfo() count = %d, want 1", len(pvzs))
+		if len(pvzs.Items) != 1 {
+			t.Errorf("GetPvzInfo() count = %d, want 1", len(pvzs.Items))
 		}
 	})
 }
@@ -171,23 +174,23 @@ func TestReceptionFlow(t *testing.T) {
 	s := setupStorage(t)
 	defer teardownStorage(t, s)
 
-	user, err := s.CreateUser("iop@gmail.com", "12345678", []storage.Role{storage.Moderator})
+	user, err := s.CreateUser(context.Background(), "iop@gmail.com", "12345678", []storage.Role{storage.Moderator})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	pvz, err := s.CreatePvz(user.UserId, storage.PvzInfo{City: storage.SPB})
+	pvz, err := s.CreatePvz(context.Background(), user.UserId, storage.PvzInfo{City: storage.SPB})
 	if err != nil {
 		t.Fatal(err)
 	}
 	pvzID := *pvz.PvzId
 
-	user, err = s.CreateUser("iop1@gmail.com", "12345678", []storage.Role{storage.Employee})
+	user, err = s.CreateUser(context.Background(), "iop1@gmail.com", "12345678", []storage.Role{storage.Employee})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	reception, err := s.OpenReception(user.UserId, pvzID)
+	reception, err := s.OpenReception(context.Background(), user.UserId, pvzID, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -198,7 +201,7 @@ func TestReceptionFlow(t *testing.T) {
 		}
 	})
 
-	product, err := s.AddProduct(*pvz.PvzId, user.UserId, "одежда")
+	product, err := s.AddProduct(context.Background(), *pvz.PvzId, user.UserId, "одежда", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -209,7 +212,7 @@ func TestReceptionFlow(t *testing.T) {
 		}
 	})
 
-	closed, err := s.CloseLastReception(pvzID)
+	closed, err := s.CloseLastReception(context.Background(), pvzID, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -220,23 +223,23 @@ func TestReceptionFlow(t *testing.T) {
 		}
 	})
 
-	_, err = s.OpenReception(user.UserId, pvzID)
+	_, err = s.OpenReception(context.Background(), user.UserId, pvzID, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = s.AddProduct(*pvz.PvzId, user.UserId, "одежда")
+	_, err = s.AddProduct(context.Background(), *pvz.PvzId, user.UserId, "одежда", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	t.Run("delete product", func(t *testing.T) {
-		err := s.DeleteLastProduct(*pvz.PvzId)
+		_, err := s.DeleteLastProduct(context.Background(), *pvz.PvzId, 0)
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		pvzs, _ := s.GetPvzInfo("", "", 1, 10)
-		for _, p := range pvzs {
+		pvzs, _ := s.GetPvzInfo(context.Background(), storage.PvzFilter{Page: 1, Limit: 10})
+		for _, p := range pvzs.Items {
 			if *p.PvzId == pvzID {
 				if len(p.Receptions[0].Products) != 0 {
 					t.Error("Product not deleted")
@@ -245,3 +248,809 @@ func TestReceptionFlow(t *testing.T) {
 		}
 	})
 }
+
+func TestGetPvzInfoPagination(t *testing.T) {
+	s := setupStorage(t)
+	defer teardownStorage(t, s)
+
+	user, err := s.CreateUser(context.Background(), "pag@gmail.com", "12345678", []storage.Role{storage.Moderator})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		date := base.Add(time.Duration(i) * time.Hour)
+		pvz, err := s.CreatePvz(context.Background(), user.UserId, storage.PvzInfo{City: storage.Kazan, RegistrationDate: &date})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, *pvz.PvzId)
+	}
+
+	// у самого нового ПВЗ есть приёмка без товаров, у остальных приёмок нет
+	if _, err := s.OpenReception(context.Background(), user.UserId, ids[2], 0); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("pages split by pvz", func(t *testing.T) {
+		first, err := s.GetPvzInfo(context.Background(), storage.PvzFilter{Page: 1, Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := s.GetPvzInfo(context.Background(), storage.PvzFilter{Page: 2, Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(first.Items) != 2 || len(second.Items) != 1 {
+			t.Fatalf("page sizes = %d, %d, want 2, 1", len(first.Items), len(second.Items))
+		}
+		if *first.Items[0].PvzId != ids[2] || *second.Items[0].PvzId != ids[0] {
+			t.Error("pvz are not ordered by registration date")
+		}
+		if len(first.Items[0].Receptions) != 1 || len(first.Items[0].Receptions[0].Products) != 0 {
+			t.Error("reception without products is missing")
+		}
+	})
+
+	t.Run("dates filter receptions only", func(t *testing.T) {
+		pvzs, err := s.GetPvzInfo(context.Background(), storage.PvzFilter{StartDate: time.Now().Add(time.Hour).Format(time.RFC3339), Page: 1, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pvzs.Items) != 3 {
+			t.Fatalf("GetPvzInfo() count = %d, want 3", len(pvzs.Items))
+		}
+		for _, p := range pvzs.Items {
+			if len(p.Receptions) != 0 {
+				t.Errorf("pvz %s has receptions outside of the range", *p.PvzId)
+			}
+		}
+	})
+
+	t.Run("invalid date", func(t *testing.T) {
+		if _, err := s.GetPvzInfo(context.Background(), storage.PvzFilter{StartDate: "yesterday", Page: 1, Limit: 10}); err == nil {
+			t.Error("expected error for invalid date")
+		}
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		first, err := s.GetPvzInfo(context.Background(), storage.PvzFilter{Page: 1, Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first.NextCursor == "" {
+			t.Fatal("NextCursor not set")
+		}
+
+		// новый ПВЗ между запросами не должен сдвигать следующую страницу
+		if _, err := s.CreatePvz(context.Background(), user.UserId, storage.PvzInfo{City: storage.Moscow}); err != nil {
+			t.Fatal(err)
+		}
+
+		next, err := s.GetPvzInfo(context.Background(), storage.PvzFilter{Limit: 2, Cursor: first.NextCursor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(next.Items) != 1 || *next.Items[0].PvzId != ids[0] {
+			t.Fatalf("cursor page = %v, want only %s", next.Items, ids[0])
+		}
+		if next.NextCursor != "" {
+			t.Error("NextCursor set on the last page")
+		}
+
+		if _, err := s.GetPvzInfo(context.Background(), storage.PvzFilter{Limit: 2, Cursor: "garbage"}); err == nil {
+			t.Error("expected error for invalid cursor")
+		}
+	})
+}
+
+func TestGetPvzInfoFilters(t *testing.T) {
+	s := setupStorage(t)
+	defer teardownStorage(t, s)
+
+	moderator, err := s.CreateUser(context.Background(), "filter@gmail.com", "12345678", []storage.Role{storage.Moderator})
+	if err != nil {
+		t.Fatal(err)
+	}
+	employee, err := s.CreateUser(context.Background(), "filter1@gmail.com", "12345678", []storage.Role{storage.Employee})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	moscow, err := s.CreatePvz(context.Background(), moderator.UserId, storage.PvzInfo{City: storage.Moscow, RegistrationDate: &old})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kazan, err := s.CreatePvz(context.Background(), moderator.UserId, storage.PvzInfo{City: storage.Kazan})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.OpenReception(context.Background(), employee.UserId, *moscow.PvzId, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddProduct(context.Background(), *moscow.PvzId, employee.UserId, storage.Shoes, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	open, closed := true, false
+	tests := []struct {
+		name   string
+		filter storage.PvzFilter
+		want   []string
+	}{
+		{"city", storage.PvzFilter{City: storage.Kazan}, []string{*kazan.PvzId}},
+		{"open reception", storage.PvzFilter{OpenReception: &open}, []string{*moscow.PvzId}},
+		{"no open reception", storage.PvzFilter{OpenReception: &closed}, []string{*kazan.PvzId}},
+		{"product type", storage.PvzFilter{ProductType: storage.Shoes}, []string{*moscow.PvzId}},
+		{"missing product type", storage.PvzFilter{ProductType: storage.Electronics}, nil},
+		{"author", storage.PvzFilter{Author: moderator.UserId}, []string{*kazan.PvzId, *moscow.PvzId}},
+		{"sort by activity", storage.PvzFilter{Sort: storage.SortByActivity}, []string{*moscow.PvzId, *kazan.PvzId}},
+		{"ascending", storage.PvzFilter{Ascending: true}, []string{*moscow.PvzId, *kazan.PvzId}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.Page, tt.filter.Limit = 1, 10
+			pvzs, err := s.GetPvzInfo(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pvzs.Items) != len(tt.want) {
+				t.Fatalf("GetPvzInfo() count = %d, want %d", len(pvzs.Items), len(tt.want))
+			}
+			for i, id := range tt.want {
+				if *pvzs.Items[i].PvzId != id {
+					t.Errorf("item %d = %s, want %s", i, *pvzs.Items[i].PvzId, id)
+				}
+			}
+		})
+	}
+
+	t.Run("cursor keeps sort", func(t *testing.T) {
+		first, err := s.GetPvzInfo(context.Background(), storage.PvzFilter{Page: 1, Limit: 1, Sort: storage.SortByActivity})
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, err := s.GetPvzInfo(context.Background(), storage.PvzFilter{Limit: 1, Cursor: first.NextCursor, Sort: storage.SortByActivity})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(next.Items) != 1 || *next.Items[0].PvzId != *kazan.PvzId {
+			t.Error("unexpected second page for activity sort")
+		}
+		if _, err := s.GetPvzInfo(context.Background(), storage.PvzFilter{Limit: 1, Cursor: first.NextCursor}); err == nil {
+			t.Error("expected error for cursor with another sort")
+		}
+	})
+
+	t.Run("invalid city", func(t *testing.T) {
+		if _, err := s.GetPvzInfo(context.Background(), storage.PvzFilter{Page: 1, Limit: 10, City: "Тверь"}); err == nil {
+			t.Error("expected error for invalid city")
+		}
+	})
+}
+
+func TestExportReceptions(t *testing.T) {
+	s := setupStorage(t)
+	defer teardownStorage(t, s)
+
+	user, err := s.CreateUser(context.Background(), "export@gmail.com", "12345678", []storage.Role{storage.Moderator})
+	if err != nil {
+		t.Fatal(err)
+	}
+	moscow, err := s.CreatePvz(context.Background(), user.UserId, storage.PvzInfo{City: storage.Moscow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kazan, err := s.CreatePvz(context.Background(), user.UserId, storage.PvzInfo{City: storage.Kazan})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.OpenReception(context.Background(), user.UserId, *moscow.PvzId, 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := s.AddProduct(context.Background(), *moscow.PvzId, user.UserId, storage.Clothes, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.OpenReception(context.Background(), user.UserId, *kazan.PvzId, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	collect := func(filter storage.ExportFilter) []storage.ExportRow {
+		var rows []storage.ExportRow
+		err := s.ExportReceptions(context.Background(), filter, func(row storage.ExportRow) error {
+			rows = append(rows, row)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+
+	t.Run("all", func(t *testing.T) {
+		if rows := collect(storage.ExportFilter{}); len(rows) != 4 {
+			t.Errorf("rows = %d, want 4", len(rows))
+		}
+	})
+
+	t.Run("reception without products", func(t *testing.T) {
+		rows := collect(storage.ExportFilter{City: storage.Kazan})
+		if len(rows) != 1 || rows[0].ProductId != "" || rows[0].ProductDate != nil {
+			t.Errorf("rows = %v, want one row without product", rows)
+		}
+	})
+
+	t.Run("pvz", func(t *testing.T) {
+		for _, row := range collect(storage.ExportFilter{PvzId: *moscow.PvzId}) {
+			if row.PvzId != *moscow.PvzId || row.ProductType != storage.Clothes {
+				t.Errorf("unexpected row %v", row)
+			}
+		}
+	})
+
+	t.Run("callback error stops export", func(t *testing.T) {
+		stop := errors.New("stop")
+		calls := 0
+		err := s.ExportReceptions(context.Background(), storage.ExportFilter{}, func(storage.ExportRow) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Errorf("err = %v, calls = %d", err, calls)
+		}
+	})
+}
+
+func TestStreamPvzInfo(t *testing.T) {
+	s := setupStorage(t)
+	defer teardownStorage(t, s)
+
+	user, err := s.CreateUser(context.Background(), "stream@gmail.com", "12345678", []storage.Role{storage.Moderator})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		date := base.Add(time.Duration(i) * time.Minute)
+		pvz, err := s.CreatePvz(context.Background(), user.UserId, storage.PvzInfo{City: storage.SPB, RegistrationDate: &date})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, *pvz.PvzId)
+	}
+	if _, err := s.OpenReception(context.Background(), user.UserId, ids[1], 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := s.AddProduct(context.Background(), ids[1], user.UserId, storage.Electronics, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []storage.PvzInfo
+	err = s.StreamPvzInfo(context.Background(), storage.PvzFilter{Ascending: true}, func(pvz storage.PvzInfo) error {
+		got = append(got, pvz)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("streamed %d pvz, want 3", len(got))
+	}
+	for i, id := range ids {
+		if *got[i].PvzId != id {
+			t.Errorf("item %d = %s, want %s", i, *got[i].PvzId, id)
+		}
+	}
+	if len(got[1].Receptions) != 1 || len(got[1].Receptions[0].Products) != 2 {
+		t.Errorf("receptions of %s were not grouped", ids[1])
+	}
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := s.StreamPvzInfo(ctx, storage.PvzFilter{}, func(storage.PvzInfo) error { return nil })
+		if err == nil {
+			t.Error("expected error for cancelled context")
+		}
+	})
+}
+
+func TestGetAnalytics(t *testing.T) {
+	s := setupStorage(t)
+	defer teardownStorage(t, s)
+
+	moderator, err := s.CreateUser(context.Background(), "stats@gmail.com", "12345678", []storage.Role{storage.Moderator})
+	if err != nil {
+		t.Fatal(err)
+	}
+	employee, err := s.CreateUser(context.Background(), "stats1@gmail.com", "12345678", []storage.Role{storage.Employee})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pvz, err := s.CreatePvz(context.Background(), moderator.UserId, storage.PvzInfo{City: storage.Moscow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.OpenReception(context.Background(), employee.UserId, *pvz.PvzId, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, product := range []string{storage.Clothes, storage.Clothes, storage.Shoes} {
+		if _, err := s.AddProduct(context.Background(), *pvz.PvzId, employee.UserId, product, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	closed, err := s.CloseLastReception(context.Background(), *pvz.PvzId, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closed.ClosedAt == nil {
+		t.Fatal("ClosedAt not set")
+	}
+
+	stats, err := s.GetAnalytics(context.Background(), storage.AnalyticsFilter{City: storage.Moscow})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("products per day", func(t *testing.T) {
+		counts := map[string]int{}
+		for _, row := range stats.ProductsPerPvz {
+			if row.PvzId != *pvz.PvzId {
+				t.Errorf("unexpected pvz %s", row.PvzId)
+			}
+			counts[row.ProductType] += row.Count
+		}
+		if counts[storage.Clothes] != 2 || counts[storage.Shoes] != 1 {
+			t.Errorf("per pvz counts = %v", counts)
+		}
+		if len(stats.ProductsPerCity) != 2 || stats.ProductsPerCity[0].PvzId != "" {
+			t.Errorf("per city rows = %v", stats.ProductsPerCity)
+		}
+	})
+
+	t.Run("durations", func(t *testing.T) {
+		if stats.Durations.Closed != 1 || stats.Durations.P95 < stats.Durations.Average {
+			t.Errorf("durations = %+v", stats.Durations)
+		}
+	})
+
+	t.Run("per employee", func(t *testing.T) {
+		if len(stats.ReceptionsPerEmployee) != 1 || stats.ReceptionsPerEmployee[0].EmployeeId != employee.UserId ||
+			stats.ReceptionsPerEmployee[0].Receptions != 1 {
+			t.Errorf("per employee = %v", stats.ReceptionsPerEmployee)
+		}
+	})
+
+	t.Run("other city is empty", func(t *testing.T) {
+		other, err := s.GetAnalytics(context.Background(), storage.AnalyticsFilter{City: storage.Kazan})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(other.ProductsPerPvz) != 0 || other.Durations.Closed != 0 {
+			t.Errorf("unexpected analytics for Kazan: %+v", other)
+		}
+	})
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	s := setupStorage(t)
+	defer teardownStorage(t, s)
+	ctx := context.Background()
+
+	stored, err := s.BeginIdempotent(ctx, "caller", "k1", "fp1", time.Hour)
+	if err != nil || stored != nil {
+		t.Fatalf("first begin = %v, %v", stored, err)
+	}
+	if _, err := s.BeginIdempotent(ctx, "caller", "k1", "fp1", time.Hour); !errors.Is(err, storage.ErrIdempotencyKeyInUse) {
+		t.Errorf("begin while in progress: %v", err)
+	}
+	if stored, err := s.BeginIdempotent(ctx, "other", "k1", "fp1", time.Hour); err != nil || stored != nil {
+		t.Errorf("keys of other callers must not clash: %v, %v", stored, err)
+	}
+
+	resp := storage.IdempotentResponse{Status: 201, ContentType: "application/json", Headers: map[string]string{"ETag": `"7"`},
+		Body: []byte(`{"id":"1"}`)}
+	if err := s.CompleteIdempotent(ctx, "caller", "k1", resp); err != nil {
+		t.Fatal(err)
+	}
+	stored, err = s.BeginIdempotent(ctx, "caller", "k1", "fp1", time.Hour)
+	if err != nil || stored == nil || stored.Status != 201 || string(stored.Body) != `{"id":"1"}` || stored.Headers["ETag"] != `"7"` {
+		t.Errorf("replay = %+v, %v", stored, err)
+	}
+	if _, err := s.BeginIdempotent(ctx, "caller", "k1", "fp2", time.Hour); !errors.Is(err, storage.ErrIdempotencyKeyReused) {
+		t.Errorf("other payload: %v", err)
+	}
+
+	if err := s.AbortIdempotent(ctx, "other", "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := s.BeginIdempotent(ctx, "other", "k1", "fp2", time.Hour); err != nil || stored != nil {
+		t.Errorf("aborted key must be free: %v, %v", stored, err)
+	}
+
+	if _, err := s.BeginIdempotent(ctx, "caller", "expired", "fp1", -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := s.BeginIdempotent(ctx, "caller", "expired", "fp2", time.Hour); err != nil || stored != nil {
+		t.Errorf("expired key must be taken again: %v, %v", stored, err)
+	}
+	if _, err := s.BeginIdempotent(ctx, "caller", "purged", "fp1", -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.PurgeIdempotencyKeys(ctx); err != nil || n != 1 {
+		t.Errorf("purged = %d, %v", n, err)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	s := setupStorage(t)
+	defer teardownStorage(t, s)
+	ctx := context.Background()
+
+	user, err := s.CreateUser(ctx, "versions@gmail.com", "12345678", []storage.Role{storage.Employee})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pvz, err := s.CreatePvz(ctx, "", storage.PvzInfo{City: storage.Kazan})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pvz.Version == 0 {
+		t.Fatal("created pvz has no version")
+	}
+
+	if _, err := s.OpenReception(ctx, user.UserId, *pvz.PvzId, pvz.Version+1); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Errorf("open with stale pvz version: %v", err)
+	}
+	reception, err := s.OpenReception(ctx, user.UserId, *pvz.PvzId, pvz.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.OpenReception(ctx, user.UserId, *pvz.PvzId, pvz.Version); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Errorf("opening a reception must change the pvz version: %v", err)
+	}
+
+	product, err := s.AddProduct(ctx, *pvz.PvzId, user.UserId, storage.Shoes, reception.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.ReceptionVersion <= reception.Version {
+		t.Errorf("reception version after add = %d, want > %d", product.ReceptionVersion, reception.Version)
+	}
+	if _, err := s.AddProduct(ctx, *pvz.PvzId, user.UserId, storage.Shoes, reception.Version); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Errorf("add with stale reception version: %v", err)
+	}
+
+	version, err := s.DeleteLastProduct(ctx, *pvz.PvzId, product.ReceptionVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeleteLastProduct(ctx, *pvz.PvzId, version); err == nil || errors.Is(err, storage.ErrVersionMismatch) {
+		t.Errorf("delete from empty reception: %v", err)
+	}
+
+	closed, err := s.CloseLastReception(ctx, *pvz.PvzId, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CloseLastReception(ctx, *pvz.PvzId, closed.Version); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Errorf("closing a closed reception with If-Match: %v", err)
+	}
+
+	page, err := s.GetPvzInfo(ctx, storage.PvzFilter{Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := page.Items[0].Receptions[0].Version; got != closed.Version {
+		t.Errorf("listed reception version = %d, want %d", got, closed.Version)
+	}
+	if page.Items[0].Version == pvz.Version {
+		t.Error("closing a reception must change the pvz version")
+	}
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	s := setupStorage(t)
+	defer teardownStorage(t, s)
+	ctx := context.Background()
+
+	moderator, err := s.CreateUser(ctx, "hooks@gmail.com", "12345678", []storage.Role{storage.Moderator})
+	if err != nil {
+		t.Fatal(err)
+	}
+	employee, err := s.CreateUser(ctx, "hooks-employee@gmail.com", "12345678", []storage.Role{storage.Employee})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateWebhook(ctx, employee.UserId, storage.Webhook{URL: "http://localhost/h", Events: []string{storage.EventPvzCreated}}); err == nil {
+		t.Error("employee must not create webhooks")
+	}
+	if _, err := s.ListWebhooks(ctx, ""); err == nil {
+		t.Error("token from /dummyLogin must not list webhooks")
+	}
+	hook, err := s.CreateWebhook(ctx, moderator.UserId, storage.Webhook{URL: "http://localhost/h",
+		Events: []string{storage.EventPvzCreated}, Secret: "0123456789abcdef"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := storage.NewEvent(storage.EventPvzCreated, "11111111-1111-1111-1111-111111111111", map[string]string{"city": "Москва"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed, _ := storage.NewEvent(storage.EventReceptionClosed, "11111111-1111-1111-1111-111111111111", map[string]string{})
+	for _, event := range []storage.Event{created, created, closed} {
+		if err := s.EnqueueEvent(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	claimed, err := s.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 1 || claimed[0].EventId != created.EventId || claimed[0].Secret != "0123456789abcdef" {
+		t.Fatalf("claimed = %+v, want one delivery of the subscribed event", claimed)
+	}
+	if again, err := s.ClaimWebhookDeliveries(ctx, 10, time.Minute); err != nil || len(again) != 0 {
+		t.Errorf("leased delivery was claimed again: %+v, %v", again, err)
+	}
+
+	err = s.SaveWebhookAttempt(ctx, storage.WebhookAttempt{DeliveryId: claimed[0].DeliveryId, Status: storage.DeliveryPending,
+		StatusCode: 503, Error: "unexpected response status 503"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	retry, err := s.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	if err != nil || len(retry) != 1 || retry[0].Attempts != 1 {
+		t.Fatalf("retry = %+v, %v", retry, err)
+	}
+	err = s.SaveWebhookAttempt(ctx, storage.WebhookAttempt{DeliveryId: retry[0].DeliveryId, Status: storage.DeliveryDead, StatusCode: 503})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadLog, err := s.ListWebhookDeliveries(ctx, moderator.UserId, hook.WebhookId, storage.DeliveryFilter{Status: storage.DeliveryDead, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deadLog) != 1 || deadLog[0].Attempts != 2 || deadLog[0].LastStatusCode != 503 || deadLog[0].DeliveredAt != nil {
+		t.Errorf("dead deliveries = %+v", deadLog)
+	}
+
+	if err := s.DeleteWebhook(ctx, moderator.UserId, hook.WebhookId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ListWebhookDeliveries(ctx, moderator.UserId, hook.WebhookId, storage.DeliveryFilter{Limit: 10}); !errors.Is(err, storage.ErrWebhookNotFound) {
+		t.Errorf("deliveries of a deleted webhook: %v", err)
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	s := setupStorage(t)
+	defer teardownStorage(t, s)
+	ctx := context.Background()
+
+	user, err := s.CreateUser(ctx, "outbox@gmail.com", "12345678", []storage.Role{storage.Employee})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := s.CreatePvz(ctx, "", storage.PvzInfo{City: storage.Moscow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.CreatePvz(ctx, "", storage.PvzInfo{City: storage.Kazan})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.OpenReception(ctx, user.UserId, *first.PvzId, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddProduct(ctx, *first.PvzId, user.UserId, storage.Shoes, 0); err != nil {
+		t.Fatal(err)
+	}
+	// неудачное изменение не оставляет события
+	if _, err := s.AddProduct(ctx, *second.PvzId, user.UserId, storage.Shoes, 0); err == nil {
+		t.Fatal("expected error for pvz without reception")
+	}
+	if _, err := s.CloseLastReception(ctx, *first.PvzId, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// первое событие ПВЗ first не публикуется: остальные его события ждут, second публикуется
+	var got []storage.OutboxMessage
+	failing := *first.PvzId
+	handle := func(ctx context.Context, msg storage.OutboxMessage) (time.Duration, error) {
+		if msg.PvzId == failing {
+			return 0, errors.New("broker is unavailable")
+		}
+		got = append(got, msg)
+		return 0, nil
+	}
+	if _, err := s.PublishOutbox(ctx, 10, handle); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].PvzId != *second.PvzId || got[0].Event.Type != storage.EventPvzCreated {
+		t.Fatalf("published = %+v, want only pvz.created of the second pvz", got)
+	}
+
+	failing = ""
+	got = nil
+	if _, err := s.PublishOutbox(ctx, 10, handle); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{storage.EventPvzCreated, storage.EventReceptionOpened, storage.EventProductAdded, storage.EventReceptionClosed}
+	if len(got) != len(want) {
+		t.Fatalf("published %d events, want %d: %+v", len(got), len(want), got)
+	}
+	for i, msg := range got {
+		if msg.Event.Type != want[i] || msg.PvzId != *first.PvzId {
+			t.Errorf("event %d = %s of %s, want %s", i, msg.Event.Type, msg.PvzId, want[i])
+		}
+	}
+	if got[0].Attempts != 1 {
+		t.Errorf("attempts of the failed event = %d, want 1", got[0].Attempts)
+	}
+	var product struct {
+		PvzId            string `json:"pvzId"`
+		Type             string `json:"type"`
+		ReceptionVersion int64  `json:"receptionVersion"`
+	}
+	if err := json.Unmarshal(got[2].Event.Data, &product); err != nil || product.PvzId != *first.PvzId || product.Type != storage.Shoes ||
+		product.ReceptionVersion == 0 {
+		t.Errorf("product.added data = %s", got[2].Event.Data)
+	}
+
+	if n, err := s.PublishOutbox(ctx, 10, handle); err != nil || n != 0 {
+		t.Errorf("published events were claimed again: %d, %v", n, err)
+	}
+	if n, err := s.PurgeOutbox(ctx, 0); err != nil || n != 5 {
+		t.Errorf("purged %d, %v, want 5", n, err)
+	}
+}
+
+func TestEventFeed(t *testing.T) {
+	s := setupStorage(t)
+	defer teardownStorage(t, s)
+	ctx := context.Background()
+
+	moscow, err := s.CreatePvz(ctx, "", storage.PvzInfo{City: storage.Moscow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kazan, err := s.CreatePvz(ctx, "", storage.PvzInfo{City: storage.Kazan})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listenCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	notified := make(chan struct{}, 10)
+	listening := make(chan error, 1)
+	go func() { listening <- s.ListenFeed(listenCtx, func() { notified <- struct{}{} }) }()
+	time.Sleep(100 * time.Millisecond)
+
+	opened, _ := storage.NewEvent(storage.EventReceptionOpened, *moscow.PvzId, map[string]string{})
+	added, _ := storage.NewEvent(storage.EventProductAdded, *kazan.PvzId, map[string]string{})
+	for _, event := range []storage.Event{opened, added, opened} {
+		if err := s.AppendFeed(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-notified:
+	case err := <-listening:
+		t.Fatalf("listener stopped: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification")
+	}
+
+	all, err := s.ReadFeed(ctx, storage.FeedFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].Event.EventId != opened.EventId || all[1].Seq <= all[0].Seq || all[1].City != storage.Kazan {
+		t.Fatalf("feed = %+v, want two events in order without the duplicate", all)
+	}
+	if last, err := s.LastFeedSeq(ctx); err != nil || last != all[1].Seq {
+		t.Errorf("last seq = %d, %v, want %d", last, err, all[1].Seq)
+	}
+	byCity, err := s.ReadFeed(ctx, storage.FeedFilter{City: storage.Moscow, Limit: 10})
+	if err != nil || len(byCity) != 1 || byCity[0].Event.PvzId != *moscow.PvzId {
+		t.Errorf("moscow feed = %+v, %v", byCity, err)
+	}
+	after, err := s.ReadFeed(ctx, storage.FeedFilter{After: all[0].Seq, PvzId: *kazan.PvzId, Limit: 10})
+	if err != nil || len(after) != 1 || after[0].Event.EventId != added.EventId {
+		t.Errorf("kazan feed after first event = %+v, %v", after, err)
+	}
+}
+
+func TestGetOpenReception(t *testing.T) {
+	s := setupStorage(t)
+	defer teardownStorage(t, s)
+	ctx := context.Background()
+
+	user, err := s.CreateUser(ctx, "watch@gmail.com", "12345678", []storage.Role{storage.Employee})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pvz, err := s.CreatePvz(ctx, "", storage.PvzInfo{City: storage.Moscow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pvzID := *pvz.PvzId
+
+	if rec, err := s.GetOpenReception(ctx, pvzID); err != nil || rec != nil {
+		t.Fatalf("open reception of new pvz = %+v, %v", rec, err)
+	}
+	if _, err := s.GetOpenReception(ctx, "00000000-0000-0000-0000-000000000000"); err == nil {
+		t.Error("expected error for unknown pvz")
+	}
+
+	opened, err := s.OpenReception(ctx, user.UserId, pvzID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := s.AddProduct(ctx, pvzID, user.UserId, storage.Shoes, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.AddProduct(ctx, pvzID, user.UserId, storage.Clothes, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := s.GetOpenReception(ctx, pvzID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec == nil || rec.ReceptionId != opened.ReceptionId || rec.Version != second.ReceptionVersion || len(rec.Products) != 2 ||
+		rec.Products[0].ProductId != first.ProductId || rec.Products[1].ProductId != second.ProductId {
+		t.Fatalf("open reception = %+v", rec)
+	}
+
+	if _, err := s.CloseLastReception(ctx, pvzID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if rec, err := s.GetOpenReception(ctx, pvzID); err != nil || rec != nil {
+		t.Errorf("open reception after close = %+v, %v", rec, err)
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	s := setupStorage(t)
+	defer teardownStorage(t, s)
+	ctx := context.Background()
+
+	// ведро пополняется на токен в час и за время теста не наполнится
+	rate := 1.0 / 3600
+	for i, want := range []bool{true, true, false} {
+		res, err := s.TakeRateLimit(ctx, "list:ip:10.0.0.1", rate, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed != want {
+			t.Errorf("request %d = %+v, want allowed %v", i+1, res, want)
+		}
+	}
+	if res, err := s.TakeRateLimit(ctx, "list:ip:10.0.0.2", rate, 2); err != nil || !res.Allowed || res.Tokens != 1 {
+		t.Errorf("other key = %+v, %v", res, err)
+	}
+
+	if n, err := s.PurgeRateLimits(ctx, time.Hour); err != nil || n != 0 {
+		t.Errorf("purged %d fresh buckets, %v", n, err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n, err := s.PurgeRateLimits(ctx, time.Millisecond); err != nil || n != 2 {
+		t.Errorf("purged %d, %v, want 2", n, err)
+	}
+}
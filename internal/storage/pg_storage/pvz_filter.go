@@ -0,0 +1,153 @@
+package pg_storage
+
+import (
+	"avito_intr/internal/storage"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// pvzCursor указывает на последний ПВЗ предыдущей страницы.
+// Клиенту он отдаётся непрозрачной строкой.
+type pvzCursor struct {
+	Sort      storage.PvzSort `json:"s,omitempty"`
+	Ascending bool            `json:"a,omitempty"`
+	Key       time.Time       `json:"d"`
+	Id        string          `json:"id"`
+}
+
+func encodeCursor(c pvzCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(cursor string) (*pvzCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, storage.ReceptionFailed{Message: "invalid cursor"}
+	}
+	var c pvzCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, storage.ReceptionFailed{Message: "invalid cursor"}
+	}
+	if _, err := parseUUID(c.Id); err != nil {
+		return nil, storage.ReceptionFailed{Message: "invalid cursor"}
+	}
+	if c.Sort == "" {
+		c.Sort = storage.SortByRegistrationDate
+	}
+	return &c, nil
+}
+
+// pvzQuery собирает запрос страницы ПВЗ с фильтрами. Ключ сортировки выбирается
+// в колонку sort_key, чтобы keyset-условие и курсор не зависели от вида сортировки.
+type pvzQuery struct {
+	where []string
+	args  []any
+}
+
+func (q *pvzQuery) arg(v any) string {
+	q.args = append(q.args, v)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+func pvzSort(filter storage.PvzFilter) storage.PvzSort {
+	if filter.Sort == "" {
+		return storage.SortByRegistrationDate
+	}
+	return filter.Sort
+}
+
+// buildPvzQuery без paginate выбирает все подходящие ПВЗ после курсора, Page и Limit тогда не используются.
+func buildPvzQuery(filter storage.PvzFilter, start, end *time.Time, cursor *pvzCursor, paginate bool) (string, []any, error) {
+	q := &pvzQuery{}
+
+	sort := pvzSort(filter)
+	var sortKey, join string
+	switch sort {
+	case storage.SortByRegistrationDate:
+		sortKey = "pvz.registration_date"
+	case storage.SortByActivity:
+		sortKey = "COALESCE(activity.last_reception, pvz.registration_date)"
+		join = `
+LEFT JOIN LATERAL (
+    SELECT MAX(registration_date) AS last_reception
+    FROM receptions
+    WHERE receptions.pvz_id = pvz.id
+) activity ON true`
+	default:
+		return "", nil, storage.ReceptionFailed{Message: "invalid sort " + string(sort)}
+	}
+
+	if filter.City != "" {
+		if !filter.City.Valid() {
+			return "", nil, storage.ReceptionFailed{Message: "invalid city " + string(filter.City)}
+		}
+		q.where = append(q.where, "pvz.city = "+q.arg(string(filter.City)))
+	}
+	if filter.Author != "" {
+		author, err := parseUUID(filter.Author)
+		if err != nil {
+			return "", nil, storage.ReceptionFailed{Message: "invalid author"}
+		}
+		q.where = append(q.where, "pvz.author_id = "+q.arg(author))
+	}
+	if filter.OpenReception != nil {
+		exists := "EXISTS (SELECT 1 FROM receptions WHERE receptions.pvz_id = pvz.id AND receptions.activity)"
+		if !*filter.OpenReception {
+			exists = "NOT " + exists
+		}
+		q.where = append(q.where, exists)
+	}
+	if filter.ProductType != "" {
+		if !storage.ValidProductType(filter.ProductType) {
+			return "", nil, storage.ReceptionFailed{Message: "invalid product type " + filter.ProductType}
+		}
+		cond := `EXISTS (
+    SELECT 1 FROM receptions
+    JOIN products ON products.reception_id = receptions.id
+    WHERE receptions.pvz_id = pvz.id AND products.product_type = ` + q.arg(filter.ProductType)
+		if start != nil {
+			cond += " AND receptions.registration_date >= " + q.arg(*start)
+		}
+		if end != nil {
+			cond += " AND receptions.registration_date <= " + q.arg(*end)
+		}
+		q.where = append(q.where, cond+")")
+	}
+
+	dir, cmp := "DESC", "<"
+	if filter.Ascending {
+		dir, cmp = "ASC", ">"
+	}
+	if cursor != nil {
+		if cursor.Sort != sort || cursor.Ascending != filter.Ascending {
+			return "", nil, storage.ReceptionFailed{Message: "cursor does not match sort order"}
+		}
+		after, err := parseUUID(cursor.Id)
+		if err != nil {
+			return "", nil, err
+		}
+		q.where = append(q.where, fmt.Sprintf("(%s, pvz.id) %s (%s, %s)", sortKey, cmp, q.arg(cursor.Key), q.arg(after)))
+	}
+
+	query := fmt.Sprintf("SELECT pvz.id, pvz.registration_date, pvz.city, pvz.version, %s AS sort_key\nFROM pvz%s", sortKey, join)
+	if len(q.where) > 0 {
+		query += "\nWHERE " + strings.Join(q.where, "\n    AND ")
+	}
+	query += fmt.Sprintf("\nORDER BY sort_key %s, pvz.id %s", dir, dir)
+	if !paginate {
+		return query, q.args, nil
+	}
+	if cursor == nil {
+		query += "\nOFFSET " + q.arg(filter.Limit*(filter.Page-1))
+	}
+	query += "\nLIMIT " + q.arg(filter.Limit+1)
+
+	return query, q.args, nil
+}
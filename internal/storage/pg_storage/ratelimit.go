@@ -0,0 +1,37 @@
+package pg_storage
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"fmt"
+	"time"
+)
+
+// refillTokens - токены ведра b на текущий момент: остаток плюс пополнение за время с прошлого запроса,
+// не больше ёмкости $3
+const refillTokens = "LEAST($3::float8, b.tokens + GREATEST(EXTRACT(EPOCH FROM NOW() - b.updated_at)::float8, 0) * $2::float8)"
+
+// TakeRateLimit пополняет ведро и списывает токен одним UPSERT. SET вычисляется по строке,
+// заблокированной этим запросом, поэтому параллельные запросы к одному ключу с разных
+// экземпляров не теряют списаний.
+func (s *PgStorage) TakeRateLimit(ctx context.Context, key string, rate float64, burst int) (storage.RateLimitResult, error) {
+	var res storage.RateLimitResult
+	err := s.pool.QueryRow(ctx, fmt.Sprintf(`
+INSERT INTO rate_limits AS b (key, tokens, allowed, updated_at) VALUES ($1, $3::float8 - 1, true, NOW())
+ON CONFLICT (key) DO UPDATE SET
+    tokens = %[1]s - CASE WHEN %[1]s >= 1 THEN 1 ELSE 0 END,
+    allowed = %[1]s >= 1,
+    updated_at = NOW()
+RETURNING b.tokens, b.allowed;`, refillTokens), key, rate, burst).Scan(&res.Tokens, &res.Allowed)
+	return res, err
+}
+
+// PurgeRateLimits удаляет вёдра, к которым не обращались дольше olderThan: за это время они
+// наполнились бы до конца, поэтому удаление не меняет лимитов.
+func (s *PgStorage) PurgeRateLimits(ctx context.Context, olderThan time.Duration) (int64, error) {
+	tag, err := s.pool.Exec(ctx, "DELETE FROM rate_limits WHERE updated_at < NOW() - $1 * INTERVAL '1 second';", olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,122 @@
+package pg_storage
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+func (s *PgStorage) GetPvzById(ctx context.Context, pvzId string) (*storage.PvzInfo, error) {
+	id, err := parseUUID(pvzId)
+	if err != nil {
+		return nil, storage.ReceptionFailed{Message: "uuid is not valid"}
+	}
+	var (
+		date    time.Time
+		city    string
+		version int64
+	)
+	err = s.pool.QueryRow(ctx, "SELECT registration_date, city, version FROM pvz WHERE id = $1;", id).Scan(&date, &city, &version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrPvzNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := parseStringFromUUID(id)
+	return &storage.PvzInfo{PvzId: &res, RegistrationDate: &date, City: storage.City(city), Version: version}, nil
+}
+
+func (s *PgStorage) GetOpenReception(ctx context.Context, pvzId string) (*storage.ReceptionInfo, error) {
+	id, err := parseUUID(pvzId)
+	if err != nil {
+		return nil, storage.ReceptionFailed{Message: "uuid is not valid"}
+	}
+	// один запрос, чтобы приёмка и товары были из одного снимка базы
+	q, err := s.pool.Query(ctx, `
+SELECT receptions.id, receptions.registration_date, receptions.version,
+       products.id, products.product_type, products.registration_date
+FROM pvz
+LEFT JOIN receptions ON receptions.pvz_id = pvz.id AND receptions.activity = true
+LEFT JOIN products ON products.reception_id = receptions.id
+WHERE pvz.id = $1
+ORDER BY products.registration_date;`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	var (
+		res   *storage.ReceptionInfo
+		found bool
+	)
+	for q.Next() {
+		var (
+			recId, productId     *[16]byte
+			recDate, productDate *time.Time
+			recVersion           *int64
+			productType          *string
+		)
+		if err := q.Scan(&recId, &recDate, &recVersion, &productId, &productType, &productDate); err != nil {
+			return nil, err
+		}
+		found = true
+		if recId == nil {
+			continue
+		}
+		if res == nil {
+			res = &storage.ReceptionInfo{ReceptionId: parseStringFromUUID(*recId), DateTime: *recDate, PvzId: pvzId,
+				Status: storage.Active, Version: *recVersion, Products: make([]storage.Product, 0)}
+		}
+		if productId != nil {
+			res.Products = append(res.Products, storage.Product{ProductId: parseStringFromUUID(*productId),
+				DateTime: *productDate, ProductType: *productType, ReceptionId: res.ReceptionId})
+		}
+	}
+	if err := q.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, storage.ErrPvzNotFound
+	}
+	return res, nil
+}
+
+func (s *PgStorage) GetPvzStats(ctx context.Context) ([]storage.CityStats, error) {
+	q, err := s.pool.Query(ctx, `
+SELECT
+    pvz.city,
+    COUNT(*),
+    COALESCE(SUM(open.receptions), 0)::bigint
+FROM
+    pvz
+LEFT JOIN LATERAL (
+    SELECT COUNT(*) AS receptions
+    FROM receptions
+    WHERE receptions.pvz_id = pvz.id AND receptions.activity
+) open ON true
+WHERE
+    pvz.city IS NOT NULL
+GROUP BY pvz.city;
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	var res []storage.CityStats
+	for q.Next() {
+		var (
+			city  string
+			stats storage.CityStats
+		)
+		if err := q.Scan(&city, &stats.Pvz, &stats.OpenReceptions); err != nil {
+			return nil, err
+		}
+		stats.City = storage.City(city)
+		res = append(res, stats)
+	}
+	return res, q.Err()
+}
@@ -0,0 +1,131 @@
+package pg_storage
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"fmt"
+	"time"
+)
+
+// StreamPvzInfo передаёт в fn все ПВЗ по фильтру вместе с приёмками и товарами.
+// Строки читаются одним упорядоченным запросом и группируются на лету, так что в памяти
+// держится только текущий ПВЗ. Page и Limit игнорируются, Cursor позволяет продолжить
+// прерванную выгрузку.
+func (s *PgStorage) StreamPvzInfo(ctx context.Context, filter storage.PvzFilter, fn func(storage.PvzInfo) error) error {
+	start, err := parseDateBound(filter.StartDate)
+	if err != nil {
+		return err
+	}
+	end, err := parseDateBound(filter.EndDate)
+	if err != nil {
+		return err
+	}
+	cursor, err := decodeCursor(filter.Cursor)
+	if err != nil {
+		return err
+	}
+	pvzQuery, args, err := buildPvzQuery(filter, start, end, cursor, false)
+	if err != nil {
+		return err
+	}
+
+	dir := "DESC"
+	if filter.Ascending {
+		dir = "ASC"
+	}
+	args = append(args, start, end)
+	query := fmt.Sprintf(`
+WITH pvz_list AS (
+%s
+)
+SELECT
+    pvz_list.id,
+    pvz_list.registration_date,
+    pvz_list.city,
+    pvz_list.version,
+    receptions.id,
+    receptions.registration_date,
+    receptions.activity,
+    receptions.version,
+    products.id,
+    products.product_type,
+    products.registration_date
+FROM
+    pvz_list
+LEFT JOIN
+    receptions
+    ON receptions.pvz_id = pvz_list.id
+    AND ($%d::timestamp IS NULL OR receptions.registration_date >= $%d)
+    AND ($%d::timestamp IS NULL OR receptions.registration_date <= $%d)
+LEFT JOIN
+    products
+    ON products.reception_id = receptions.id
+ORDER BY
+    pvz_list.sort_key %s,
+    pvz_list.id %s,
+    receptions.registration_date DESC,
+    receptions.id,
+    products.registration_date DESC;
+`, pvzQuery, len(args)-1, len(args)-1, len(args), len(args), dir, dir)
+
+	q, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	var current *storage.PvzInfo
+	for q.Next() {
+		var (
+			pvzId                [16]byte
+			pvzDate              time.Time
+			city                 string
+			pvzVersion           int64
+			recVersion           *int64
+			recId, productId     *[16]byte
+			recDate, productDate *time.Time
+			activity             *bool
+			productType          *string
+		)
+		if err := q.Scan(&pvzId, &pvzDate, &city, &pvzVersion, &recId, &recDate, &activity, &recVersion, &productId, &productType, &productDate); err != nil {
+			return err
+		}
+
+		id := parseStringFromUUID(pvzId)
+		if current == nil || *current.PvzId != id {
+			if current != nil {
+				if err := fn(*current); err != nil {
+					return err
+				}
+			}
+			current = &storage.PvzInfo{PvzId: &id, RegistrationDate: &pvzDate, City: storage.City(city),
+				Version: pvzVersion, Receptions: make([]storage.ReceptionInfo, 0)}
+		}
+		if recId == nil {
+			continue
+		}
+
+		rec := parseStringFromUUID(*recId)
+		if n := len(current.Receptions); n == 0 || current.Receptions[n-1].ReceptionId != rec {
+			status := storage.Inactive
+			if *activity {
+				status = storage.Active
+			}
+			current.Receptions = append(current.Receptions, storage.ReceptionInfo{ReceptionId: rec,
+				DateTime: *recDate, PvzId: id, Status: status, Version: *recVersion, Products: make([]storage.Product, 0)})
+		}
+		if productId == nil {
+			continue
+		}
+		last := &current.Receptions[len(current.Receptions)-1]
+		last.Products = append(last.Products, storage.Product{ProductId: parseStringFromUUID(*productId),
+			DateTime: *productDate, ProductType: *productType, ReceptionId: rec})
+	}
+	if err := q.Err(); err != nil {
+		return err
+	}
+	if current != nil {
+		return fn(*current)
+	}
+	return nil
+}
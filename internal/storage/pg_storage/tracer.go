@@ -0,0 +1,62 @@
+package pg_storage
+
+import (
+	"avito_intr/internal/requestid"
+	"avito_intr/internal/tracing"
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// queryTracer пишет спан на каждый запрос и на ожидание соединения из пула,
+// родительский спан берётся из контекста, переданного в хранилище.
+// Ошибки запросов пишутся в логгер запроса, чтобы их можно было найти по request_id.
+type queryTracer struct{}
+
+type querySQLKey struct{}
+
+var (
+	_ pgx.QueryTracer       = queryTracer{}
+	_ pgxpool.AcquireTracer = queryTracer{}
+)
+
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, _ = tracing.Tracer().Start(ctx, "postgres.query",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBQueryText(data.SQL)),
+	)
+	return context.WithValue(ctx, querySQLKey{}, data.SQL)
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+		sql, _ := ctx.Value(querySQLKey{}).(string)
+		requestid.Logger(ctx, zap.NewNop()).Warn("postgres query failed",
+			zap.String("sql", sql), zap.Error(data.Err))
+	} else {
+		span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	}
+	span.End()
+}
+
+func (queryTracer) TraceAcquireStart(ctx context.Context, _ *pgxpool.Pool, _ pgxpool.TraceAcquireStartData) context.Context {
+	ctx, _ = tracing.Tracer().Start(ctx, "postgres.acquire", trace.WithSpanKind(trace.SpanKindInternal))
+	return ctx
+}
+
+func (queryTracer) TraceAcquireEnd(ctx context.Context, _ *pgxpool.Pool, data pgxpool.TraceAcquireEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+	}
+	span.End()
+}
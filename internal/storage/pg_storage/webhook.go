@@ -0,0 +1,195 @@
+package pg_storage
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+const deliveryColumns = `d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at,
+    d.last_status_code, d.last_error, d.created_at, d.delivered_at`
+
+// checkWebhookAdmin допускает к подпискам только зарегистрированных модераторов: у токенов из /dummyLogin
+// нет автора, и роль по ним не проверить
+func (s *PgStorage) checkWebhookAdmin(ctx context.Context, author string) error {
+	if author == "" {
+		return storage.LoginFailed{Message: "user has no permission"}
+	}
+	return s.checkModerator(ctx, author)
+}
+
+func (s *PgStorage) CreateWebhook(ctx context.Context, author string, hook storage.Webhook) (*storage.Webhook, error) {
+	if err := s.checkWebhookAdmin(ctx, author); err != nil {
+		return nil, err
+	}
+	var id [16]byte
+	err := s.pool.QueryRow(ctx, `
+INSERT INTO webhooks (author_id, url, events, secret) VALUES ($1, $2, $3, $4)
+RETURNING id, created_at;`, author, hook.URL, hook.Events, hook.Secret).Scan(&id, &hook.CreatedAt)
+	if err != nil {
+		return nil, domainError(err)
+	}
+	hook.WebhookId = parseStringFromUUID(id)
+	return &hook, nil
+}
+
+func (s *PgStorage) ListWebhooks(ctx context.Context, author string) ([]storage.Webhook, error) {
+	if err := s.checkWebhookAdmin(ctx, author); err != nil {
+		return nil, err
+	}
+	q, err := s.pool.Query(ctx, "SELECT id, url, events, secret, created_at FROM webhooks ORDER BY created_at, id;")
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	res := make([]storage.Webhook, 0)
+	for q.Next() {
+		var (
+			id   [16]byte
+			hook storage.Webhook
+		)
+		if err := q.Scan(&id, &hook.URL, &hook.Events, &hook.Secret, &hook.CreatedAt); err != nil {
+			return nil, err
+		}
+		hook.WebhookId = parseStringFromUUID(id)
+		res = append(res, hook)
+	}
+	return res, q.Err()
+}
+
+// DeleteWebhook удаляет подписку вместе с журналом её доставок
+func (s *PgStorage) DeleteWebhook(ctx context.Context, author, webhookId string) error {
+	if err := s.checkWebhookAdmin(ctx, author); err != nil {
+		return err
+	}
+	if !IsUUID(webhookId) {
+		return storage.ErrWebhookNotFound
+	}
+	tag, err := s.pool.Exec(ctx, "DELETE FROM webhooks WHERE id = $1;", webhookId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// ListWebhookDeliveries возвращает журнал доставок подписки, начиная с последних
+func (s *PgStorage) ListWebhookDeliveries(ctx context.Context, author, webhookId string, filter storage.DeliveryFilter) ([]storage.WebhookDelivery, error) {
+	if err := s.checkWebhookAdmin(ctx, author); err != nil {
+		return nil, err
+	}
+	if !IsUUID(webhookId) {
+		return nil, storage.ErrWebhookNotFound
+	}
+	var exists bool
+	if err := s.pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1);", webhookId).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, storage.ErrWebhookNotFound
+	}
+
+	q, err := s.pool.Query(ctx, fmt.Sprintf(`
+SELECT %s
+FROM webhook_deliveries d
+WHERE d.webhook_id = $1 AND ($2 = '' OR d.status = $2)
+ORDER BY d.created_at DESC, d.id
+LIMIT $3;`, deliveryColumns), webhookId, string(filter.Status), filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(q, func(row pgx.CollectableRow) (storage.WebhookDelivery, error) {
+		return scanDelivery(row, false)
+	})
+}
+
+func (s *PgStorage) EnqueueEvent(ctx context.Context, event storage.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = s.pool.Exec(ctx, `
+INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+SELECT id, $1, $2, $3 FROM webhooks WHERE $2 = ANY (events)
+ON CONFLICT (webhook_id, event_id) DO NOTHING;`, event.EventId, event.Type, payload)
+	return err
+}
+
+// ClaimWebhookDeliveries блокирует строки с SKIP LOCKED, поэтому несколько экземпляров сервиса
+// не получат одну и ту же доставку.
+func (s *PgStorage) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]storage.WebhookDelivery, error) {
+	q, err := s.pool.Query(ctx, fmt.Sprintf(`
+WITH due AS (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE webhook_deliveries d
+SET next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+FROM due, webhooks w
+WHERE d.id = due.id AND w.id = d.webhook_id
+RETURNING %s, w.url, w.secret;`, deliveryColumns), limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(q, func(row pgx.CollectableRow) (storage.WebhookDelivery, error) {
+		return scanDelivery(row, true)
+	})
+}
+
+// SaveWebhookAttempt записывает результат попытки. Доставка в статусе pending будет выдана снова через RetryIn.
+func (s *PgStorage) SaveWebhookAttempt(ctx context.Context, attempt storage.WebhookAttempt) error {
+	var statusCode, lastError any
+	if attempt.StatusCode != 0 {
+		statusCode = attempt.StatusCode
+	}
+	if attempt.Error != "" {
+		lastError = attempt.Error
+	}
+	_, err := s.pool.Exec(ctx, `
+UPDATE webhook_deliveries
+SET status           = $2,
+    attempts         = attempts + 1,
+    next_attempt_at  = NOW() + $3 * INTERVAL '1 second',
+    last_status_code = $4,
+    last_error       = $5,
+    delivered_at     = CASE WHEN $2 = 'delivered' THEN NOW() END
+WHERE id = $1;`, attempt.DeliveryId, string(attempt.Status), attempt.RetryIn.Seconds(), statusCode, lastError)
+	return err
+}
+
+// scanDelivery читает колонки deliveryColumns, а с withTarget - ещё url и secret подписки
+func scanDelivery(row pgx.CollectableRow, withTarget bool) (storage.WebhookDelivery, error) {
+	var (
+		d                    storage.WebhookDelivery
+		id, webhookId, event [16]byte
+		status               string
+		statusCode           *int
+		lastError            *string
+	)
+	dest := []any{&id, &webhookId, &event, &d.EventType, &d.Payload, &status, &d.Attempts, &d.NextAttemptAt,
+		&statusCode, &lastError, &d.CreatedAt, &d.DeliveredAt}
+	if withTarget {
+		dest = append(dest, &d.URL, &d.Secret)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return d, err
+	}
+	d.DeliveryId, d.WebhookId, d.EventId = parseStringFromUUID(id), parseStringFromUUID(webhookId), parseStringFromUUID(event)
+	d.Status = storage.DeliveryStatus(status)
+	if statusCode != nil {
+		d.LastStatusCode = *statusCode
+	}
+	if lastError != nil {
+		d.LastError = *lastError
+	}
+	return d, nil
+}
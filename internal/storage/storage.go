@@ -1,20 +1,85 @@
 package storage
 
-import "time"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
 
 type Storage interface {
-	Migrate() error
-	CreateUser(email, password string, roles []Role) (*UserInfo, error)
-	LoginUser(email, password string) (*UserInfo, error)
-	CreatePvz(author string, params PvzInfo) (*PvzInfo, error)
-	GetPvzInfo(startDate, endDate string, page, limit int) ([]PvzInfo, error)
-	CloseLastReception(pvzId string) (*ReceptionInfo, error)
-	OpenReception(author string, pvz string) (*ReceptionInfo, error)
-	AddProduct(uuid, author, product string) (*Product, error)
-	DeleteLastProduct(uuid string) error
-	GetOnlyPvzList() ([]PvzInfo, error)
+	Migrate(ctx context.Context) error
+	CreateUser(ctx context.Context, email, password string, roles []Role) (*UserInfo, error)
+	LoginUser(ctx context.Context, email, password string) (*UserInfo, error)
+	CreatePvz(ctx context.Context, author string, params PvzInfo) (*PvzInfo, error)
+	GetPvzInfo(ctx context.Context, filter PvzFilter) (*PvzPage, error)
+	GetPvzList(ctx context.Context, filter PvzFilter) (*PvzPage, error)
+	// Изменяющие методы принимают ожидаемую версию изменяемого ресурса: ПВЗ для OpenReception,
+	// открытой приёмки для остальных. 0 - без проверки, при расхождении возвращается ErrVersionMismatch.
+	CloseLastReception(ctx context.Context, pvzId string, expectedVersion int64) (*ReceptionInfo, error)
+	OpenReception(ctx context.Context, author string, pvz string, expectedVersion int64) (*ReceptionInfo, error)
+	AddProduct(ctx context.Context, uuid, author, product string, expectedVersion int64) (*Product, error)
+	// DeleteLastProduct возвращает новую версию приёмки
+	DeleteLastProduct(ctx context.Context, uuid string, expectedVersion int64) (int64, error)
+	GetOnlyPvzList(ctx context.Context) ([]PvzInfo, error)
+	StreamPvzInfo(ctx context.Context, filter PvzFilter, fn func(PvzInfo) error) error
+	ExportReceptions(ctx context.Context, filter ExportFilter, fn func(ExportRow) error) error
+	GetAnalytics(ctx context.Context, filter AnalyticsFilter) (*Analytics, error)
+	GetPvzById(ctx context.Context, pvzId string) (*PvzInfo, error)
+	// GetOpenReception возвращает открытую приёмку ПВЗ с товарами в порядке добавления, nil - открытой приёмки нет
+	GetOpenReception(ctx context.Context, pvzId string) (*ReceptionInfo, error)
+	GetPvzStats(ctx context.Context) ([]CityStats, error)
+	BeginIdempotent(ctx context.Context, caller, key, fingerprint string, ttl time.Duration) (*IdempotentResponse, error)
+	CompleteIdempotent(ctx context.Context, caller, key string, resp IdempotentResponse) error
+	AbortIdempotent(ctx context.Context, caller, key string) error
+	PurgeIdempotencyKeys(ctx context.Context) (int64, error)
+	// Подписками на webhooks управляют только модераторы, author проверяется как в CreatePvz
+	CreateWebhook(ctx context.Context, author string, hook Webhook) (*Webhook, error)
+	ListWebhooks(ctx context.Context, author string) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, author, webhookId string) error
+	ListWebhookDeliveries(ctx context.Context, author, webhookId string, filter DeliveryFilter) ([]WebhookDelivery, error)
+	// EnqueueEvent ставит событие в очередь доставки каждой подписке на его тип
+	EnqueueEvent(ctx context.Context, event Event) error
+	// ClaimWebhookDeliveries выдаёт на отправку доставки, срок попытки которых наступил, и откладывает
+	// их на lease: если отправитель не сохранит результат, доставка будет выдана снова.
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error)
+	SaveWebhookAttempt(ctx context.Context, attempt WebhookAttempt) error
+	// PublishOutbox выбирает неопубликованные события из outbox (их пишут изменяющие методы в транзакции
+	// изменения), по порядку передаёт их handle и возвращает, сколько сообщений передано handle.
+	// Сообщения одного ПВЗ обрабатываются по порядку и одним экземпляром сервиса: пока сообщение
+	// не опубликовано, следующие сообщения его ПВЗ не выдаются.
+	PublishOutbox(ctx context.Context, limit int, handle OutboxHandler) (int, error)
+	// PurgeOutbox удаляет сообщения, опубликованные раньше чем olderThan назад
+	PurgeOutbox(ctx context.Context, olderThan time.Duration) (int64, error)
+	// AppendFeed добавляет событие в ленту для живых подписчиков и будит ListenFeed всех экземпляров.
+	// Seq назначается в порядке фиксации, повтор события с тем же EventId игнорируется.
+	AppendFeed(ctx context.Context, event Event) error
+	ReadFeed(ctx context.Context, filter FeedFilter) ([]FeedEvent, error)
+	// LastFeedSeq возвращает Seq последнего события ленты, 0 - лента пуста
+	LastFeedSeq(ctx context.Context) (int64, error)
+	// ListenFeed вызывает notify после каждого AppendFeed, пока не отменён ctx или не оборвалось соединение
+	ListenFeed(ctx context.Context, notify func()) error
+	PurgeFeed(ctx context.Context, olderThan time.Duration) (int64, error)
+	// TakeRateLimit берёт токен из ведра key ёмкостью burst, которое пополняется на rate токенов в секунду.
+	// Вёдра общие для всех экземпляров сервиса.
+	TakeRateLimit(ctx context.Context, key string, rate float64, burst int) (RateLimitResult, error)
+	// PurgeRateLimits удаляет вёдра, к которым не обращались дольше olderThan
+	PurgeRateLimits(ctx context.Context, olderThan time.Duration) (int64, error)
 }
 
+// ErrVersionMismatch - ресурс изменился после того, как клиент получил его версию
+var ErrVersionMismatch = errors.New("resource version does not match")
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+var (
+	// ErrIdempotencyKeyInUse - запрос с тем же ключом ещё выполняется
+	ErrIdempotencyKeyInUse = errors.New("idempotency key is in use by a request in progress")
+	// ErrIdempotencyKeyReused - ключ уже использован для запроса с другим содержимым
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different request")
+)
+
 type LoginFailed struct{ Message string }
 
 func (e LoginFailed) Error() string {
@@ -27,6 +92,12 @@ func (e ReceptionFailed) Error() string {
 	return "Operation failed: " + e.Message
 }
 
+// ErrReceptionAlreadyOpen - в ПВЗ уже есть открытая приёмка
+var ErrReceptionAlreadyOpen = ReceptionFailed{Message: "opened reception already exists"}
+
+// ErrPvzNotFound - ПВЗ с таким идентификатором нет
+var ErrPvzNotFound = ReceptionFailed{Message: "pvz not found"}
+
 type Role string
 
 const (
@@ -42,6 +113,30 @@ const (
 	Kazan  City = "Казань"
 )
 
+var Cities = []City{Moscow, SPB, Kazan}
+
+func (c City) Valid() bool {
+	switch c {
+	case Moscow, SPB, Kazan:
+		return true
+	}
+	return false
+}
+
+const (
+	Electronics = "электроника"
+	Clothes     = "одежда"
+	Shoes       = "обувь"
+)
+
+func ValidProductType(productType string) bool {
+	switch productType {
+	case Electronics, Clothes, Shoes:
+		return true
+	}
+	return false
+}
+
 type Status string
 
 const (
@@ -59,15 +154,18 @@ type PvzInfo struct {
 	PvzId            *string         `json:"id"`
 	RegistrationDate *time.Time      `json:"registrationDate"`
 	City             City            `json:"city"`
-	Receptions       []ReceptionInfo `json:"receptions"`
+	Version          int64           `json:"version"`
+	Receptions       []ReceptionInfo `json:"receptions,omitempty"`
 }
 
 type ReceptionInfo struct {
-	ReceptionId string    `json:"id"`
-	DateTime    time.Time `json:"dateTime"`
-	PvzId       string    `json:"pvzId"`
-	Status      Status    `json:"status"`
-	Products    []Product `json:"products"`
+	ReceptionId string     `json:"id"`
+	DateTime    time.Time  `json:"dateTime"`
+	PvzId       string     `json:"pvzId"`
+	Status      Status     `json:"status"`
+	ClosedAt    *time.Time `json:"closedAt,omitempty"`
+	Version     int64      `json:"version"`
+	Products    []Product  `json:"products,omitempty"`
 }
 
 type Product struct {
@@ -75,4 +173,231 @@ type Product struct {
 	DateTime    time.Time `json:"dateTime"`
 	ProductType string    `json:"type"`
 	ReceptionId string    `json:"receptionId"`
+	// ReceptionVersion - версия приёмки после добавления или удаления товара, в составе приёмки не заполняется
+	ReceptionVersion int64 `json:"receptionVersion,omitempty"`
+}
+
+type PvzSort string
+
+const (
+	SortByRegistrationDate PvzSort = "registrationDate"
+	SortByActivity         PvzSort = "activity"
+)
+
+// PvzFilter задаёт выборку ПВЗ. Если указан Cursor, Page игнорируется.
+// StartDate и EndDate ограничивают приёмки, остальные поля отбирают сами ПВЗ.
+type PvzFilter struct {
+	StartDate     string
+	EndDate       string
+	Page          int
+	Limit         int
+	Cursor        string
+	City          City
+	OpenReception *bool
+	ProductType   string
+	Author        string
+	Sort          PvzSort
+	Ascending     bool
+}
+
+type PvzPage struct {
+	Items      []PvzInfo
+	NextCursor string
+}
+
+type ExportFilter struct {
+	StartDate string
+	EndDate   string
+	City      City
+	PvzId     string
+}
+
+// ExportRow - одна строка выгрузки: товар вместе с его приёмкой и ПВЗ.
+// У приёмки без товаров поля товара пустые.
+type ExportRow struct {
+	PvzId           string
+	City            City
+	ReceptionId     string
+	ReceptionDate   time.Time
+	ReceptionStatus Status
+	ProductId       string
+	ProductType     string
+	ProductDate     *time.Time
+}
+
+type AnalyticsFilter struct {
+	StartDate string
+	EndDate   string
+	City      City
+}
+
+// DailyProducts - число принятых товаров одного типа за день. У строк по городу PvzId пустой.
+type DailyProducts struct {
+	Day         time.Time
+	PvzId       string
+	City        City
+	ProductType string
+	Count       int
+}
+
+// ReceptionDurations считается по закрытым приёмкам (от открытия до закрытия).
+type ReceptionDurations struct {
+	Closed  int
+	Average time.Duration
+	P95     time.Duration
+}
+
+type EmployeeReceptions struct {
+	EmployeeId string
+	Email      string
+	Receptions int
+}
+
+type Analytics struct {
+	ProductsPerPvz        []DailyProducts
+	ProductsPerCity       []DailyProducts
+	Durations             ReceptionDurations
+	ReceptionsPerEmployee []EmployeeReceptions
+}
+
+type CityStats struct {
+	City           City
+	Pvz            int
+	OpenReceptions int
+}
+
+// IdempotentResponse - сохранённый первый ответ на запрос с ключом идемпотентности.
+// Для HTTP Status - код ответа, для gRPC - код статуса.
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	// Headers - заголовки ответа, которые нужно вернуть и при повторе (ETag, Location)
+	Headers map[string]string
+	Body    []byte
+}
+
+// Типы событий жизненного цикла ПВЗ и приёмок, на которые подписываются webhooks
+const (
+	EventPvzCreated      = "pvz.created"
+	EventReceptionOpened = "reception.opened"
+	EventProductAdded    = "product.added"
+	EventProductDeleted  = "product.deleted"
+	EventReceptionClosed = "reception.closed"
+)
+
+var EventTypes = []string{EventPvzCreated, EventReceptionOpened, EventProductAdded, EventProductDeleted, EventReceptionClosed}
+
+func ValidEventType(eventType string) bool {
+	switch eventType {
+	case EventPvzCreated, EventReceptionOpened, EventProductAdded, EventProductDeleted, EventReceptionClosed:
+		return true
+	}
+	return false
+}
+
+// Event - событие в том виде, в каком его получают подписчики
+type Event struct {
+	EventId    string          `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	PvzId      string          `json:"pvzId"`
+	Data       json.RawMessage `json:"data"`
+}
+
+func NewEvent(eventType, pvzId string, data any) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{EventId: uuid.NewString(), Type: eventType, OccurredAt: time.Now().UTC(), PvzId: pvzId, Data: raw}, nil
+}
+
+// FeedEvent - событие ленты. Seq растёт в порядке, в котором события попали в ленту, и служит id для SSE.
+type FeedEvent struct {
+	Seq   int64
+	City  City
+	Event Event
+}
+
+// FeedFilter отбирает события ленты с After < Seq <= Until (0 - без верхней границы)
+type FeedFilter struct {
+	After int64
+	Until int64
+	PvzId string
+	City  City
+	Limit int
+}
+
+// RateLimitResult - состояние ведра после попытки взять токен
+type RateLimitResult struct {
+	Allowed bool
+	// Tokens - сколько токенов осталось в ведре
+	Tokens float64
+}
+
+// OutboxMessage - событие из outbox. Id растёт в порядке записи событий.
+type OutboxMessage struct {
+	Id    int64
+	PvzId string
+	Event Event
+	// Attempts - сколько раз публикация уже не удалась
+	Attempts int
+}
+
+// OutboxHandler публикует сообщение. При ошибке сообщение и следующие сообщения его ПВЗ
+// будут выданы снова через retryIn.
+type OutboxHandler func(ctx context.Context, msg OutboxMessage) (retryIn time.Duration, err error)
+
+type Webhook struct {
+	WebhookId string
+	URL       string
+	Events    []string
+	// Secret подписывает доставки, наружу не отдаётся
+	Secret    string
+	CreatedAt time.Time
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead - попытки исчерпаны, доставка больше не повторяется
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+type WebhookDelivery struct {
+	DeliveryId string
+	WebhookId  string
+	EventId    string
+	EventType  string
+	// Payload - тело запроса к подписчику, подпись считается по нему
+	Payload        []byte
+	Status         DeliveryStatus
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+	// URL и Secret подписки заполняются только у доставок из ClaimWebhookDeliveries
+	URL    string
+	Secret string
+}
+
+// WebhookAttempt - результат одной попытки доставки
+type WebhookAttempt struct {
+	DeliveryId string
+	Status     DeliveryStatus
+	// StatusCode - код ответа подписчика, 0 - ответа не было
+	StatusCode int
+	Error      string
+	// RetryIn - через сколько повторить доставку, оставшуюся в статусе pending
+	RetryIn time.Duration
+}
+
+// DeliveryFilter отбирает доставки для журнала. Пустой Status - все доставки.
+type DeliveryFilter struct {
+	Status DeliveryStatus
+	Limit  int
 }
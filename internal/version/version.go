@@ -0,0 +1,45 @@
+package version
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// Version задаётся при сборке:
+//
+//	go build -ldflags "-X avito_intr/internal/version.Version=1.4.0" ./cmd/main.go
+var Version = ""
+
+var fromBuild = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	revision, dirty := "", false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "dev"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if dirty {
+		revision += "-dirty"
+	}
+	return revision
+})
+
+// String возвращает версию сборки: из -ldflags, иначе коммит из debug.BuildInfo, иначе "dev"
+func String() string {
+	if Version != "" {
+		return Version
+	}
+	return fromBuild()
+}
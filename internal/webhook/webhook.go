@@ -0,0 +1,249 @@
+package webhook
+
+import (
+	"avito_intr/internal/storage"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SignatureHeader содержит время отправки и HMAC-SHA256 тела: t=<unix>,v1=<hex>.
+	// Подписывается строка "<unix>.<тело>" секретом подписки.
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	// DeliveryHeader не меняется между повторами одной доставки, по нему подписчик отбрасывает дубли
+	DeliveryHeader = "X-Webhook-Delivery"
+
+	// responseLimit - сколько байт ответа подписчика читается, остальное отбрасывается
+	responseLimit = 4 << 10
+	// errorLimit ограничивает текст ошибки, сохраняемый в журнал доставок
+	errorLimit = 500
+
+	defaultBatchSize = 20
+)
+
+// Store - часть storage.Storage, в которой лежат очередь доставок и их журнал
+type Store interface {
+	EnqueueEvent(ctx context.Context, event storage.Event) error
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]storage.WebhookDelivery, error)
+	SaveWebhookAttempt(ctx context.Context, attempt storage.WebhookAttempt) error
+}
+
+type Config struct {
+	// MaxAttempts - после стольких неудачных попыток доставка уходит в dead
+	MaxAttempts int
+	// Timeout ограничивает один запрос к подписчику
+	Timeout time.Duration
+	// InitialBackoff - пауза перед первым повтором, дальше она удваивается до MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// BatchSize - сколько доставок отправляется параллельно за один проход
+	BatchSize int
+}
+
+var deliveriesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "webhook_deliveries_total",
+		Help: "Webhook delivery attempts by event type and result: delivered, failed, dead",
+	},
+	[]string{"event", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(deliveriesTotal)
+}
+
+// Sign возвращает значение заголовка SignatureHeader для тела, отправленного в момент at
+func Sign(secret string, at time.Time, body []byte) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + ts + ",v1=" + signature(secret, ts, body)
+}
+
+func signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify проверяет подпись на стороне подписчика. Подпись старше tolerance отклоняется,
+// чтобы перехваченный запрос нельзя было повторить позже.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return errors.New("malformed signature header")
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return errors.New("signature timestamp is outside the tolerance")
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, ts, body))) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+// Dispatcher ставит события в очередь и доставляет их подписчикам
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+	cfg    Config
+	logger *zap.Logger
+}
+
+func NewDispatcher(store Store, cfg Config, logger *zap.Logger) *Dispatcher {
+	client := &http.Client{
+		Timeout: cfg.Timeout,
+		// перенаправление считается неудачной доставкой: подписка должна указывать на конечный адрес
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	return &Dispatcher{store: store, client: client, cfg: cfg, logger: logger}
+}
+
+// Publish ставит событие в очередь доставки всем подписанным на него webhooks
+func (d *Dispatcher) Publish(ctx context.Context, event storage.Event) error {
+	return d.store.EnqueueEvent(ctx, event)
+}
+
+// Run отправляет доставки, срок которых наступил, пока не отменён ctx.
+// Если проход выбрал полную пачку, следующий начинается сразу.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := d.deliverDue(ctx)
+		if err != nil && ctx.Err() == nil {
+			d.logger.Warn("failed to deliver webhooks", zap.Error(err))
+		}
+		if n == d.cfg.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverDue отправляет одну пачку доставок и возвращает её размер
+func (d *Dispatcher) deliverDue(ctx context.Context) (int, error) {
+	// аренда переживает запрос с запасом, иначе доставку успеет забрать другой экземпляр
+	deliveries, err := d.store.ClaimWebhookDeliveries(ctx, d.cfg.BatchSize, 2*d.cfg.Timeout)
+	if err != nil {
+		return 0, err
+	}
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			attempt, ok := d.deliver(ctx, delivery)
+			if !ok {
+				return
+			}
+			// результат сохраняется и при остановке сервиса, чтобы успешная доставка не ушла повторно
+			if err := d.store.SaveWebhookAttempt(context.WithoutCancel(ctx), attempt); err != nil {
+				d.logger.Error("failed to save webhook attempt", zap.String("delivery_id", delivery.DeliveryId), zap.Error(err))
+			}
+		}()
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+// deliver отправляет доставку и решает, что с ней делать дальше. ok = false - попытку прервала
+// остановка сервиса, она не засчитывается и доставка будет выдана снова после аренды.
+func (d *Dispatcher) deliver(ctx context.Context, delivery storage.WebhookDelivery) (attempt storage.WebhookAttempt, ok bool) {
+	attempt = storage.WebhookAttempt{DeliveryId: delivery.DeliveryId, Status: storage.DeliveryDelivered}
+	status, err := d.send(ctx, delivery)
+	attempt.StatusCode = status
+	if err == nil {
+		deliveriesTotal.WithLabelValues(delivery.EventType, "delivered").Inc()
+		return attempt, true
+	}
+	if ctx.Err() != nil {
+		return attempt, false
+	}
+
+	attempt.Error = err.Error()
+	if len(attempt.Error) > errorLimit {
+		attempt.Error = attempt.Error[:errorLimit]
+	}
+	logger := d.logger.With(zap.String("delivery_id", delivery.DeliveryId), zap.String("webhook_id", delivery.WebhookId),
+		zap.String("event", delivery.EventType), zap.Int("attempt", delivery.Attempts+1), zap.Error(err))
+	if delivery.Attempts+1 >= d.cfg.MaxAttempts {
+		attempt.Status = storage.DeliveryDead
+		deliveriesTotal.WithLabelValues(delivery.EventType, "dead").Inc()
+		logger.Warn("webhook delivery dead-lettered")
+		return attempt, true
+	}
+	attempt.Status = storage.DeliveryPending
+	attempt.RetryIn = d.backoff(delivery.Attempts + 1)
+	deliveriesTotal.WithLabelValues(delivery.EventType, "failed").Inc()
+	logger.Info("webhook delivery failed, will retry", zap.Duration("retry_in", attempt.RetryIn))
+	return attempt, true
+}
+
+// send отправляет доставку и возвращает код ответа. Успехом считается только ответ 2xx.
+func (d *Dispatcher) send(ctx context.Context, delivery storage.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.DeliveryId)
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, time.Now(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// дочитываем тело, чтобы соединение вернулось в пул
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, responseLimit))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff - пауза перед повтором после attempt неудачных попыток: InitialBackoff * 2^(attempt-1),
+// не больше MaxBackoff, плюс до 10% случайного разброса, чтобы повторы к одному подписчику не шли разом.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.cfg.MaxBackoff
+	if shift := attempt - 1; shift < 32 {
+		if next := d.cfg.InitialBackoff << shift; next > 0 && next < delay {
+			delay = next
+		}
+	}
+	return delay + rand.N(delay/10+1)
+}
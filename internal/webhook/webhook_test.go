@@ -0,0 +1,170 @@
+package webhook
+
+import (
+	"avito_intr/internal/storage"
+	"context"
+	"encoding/json"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryStore - очередь доставок в памяти. Доставка выдаётся снова сразу, без учёта RetryIn.
+type memoryStore struct {
+	mu         sync.Mutex
+	url        string
+	secret     string
+	deliveries []*storage.WebhookDelivery
+	attempts   []storage.WebhookAttempt
+}
+
+func (m *memoryStore) EnqueueEvent(ctx context.Context, event storage.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries = append(m.deliveries, &storage.WebhookDelivery{DeliveryId: event.EventId + "-d", WebhookId: "hook",
+		EventId: event.EventId, EventType: event.Type, Payload: payload, Status: storage.DeliveryPending, URL: m.url, Secret: m.secret})
+	return nil
+}
+
+func (m *memoryStore) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]storage.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var res []storage.WebhookDelivery
+	for _, d := range m.deliveries {
+		if d.Status == storage.DeliveryPending && len(res) < limit {
+			res = append(res, *d)
+		}
+	}
+	return res, nil
+}
+
+func (m *memoryStore) SaveWebhookAttempt(ctx context.Context, attempt storage.WebhookAttempt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.attempts = append(m.attempts, attempt)
+	for _, d := range m.deliveries {
+		if d.DeliveryId == attempt.DeliveryId {
+			d.Status = attempt.Status
+			d.Attempts++
+			d.LastStatusCode = attempt.StatusCode
+		}
+	}
+	return nil
+}
+
+func TestDispatcherDelivers(t *testing.T) {
+	const secret = "0123456789abcdef"
+	received := make(chan *http.Request, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify(secret, r.Header.Get(SignatureHeader), body, time.Now(), time.Minute); err != nil {
+			t.Errorf("signature: %v", err)
+		}
+		var event storage.Event
+		if err := json.Unmarshal(body, &event); err != nil || event.Type != storage.EventPvzCreated {
+			t.Errorf("body = %s", body)
+		}
+		received <- r
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	store := &memoryStore{url: receiver.URL, secret: secret}
+	d := NewDispatcher(store, Config{MaxAttempts: 3, Timeout: time.Second, InitialBackoff: time.Second, MaxBackoff: time.Minute}, zap.NewNop())
+	event, err := storage.NewEvent(storage.EventPvzCreated, "11111111-1111-1111-1111-111111111111", storage.PvzInfo{City: "Москва"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := d.deliverDue(context.Background()); err != nil || n != 1 {
+		t.Fatalf("deliverDue = %d, %v", n, err)
+	}
+
+	r := <-received
+	if r.Header.Get(EventHeader) != storage.EventPvzCreated || r.Header.Get(DeliveryHeader) != event.EventId+"-d" {
+		t.Errorf("headers = %v", r.Header)
+	}
+	if got := store.attempts; len(got) != 1 || got[0].Status != storage.DeliveryDelivered || got[0].StatusCode != http.StatusNoContent {
+		t.Errorf("attempts = %+v", got)
+	}
+}
+
+func TestDispatcherRetriesAndDeadLetters(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	store := &memoryStore{url: receiver.URL, secret: "0123456789abcdef"}
+	cfg := Config{MaxAttempts: 3, Timeout: time.Second, InitialBackoff: 10 * time.Second, MaxBackoff: 15 * time.Second}
+	d := NewDispatcher(store, cfg, zap.NewNop())
+	event, _ := storage.NewEvent(storage.EventReceptionClosed, "11111111-1111-1111-1111-111111111111", storage.ReceptionInfo{ReceptionId: "r"})
+	if err := d.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := d.deliverDue(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := int(calls.Load()); got != cfg.MaxAttempts {
+		t.Errorf("receiver got %d requests, want %d", got, cfg.MaxAttempts)
+	}
+	want := []storage.DeliveryStatus{storage.DeliveryPending, storage.DeliveryPending, storage.DeliveryDead}
+	if len(store.attempts) != len(want) {
+		t.Fatalf("attempts = %+v", store.attempts)
+	}
+	for i, attempt := range store.attempts {
+		if attempt.Status != want[i] || attempt.StatusCode != http.StatusServiceUnavailable || attempt.Error == "" {
+			t.Errorf("attempt %d = %+v, want %s", i+1, attempt, want[i])
+		}
+	}
+	if got := store.attempts[0].RetryIn; got < 10*time.Second || got > 11*time.Second {
+		t.Errorf("first retry in %s, want 10s plus jitter", got)
+	}
+	if got := store.attempts[1].RetryIn; got < 15*time.Second || got > 17*time.Second {
+		t.Errorf("second retry in %s, want capped at 15s plus jitter", got)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	now := time.Unix(1700000000, 0)
+	header := Sign("secret", now, body)
+
+	if err := Verify("secret", header, body, now.Add(time.Minute), 5*time.Minute); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+	tests := map[string]struct {
+		secret, header string
+		body           []byte
+		now            time.Time
+	}{
+		"other secret": {"other", header, body, now},
+		"changed body": {"secret", header, []byte(`{"id":"2"}`), now},
+		"expired":      {"secret", header, body, now.Add(10 * time.Minute)},
+		"no signature": {"secret", "t=1700000000", body, now},
+		"garbage":      {"secret", "sha256=abc", body, now},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := Verify(tt.secret, tt.header, tt.body, tt.now, 5*time.Minute); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}